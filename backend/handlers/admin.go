@@ -3,11 +3,16 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"lms-backend/mail"
 	"lms-backend/middleware"
 	"lms-backend/models"
+	"lms-backend/security"
+	"lms-backend/xapi"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -17,26 +22,74 @@ import (
 )
 
 type AdminHandler struct {
-	db *sql.DB
+	db     *sql.DB
+	mailer mail.Mailer
 }
 
-func NewAdminHandler(db *sql.DB) *AdminHandler {
-	return &AdminHandler{db: db}
+func NewAdminHandler(db *sql.DB, mailer mail.Mailer) *AdminHandler {
+	return &AdminHandler{db: db, mailer: mailer}
 }
 
 // Course Management
 
-// GetAllCourses gets all courses (admin only)
+// courseListSpec are the sort and filter fields of the admin course list
+var courseListSpec = models.ListSpec{
+	Fields: map[string]models.ListField{
+		"id":          {Column: "id", Type: models.ListNumber},
+		"title":       {Column: "title", Type: models.ListText},
+		"category":    {Column: "category", Type: models.ListText},
+		"level":       {Column: "level", Type: models.ListText},
+		"rating":      {Column: "rating", Type: models.ListNumber},
+		"students":    {Column: "students", Type: models.ListNumber},
+		"status":      {Column: "status", Type: models.ListText},
+		"publishAt":   {Column: "publish_at", Type: models.ListTime},
+		"unpublishAt": {Column: "unpublish_at", Type: models.ListTime},
+		"isTemplate":  {Column: "is_template", Type: models.ListBool},
+		"createdAt":   {Column: "created_at", Type: models.ListTime},
+		"updatedAt":   {Column: "updated_at", Type: models.ListTime},
+	},
+	SearchColumns: []string{"title", "category", "description"},
+	DefaultSort:   "-createdAt",
+	IDColumn:      "id",
+}
+
+// GetAllCourses gets a page of courses whatever their status (admin only),
+// optionally filtered with ?status=draft|review|published|archived and
+// ?template=true|false
 func (h *AdminHandler) GetAllCourses(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[ADMIN DEBUG] GetAllCourses called")
-	query := `
-		SELECT id, title, description, category, level, duration, instructor, rating, students, image,
-		       intro_material, lessons, pre_test, post_test, post_work, final_project, created_at, updated_at
-		FROM courses
-		ORDER BY created_at DESC
-	`
+	status := r.URL.Query().Get("status")
+	if status != "" && (models.CourseStatusRequest{Status: status}).Validate() != nil {
+		http.Error(w, "Invalid status filter", http.StatusBadRequest)
+		return
+	}
 
-	rows, err := h.db.Query(query)
+	var template interface{}
+	if value := r.URL.Query().Get("template"); value != "" {
+		isTemplate, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid template filter", http.StatusBadRequest)
+			return
+		}
+		template = isTemplate
+	}
+	list, ok := parseListQuery(w, r, courseListSpec)
+	if !ok {
+		return
+	}
+
+	columns := `id, title, description, category, level, duration,
+		       ` + models.InstructorNamesSQL("courses.id", "courses.instructor") + ` AS instructor,
+		       rating, students, image,
+		       intro_material, ` + models.LessonsJSONSQL("courses.id") + ` AS lessons,
+		       pre_test, post_test, post_work, final_project, status, publish_at, unpublish_at, is_template,
+		       created_at, updated_at`
+	where := []string{
+		models.InstructorCourseFilter("id", "$1"),
+		"($2::boolean IS NULL OR is_template = $2)",
+	}
+
+	rows, page, err := list.Query(h.db, columns, "courses", where, instructorScope(r), template)
 	if err != nil {
 		log.Printf("[ADMIN ERROR] Error querying courses: %v", err)
 		http.Error(w, "Failed to get courses", http.StatusInternalServerError)
@@ -51,6 +104,7 @@ func (h *AdminHandler) GetAllCourses(w http.ResponseWriter, r *http.Request) {
 			&course.Level, &course.Duration, &course.Instructor, &course.Rating,
 			&course.Students, &course.Image, &course.IntroMaterial, &course.Lessons,
 			&course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
+			&course.Status, &course.PublishAt, &course.UnpublishAt, &course.IsTemplate,
 			&course.CreatedAt, &course.UpdatedAt)
 		if err != nil {
 			log.Printf("[ADMIN ERROR] Error scanning course: %v", err)
@@ -68,8 +122,9 @@ func (h *AdminHandler) GetAllCourses(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[ADMIN DEBUG] Found %d courses", len(courses))
 	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
-		"success": true,
-		"data":    courses,
+		"success":    true,
+		"data":       courses,
+		"pagination": page,
 	}
 	log.Printf("[ADMIN DEBUG] Sending response: %+v", response)
 	json.NewEncoder(w).Encode(response)
@@ -83,22 +138,54 @@ func (h *AdminHandler) CreateCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New courses are drafts unless the request publishes or schedules them
+	if course.Status == "" {
+		course.Status = models.CourseStatusDraft
+	}
+	statusReq := models.CourseStatusRequest{Status: course.Status, PublishAt: course.PublishAt, UnpublishAt: course.UnpublishAt}
+	if err := statusReq.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to create course", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO courses (title, description, category, level, duration, instructor, rating, students, image, intro_material, lessons, pre_test, post_test, post_work, final_project)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		INSERT INTO courses (title, description, category, level, duration, instructor, rating, students, image, intro_material, pre_test, post_test, post_work, final_project, status, publish_at, unpublish_at, is_template)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id, created_at, updated_at
 	`
 
-	err := h.db.QueryRow(query, course.Title, course.Description, course.Category, course.Level,
+	err = tx.QueryRow(query, course.Title, course.Description, course.Category, course.Level,
 		course.Duration, course.Instructor, course.Rating, course.Students, course.Image,
-		course.IntroMaterial, course.Lessons, course.PreTest, course.PostTest,
-		course.PostWork, course.FinalProject).Scan(&course.ID, &course.CreatedAt, &course.UpdatedAt)
+		course.IntroMaterial, course.PreTest, course.PostTest,
+		course.PostWork, course.FinalProject, course.Status, course.PublishAt, course.UnpublishAt,
+		course.IsTemplate).Scan(&course.ID, &course.CreatedAt, &course.UpdatedAt)
 
 	if err != nil {
 		http.Error(w, "Failed to create course", http.StatusInternalServerError)
 		return
 	}
 
+	if !h.syncCourseLessons(w, tx, course.ID, course.Lessons) {
+		return
+	}
+
+	if !h.recordCourseRevision(w, r, tx, course.ID) {
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to create course", http.StatusInternalServerError)
+		return
+	}
+	course.Lessons, _ = models.GetCourseLessonsJSON(h.db, course.ID)
+
 	// Create default stage locks for the new course
 	defaultStages := []string{
 		"intro",
@@ -139,6 +226,10 @@ func (h *AdminHandler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return
+	}
+
 	var course models.Course
 	if err := json.NewDecoder(r.Body).Decode(&course); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -147,19 +238,33 @@ func (h *AdminHandler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 
 	course.ID = courseID
 
+	// The status is only changed when the request carries one; publishing and
+	// archiving need the same permission as the status endpoint
+	statusReq := models.CourseStatusRequest{Status: course.Status, PublishAt: course.PublishAt, UnpublishAt: course.UnpublishAt}
+	if course.Status != "" && !h.checkCourseStatusRequest(w, r, statusReq) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to update course", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE courses SET
 			title = $1, description = $2, category = $3, level = $4, duration = $5,
 			instructor = $6, rating = $7, students = $8, image = $9,
-			intro_material = $10, lessons = $11, pre_test = $12, post_test = $13,
-			post_work = $14, final_project = $15, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $16
+			intro_material = $10, pre_test = $11, post_test = $12,
+			post_work = $13, final_project = $14, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $15
 		RETURNING updated_at
 	`
 
-	err = h.db.QueryRow(query, course.Title, course.Description, course.Category, course.Level,
+	err = tx.QueryRow(query, course.Title, course.Description, course.Category, course.Level,
 		course.Duration, course.Instructor, course.Rating, course.Students, course.Image,
-		course.IntroMaterial, course.Lessons, course.PreTest, course.PostTest,
+		course.IntroMaterial, course.PreTest, course.PostTest,
 		course.PostWork, course.FinalProject, courseID).Scan(&course.UpdatedAt)
 
 	if err != nil {
@@ -167,6 +272,32 @@ func (h *AdminHandler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Lessons are only touched when the request carries them; lessons keep their IDs
+	// (and progress) as long as the request sends those IDs back
+	if !h.syncCourseLessons(w, tx, courseID, course.Lessons) {
+		return
+	}
+
+	if course.Status != "" {
+		_, err = tx.Exec(`UPDATE courses SET status = $1, publish_at = $2, unpublish_at = $3 WHERE id = $4`,
+			course.Status, course.PublishAt, course.UnpublishAt, courseID)
+		if err != nil {
+			http.Error(w, "Failed to update course", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Every save is kept as a revision; learners stay on the revision they enrolled in
+	if !h.recordCourseRevision(w, r, tx, courseID) {
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update course", http.StatusInternalServerError)
+		return
+	}
+	course.Lessons, _ = models.GetCourseLessonsJSON(h.db, courseID)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -175,6 +306,111 @@ func (h *AdminHandler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetCourseAdmin gets a course whatever its status, so drafts can be previewed (admin only)
+func (h *AdminHandler) GetCourseAdmin(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return
+	}
+
+	course, err := models.GetCourseByID(h.db, courseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting course %d: %v", courseID, err)
+		http.Error(w, "Failed to get course", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"data":      course,
+		"isVisible": course.IsVisible(time.Now()),
+	})
+}
+
+// UpdateCourseStatus moves a course through the draft/publish workflow and sets
+// its publishing schedule (admin only)
+func (h *AdminHandler) UpdateCourseStatus(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return
+	}
+
+	var req models.CourseStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !h.checkCourseStatusRequest(w, r, req) {
+		return
+	}
+
+	err = models.UpdateCourseStatus(h.db, courseID, req)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error updating status of course %d: %v", courseID, err)
+		http.Error(w, "Failed to update course status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"message":     "Course status updated successfully",
+		"status":      req.Status,
+		"publishAt":   req.PublishAt,
+		"unpublishAt": req.UnpublishAt,
+	})
+}
+
+// checkCourseStatusRequest validates a status change and that the caller may make it:
+// editors move courses between draft and review, publishing and archiving need courses.manage
+func (h *AdminHandler) checkCourseStatusRequest(w http.ResponseWriter, r *http.Request, req models.CourseStatusRequest) bool {
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if req.RequiresPublishPermission() && !middleware.HasPermission(r, "courses.manage") {
+		http.Error(w, "Permission required: courses.manage", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// syncCourseLessons stores the lessons array of a course create or update request
+func (h *AdminHandler) syncCourseLessons(w http.ResponseWriter, tx *sql.Tx, courseID int, lessons *json.RawMessage) bool {
+	if lessons == nil {
+		return true
+	}
+	if err := models.SyncCourseLessons(tx, courseID, *lessons); err != nil {
+		if errors.Is(err, models.ErrInvalidLessons) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		log.Printf("[ADMIN ERROR] Failed to save lessons for course %d: %v", courseID, err)
+		http.Error(w, "Failed to save lessons", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
 // DeleteCourse deletes a course (admin only)
 func (h *AdminHandler) DeleteCourse(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -207,123 +443,17 @@ func (h *AdminHandler) DeleteCourse(w http.ResponseWriter, r *http.Request) {
 
 // Test Results Management
 
-// GetAllTestResults gets all pre test and post test results (admin only)
+// GetAllTestResults gets a page of pre test and post test results (admin only)
 func (h *AdminHandler) GetAllTestResults(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[ADMIN DEBUG] GetAllTestResults called")
-	
-	query := `
-		SELECT 
-			qa.id as attempt_id,
-			qa.quiz_id,
-			qa.user_id,
-			qa.score,
-			qa.time_spent,
-			qa.passed,
-			qa.attempt_number,
-			qa.submitted_at,
-			qa.created_at,
-			u.full_name as user_name,
-			u.email as user_email,
-			c.id as course_id,
-			c.title as course_title,
-			q.title as quiz_title,
-			q.quiz_type,
-			q.passing_score,
-			-- Calculate correct and total count from answers and questions
-			COALESCE(
-				(
-					SELECT COUNT(*)
-					FROM json_array_elements(q.questions::json) as question
-				), 0
-			) as total_count,
-			-- Estimate correct count based on score and total questions
-			COALESCE(
-				ROUND(
-					(qa.score::float / 100.0) * 
-					(
-						SELECT COUNT(*)
-						FROM json_array_elements(q.questions::json) as question
-					)
-				), 0
-			) as correct_count
-		FROM quiz_attempts qa
-		JOIN users u ON qa.user_id = u.id
-		JOIN quizzes q ON qa.quiz_id = q.id
-		JOIN courses c ON q.course_id = c.id
-		WHERE qa.completed = true 
-			AND qa.submitted_at IS NOT NULL
-			AND q.quiz_type IN ('pretest', 'posttest')
-		ORDER BY qa.submitted_at DESC
-	`
-
-	rows, err := h.db.Query(query)
-	if err != nil {
-		log.Printf("[ADMIN ERROR] Error querying test results: %v", err)
-		http.Error(w, "Failed to get test results", http.StatusInternalServerError)
+	list, ok := parseListQuery(w, r, models.TestResultListSpec)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	type TestResult struct {
-		AttemptID     int        `json:"attempt_id"`
-		QuizID        int        `json:"quiz_id"`
-		UserID        int        `json:"user_id"`
-		UserName      string     `json:"user_name"`
-		UserEmail     string     `json:"user_email"`
-		CourseID      int        `json:"course_id"`
-		CourseTitle   string     `json:"course_title"`
-		QuizTitle     string     `json:"quiz_title"`
-		QuizType      string     `json:"quiz_type"`
-		Score         int        `json:"score"`
-		Passed        bool       `json:"passed"`
-		PassingScore  int        `json:"passing_score"`
-		CorrectCount  int        `json:"correct_count"`
-		TotalCount    int        `json:"total_count"`
-		TimeSpent     int        `json:"time_spent"`
-		AttemptNumber int        `json:"attempt_number"`
-		SubmittedAt   *time.Time `json:"submitted_at"`
-		CreatedAt     time.Time  `json:"created_at"`
-	}
-
-	var results []TestResult
-	for rows.Next() {
-		var result TestResult
-		var submittedAt sql.NullTime
-		
-		err := rows.Scan(
-			&result.AttemptID,
-			&result.QuizID,
-			&result.UserID,
-			&result.Score,
-			&result.TimeSpent,
-			&result.Passed,
-			&result.AttemptNumber,
-			&submittedAt,
-			&result.CreatedAt,
-			&result.UserName,
-			&result.UserEmail,
-			&result.CourseID,
-			&result.CourseTitle,
-			&result.QuizTitle,
-			&result.QuizType,
-			&result.PassingScore,
-			&result.TotalCount,
-			&result.CorrectCount,
-		)
-		if err != nil {
-			log.Printf("[ADMIN ERROR] Error scanning test result: %v", err)
-			continue
-		}
-		
-		if submittedAt.Valid {
-			result.SubmittedAt = &submittedAt.Time
-		}
-		
-		results = append(results, result)
-	}
-
-	if err = rows.Err(); err != nil {
-		log.Printf("[ADMIN ERROR] Error iterating test results: %v", err)
+	results, page, err := models.GetTestResults(h.db, instructorScope(r), list)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error querying test results: %v", err)
 		http.Error(w, "Failed to get test results", http.StatusInternalServerError)
 		return
 	}
@@ -331,8 +461,9 @@ func (h *AdminHandler) GetAllTestResults(w http.ResponseWriter, r *http.Request)
 	log.Printf("[ADMIN DEBUG] Found %d test results", len(results))
 	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
-		"success": true,
-		"data":    results,
+		"success":    true,
+		"data":       results,
+		"pagination": page,
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -374,11 +505,20 @@ func (h *AdminHandler) CreateGrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireCourseAccess(h.db, w, r, req.CourseID) {
+		return
+	}
+
 	grade, err := models.CreateGrade(h.db, req)
 	if err != nil {
 		http.Error(w, "Failed to create grade", http.StatusInternalServerError)
 		return
 	}
+	recordStatement(h.db, req.UserID, xapi.Statement{
+		Verb:   xapi.VerbScored,
+		Object: courseActivity(req.CourseID, ""),
+		Result: &xapi.Result{Score: xapi.PercentageScore(req.Grade)},
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -388,18 +528,25 @@ func (h *AdminHandler) CreateGrade(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetGrades gets all grades (admin only)
+// GetGrades gets a page of grades (admin only)
 func (h *AdminHandler) GetGrades(w http.ResponseWriter, r *http.Request) {
-	grades, err := models.GetAllGrades(h.db)
+	list, ok := parseListQuery(w, r, models.GradeListSpec)
+	if !ok {
+		return
+	}
+
+	grades, page, err := models.GetAllGrades(h.db, instructorScope(r), list)
 	if err != nil {
+		log.Printf("[ADMIN ERROR] Error querying grades: %v", err)
 		http.Error(w, "Failed to get grades", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"grades":  grades,
+		"success":    true,
+		"grades":     grades,
+		"pagination": page,
 	})
 }
 
@@ -412,6 +559,10 @@ func (h *AdminHandler) GetCoursePreTestAdmin(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return
+	}
+
 	preTest, err := models.GetQuizByTypeAndCourse(h.db, courseID, "pretest")
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -443,6 +594,10 @@ func (h *AdminHandler) GetCoursePostTestAdmin(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return
+	}
+
 	postTest, err := models.GetQuizByTypeAndCourse(h.db, courseID, "posttest")
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -474,6 +629,10 @@ func (h *AdminHandler) GetCourseSubmissions(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return
+	}
+
 	submissions, err := models.GetCourseSubmissionsWithGrades(h.db, courseID)
 	if err != nil {
 		http.Error(w, "Failed to get submissions", http.StatusInternalServerError)
@@ -507,22 +666,38 @@ type UserResponse struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
-// GetAllUsers gets all users (admin only)
+// userListSpec are the sort and filter fields of the admin user list
+var userListSpec = models.ListSpec{
+	Fields: map[string]models.ListField{
+		"id":        {Column: "id", Type: models.ListNumber},
+		"username":  {Column: "username", Type: models.ListText},
+		"fullName":  {Column: "full_name", Type: models.ListText},
+		"email":     {Column: "email", Type: models.ListText},
+		"role":      {Column: "role", Type: models.ListText},
+		"createdAt": {Column: "created_at", Type: models.ListTime},
+		"updatedAt": {Column: "updated_at", Type: models.ListTime},
+	},
+	SearchColumns: []string{"username", "full_name", "email"},
+	DefaultSort:   "-createdAt",
+	IDColumn:      "id",
+}
+
+// GetAllUsers gets a page of users (admin only)
 func (h *AdminHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	query := `
-		SELECT id, username, full_name, email, role, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
-	`
+	list, ok := parseListQuery(w, r, userListSpec)
+	if !ok {
+		return
+	}
 
-	rows, err := h.db.Query(query)
+	rows, page, err := list.Query(h.db, "id, username, full_name, email, role, created_at, updated_at", "users", nil)
 	if err != nil {
+		log.Printf("[ADMIN ERROR] Error querying users: %v", err)
 		http.Error(w, "Failed to get users", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var users []UserResponse
+	users := []UserResponse{}
 	for rows.Next() {
 		var user UserResponse
 		err := rows.Scan(&user.ID, &user.Username, &user.FullName, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
@@ -535,8 +710,9 @@ func (h *AdminHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"users":   users,
+		"success":    true,
+		"users":      users,
+		"pagination": page,
 	})
 }
 
@@ -555,8 +731,13 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate role if provided
-	if req.Role != "" && req.Role != "user" && req.Role != "admin" {
-		http.Error(w, "Role must be either 'user' or 'admin'", http.StatusBadRequest)
+	if req.Role != "" && !h.validateRole(w, req.Role) {
+		return
+	}
+
+	// Validate password against the password policy
+	if err := security.PasswordPolicyFromEnv().Validate(req.Password, req.Username, req.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -573,8 +754,8 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		INSERT INTO users (username, full_name, email, password_hash, role)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (username, full_name, email, password_hash, role, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
 		RETURNING id, created_at, updated_at
 	`
 
@@ -585,8 +766,8 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Username or email already exists", http.StatusConflict)
 			return
 		}
-		if strings.Contains(err.Error(), "check constraint") {
-			http.Error(w, "Invalid role value. Must be 'user' or 'admin'", http.StatusBadRequest)
+		if strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Invalid role value", http.StatusBadRequest)
 			return
 		}
 		http.Error(w, fmt.Sprintf("Failed to create user: %v", err), http.StatusInternalServerError)
@@ -643,11 +824,18 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		argIndex++
 	}
 	if req.Role != "" {
+		if !h.validateRole(w, req.Role) {
+			return
+		}
 		setParts = append(setParts, fmt.Sprintf("role = $%d", argIndex))
 		args = append(args, req.Role)
 		argIndex++
 	}
 	if req.Password != "" {
+		if err := security.PasswordPolicyFromEnv().Validate(req.Password, req.Username, req.Email); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			http.Error(w, "Failed to hash password", http.StatusInternalServerError)
@@ -730,6 +918,299 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UnlockUserLogin clears the failed-login lockout of a user, and optionally of
+// a client address given as {"ipAddress": "..."} (admin only)
+func (h *AdminHandler) UnlockUserLogin(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["id"]
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		IPAddress string `json:"ipAddress"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := models.GetUserByID(h.db, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	keys := []string{models.UsernameThrottleKey(user.Username)}
+	if req.IPAddress != "" {
+		keys = append(keys, models.IPThrottleKey(req.IPAddress))
+	}
+
+	if err := models.ClearLoginFailures(h.db, keys...); err != nil {
+		log.Printf("[ADMIN DEBUG] Failed to unlock login for user %d: %v", userID, err)
+		http.Error(w, "Failed to unlock user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "User login unlocked successfully",
+	})
+}
+
+// RevokeUserSessions signs a user out everywhere by revoking all refresh tokens
+// and invalidating all issued access tokens (admin only)
+func (h *AdminHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["id"]
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RevokeAllSessions(h.db, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN DEBUG] Failed to revoke sessions for user %d: %v", userID, err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "All sessions revoked successfully",
+	})
+}
+
+// validateRole writes an error and returns false unless role exists
+func (h *AdminHandler) validateRole(w http.ResponseWriter, role string) bool {
+	exists, err := models.RoleExists(h.db, role)
+	if err != nil {
+		http.Error(w, "Failed to validate role", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, fmt.Sprintf("Role '%s' does not exist", role), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Role and Permission Management
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// GetRoles gets all roles with their permissions
+func (h *AdminHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := models.GetAllRoles(h.db)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting roles: %v", err)
+		http.Error(w, "Failed to get roles", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"roles":   roles,
+	})
+}
+
+// GetPermissions gets all permissions that can be granted to roles
+func (h *AdminHandler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := models.GetAllPermissions(h.db)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting permissions: %v", err)
+		http.Error(w, "Failed to get permissions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"permissions": permissions,
+	})
+}
+
+// CreateRole creates a custom role
+func (h *AdminHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !roleNamePattern.MatchString(req.Name) {
+		http.Error(w, "Role name must be 2-50 lowercase letters, digits or underscores", http.StatusBadRequest)
+		return
+	}
+
+	if req.Scope == "" {
+		req.Scope = models.RoleScopeGlobal
+	}
+	if req.Scope != models.RoleScopeGlobal && req.Scope != models.RoleScopeCourse {
+		http.Error(w, "Scope must be either 'global' or 'course'", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.CreateRole(h.db, req); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Role already exists", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Unknown permission", http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error creating role: %v", err)
+		http.Error(w, "Failed to create role", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Role created successfully",
+	})
+}
+
+// SetRolePermissions replaces the permissions of a custom role
+func (h *AdminHandler) SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	roleName := mux.Vars(r)["name"]
+
+	var req models.SetRolePermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := models.SetRolePermissions(h.db, roleName, req.Permissions)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Role not found", http.StatusNotFound)
+			return
+		}
+		if err == models.ErrSystemRole {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Unknown permission", http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error updating permissions for role %s: %v", roleName, err)
+		http.Error(w, "Failed to update role permissions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Role permissions updated successfully",
+	})
+}
+
+// Course Instructor Assignment
+
+// GetCourseInstructors gets the users assigned to a course
+func (h *AdminHandler) GetCourseInstructors(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	instructors, err := models.GetCourseInstructors(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting instructors for course %d: %v", courseID, err)
+		http.Error(w, "Failed to get course instructors", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"instructors": instructors,
+	})
+}
+
+// AssignCourseInstructor assigns a user to a course so course-scoped roles can act on it
+func (h *AdminHandler) AssignCourseInstructor(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AssignInstructorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.AssignCourseInstructor(h.db, courseID, req.UserID); err != nil {
+		if strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Course or user not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error assigning user %d to course %d: %v", req.UserID, courseID, err)
+		http.Error(w, "Failed to assign instructor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Instructor assigned successfully",
+	})
+}
+
+// RemoveCourseInstructor removes a user's assignment to a course
+func (h *AdminHandler) RemoveCourseInstructor(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RemoveCourseInstructor(h.db, courseID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Instructor assignment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error removing user %d from course %d: %v", userID, courseID, err)
+		http.Error(w, "Failed to remove instructor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Instructor removed successfully",
+	})
+}
+
 // Announcement Management
 
 // CreateAnnouncement creates a new announcement (admin only)
@@ -787,9 +1268,14 @@ func (h *AdminHandler) CreateAnnouncement(w http.ResponseWriter, r *http.Request
 	})
 }
 
-// GetAllAnnouncements gets all announcements (admin only)
+// GetAllAnnouncements gets a page of announcements (admin only)
 func (h *AdminHandler) GetAllAnnouncements(w http.ResponseWriter, r *http.Request) {
-	announcements, err := models.GetAllAnnouncements(h.db)
+	list, ok := parseListQuery(w, r, models.AnnouncementListSpec)
+	if !ok {
+		return
+	}
+
+	announcements, page, err := models.GetAllAnnouncements(h.db, list)
 	if err != nil {
 		log.Printf("Error getting announcements: %v", err)
 		http.Error(w, "Failed to get announcements", http.StatusInternalServerError)
@@ -800,6 +1286,7 @@ func (h *AdminHandler) GetAllAnnouncements(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":       true,
 		"announcements": announcements,
+		"pagination":    page,
 	})
 }
 
@@ -929,15 +1416,8 @@ func (h *AdminHandler) GetDashboardStats(w http.ResponseWriter, r *http.Request)
 		stats.TotalCourses = 0
 	}
 
-	// Get total lessons (sum of lessons from all courses)
-	err = h.db.QueryRow(`
-		SELECT COALESCE(SUM(CASE 
-			WHEN lessons IS NOT NULL AND lessons != 'null' AND lessons != '[]' 
-			THEN json_array_length(lessons::json) 
-			ELSE 0 
-		END), 0) 
-		FROM courses
-	`).Scan(&stats.TotalLessons)
+	// Get total lessons
+	err = h.db.QueryRow("SELECT COUNT(*) FROM lessons").Scan(&stats.TotalLessons)
 	if err != nil {
 		log.Printf("Error getting total lessons: %v", err)
 		stats.TotalLessons = 0
@@ -3,15 +3,28 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"lms-backend/mail"
 	"lms-backend/middleware"
 	"lms-backend/models"
+	"lms-backend/oidc"
+	"lms-backend/security"
 )
 
 type AuthHandler struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Mailer mail.Mailer
+	// OIDC is nil when single sign-on is not configured
+	OIDC *oidc.Provider
 }
 
 type ErrorResponse struct {
@@ -26,8 +39,8 @@ type SuccessResponse struct {
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{DB: db}
+func NewAuthHandler(db *sql.DB, mailer mail.Mailer, oidcProvider *oidc.Provider) *AuthHandler {
+	return &AuthHandler{DB: db, Mailer: mailer, OIDC: oidcProvider}
 }
 
 // Register handles user registration
@@ -67,12 +80,12 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate password length
-	if len(req.Password) < 3 {
+	// Validate password against the password policy
+	if err := security.PasswordPolicyFromEnv().Validate(req.Password, req.Username, req.Email); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "Password too short",
-			Message: "Password must be at least 3 characters long",
+			Error:   "Weak password",
+			Message: err.Error(),
 		})
 		return
 	}
@@ -107,8 +120,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateJWT(user)
+	// Send email verification link
+	h.sendVerificationEmail(user)
+
+	// Generate access and refresh tokens
+	session, err := h.issueSession(r, user)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(ErrorResponse{
@@ -123,10 +139,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(SuccessResponse{
 		Success: true,
 		Message: "User registered successfully",
-		Data: models.LoginResponse{
-			User:  *user,
-			Token: token,
-		},
+		Data:    session,
 	})
 }
 
@@ -157,9 +170,26 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user from database
-	user, err := models.GetUserByUsername(h.DB, req.Username)
+	// Refuse while the username or client address is locked out
+	userKey := models.UsernameThrottleKey(req.Username)
+	ipKey := models.IPThrottleKey(clientIP(r))
+	lockedUntil, err := models.GetLoginLockedUntil(h.DB, userKey, ipKey)
 	if err != nil {
+		log.Printf("Error checking login lockout: %v", err)
+	}
+	if lockedUntil != nil {
+		writeLoginLocked(w, *lockedUntil)
+		return
+	}
+
+	// Get user from database and check password
+	user, err := models.GetUserByUsername(h.DB, req.Username)
+	if err != nil || !user.CheckPassword(req.Password) {
+		lockedUntil = h.recordLoginFailure(userKey, ipKey)
+		if lockedUntil != nil {
+			writeLoginLocked(w, *lockedUntil)
+			return
+		}
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(ErrorResponse{
 			Error:   "Invalid credentials",
@@ -168,17 +198,103 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check password
-	if !user.CheckPassword(req.Password) {
+	// Users with 2FA enabled get a challenge instead of a session; their
+	// failures are cleared once the code is verified
+	if user.TwoFactorEnabled {
+		h.writeTwoFactorChallenge(w, user)
+		return
+	}
+
+	// Successful login clears the username counter; the per-IP counter is kept
+	// so one valid account cannot be used to reset it
+	if err := models.ClearLoginFailures(h.DB, userKey); err != nil {
+		log.Printf("Error clearing login failures: %v", err)
+	}
+
+	// Generate access and refresh tokens
+	session, err := h.issueSession(r, user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to generate token",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Return success response
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    session,
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Missing refresh token",
+			Message: "Refresh token is required",
+		})
+		return
+	}
+
+	ttl, err := middleware.RefreshTokenExpiry()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to refresh token",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userID, refreshToken, err := models.RotateRefreshToken(h.DB, req.RefreshToken, ttl, r.UserAgent(), clientIP(r))
+	if err == models.ErrRefreshTokenInvalid {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "Invalid credentials",
-			Message: "Username atau password salah",
+			Error:   "Invalid refresh token",
+			Message: "Sesi telah berakhir, silakan login kembali",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to refresh token",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid refresh token",
+			Message: "User not found",
 		})
 		return
 	}
 
-	// Generate JWT token
 	token, err := middleware.GenerateJWT(user)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -189,18 +305,412 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return success response
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(SuccessResponse{
 		Success: true,
-		Message: "Login successful",
+		Message: "Token refreshed",
 		Data: models.LoginResponse{
-			User:  *user,
-			Token: token,
+			User:         *user,
+			Token:        token,
+			RefreshToken: refreshToken,
 		},
 	})
 }
 
+// Logout revokes the given refresh token, or every session of the user when allDevices is set
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var logoutReq struct {
+		RefreshToken string `json:"refreshToken"`
+		AllDevices   bool   `json:"allDevices"`
+	}
+
+	// Body is optional
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&logoutReq); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	if logoutReq.AllDevices {
+		err = models.RevokeAllSessions(h.DB, user.ID)
+	} else if logoutReq.RefreshToken != "" {
+		err = models.RevokeRefreshToken(h.DB, user.ID, logoutReq.RefreshToken)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to logout",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Logout successful",
+	})
+}
+
+// ForgotPassword emails a password reset link to the account with the given email.
+// The response is the same whether or not the email is registered.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Missing required fields",
+			Message: "Email is required",
+		})
+		return
+	}
+
+	user, err := models.GetUserByEmail(h.DB, strings.TrimSpace(req.Email))
+	if err == nil {
+		h.sendPasswordResetEmail(user)
+	} else if err != sql.ErrNoRows {
+		log.Printf("Error looking up user for password reset: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Jika email terdaftar, link reset password telah dikirim",
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Missing required fields",
+			Message: "Token and new password are required",
+		})
+		return
+	}
+
+	// Validate password against the password policy
+	if err := security.PasswordPolicyFromEnv().Validate(req.NewPassword); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Weak password",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	_, err := models.ResetPasswordWithToken(h.DB, req.Token, req.NewPassword)
+	if err == models.ErrPasswordResetTokenInvalid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid token",
+			Message: "Link reset password tidak valid atau sudah kedaluwarsa",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to reset password",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Password berhasil direset, silakan login kembali",
+	})
+}
+
+// VerifyEmail confirms a user's email address using the token from the verification email
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Missing token",
+			Message: "Verification token is required",
+		})
+		return
+	}
+
+	_, err := models.VerifyEmailWithToken(h.DB, token)
+	if err == models.ErrEmailVerificationTokenInvalid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid token",
+			Message: "Link verifikasi tidak valid atau sudah kedaluwarsa",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to verify email",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Email berhasil diverifikasi",
+	})
+}
+
+// ResendVerification sends a new verification email to the current user
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if user.IsEmailVerified() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Already verified",
+			Message: "Email sudah terverifikasi",
+		})
+		return
+	}
+
+	h.sendVerificationEmail(user)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Link verifikasi telah dikirim",
+	})
+}
+
+// sendVerificationEmail issues a verification token and mails the link to the user
+func (h *AuthHandler) sendVerificationEmail(user *models.User) {
+	ttl, err := durationFromEnv("EMAIL_VERIFICATION_EXPIRY", "48h")
+	if err != nil {
+		log.Printf("Error reading email verification expiry: %v", err)
+		return
+	}
+
+	token, err := models.CreateEmailVerificationToken(h.DB, user.ID, ttl)
+	if err != nil {
+		log.Printf("Error creating email verification token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := frontendURL() + "/verify-email?token=" + url.QueryEscape(token)
+	err = h.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verifikasi email Anda",
+		Body: "Halo " + user.FullName + ",\n\n" +
+			"Terima kasih telah mendaftar. Buka link berikut untuk memverifikasi email Anda:\n\n" +
+			link + "\n\n" +
+			"Link ini berlaku selama " + ttl.String() + ".\n",
+	})
+	if err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+	}
+}
+
+// EmailVerificationRequired reports whether learners must verify their email
+// before enrolling, controlled by REQUIRE_EMAIL_VERIFICATION
+func EmailVerificationRequired() bool {
+	value, err := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+	return err == nil && value
+}
+
+// sendPasswordResetEmail issues a reset token and mails the link to the user.
+// Failures are logged rather than returned so the caller cannot leak whether
+// the account exists.
+func (h *AuthHandler) sendPasswordResetEmail(user *models.User) {
+	ttl, err := durationFromEnv("PASSWORD_RESET_EXPIRY", "1h")
+	if err != nil {
+		log.Printf("Error reading password reset expiry: %v", err)
+		return
+	}
+
+	token, err := models.CreatePasswordResetToken(h.DB, user.ID, ttl)
+	if err != nil {
+		log.Printf("Error creating password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := frontendURL() + "/reset-password?token=" + url.QueryEscape(token)
+	err = h.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset password",
+		Body: "Halo " + user.FullName + ",\n\n" +
+			"Kami menerima permintaan untuk mereset password akun Anda. " +
+			"Buka link berikut untuk membuat password baru:\n\n" + link + "\n\n" +
+			"Link ini berlaku selama " + ttl.String() + " dan hanya dapat digunakan sekali. " +
+			"Abaikan email ini jika Anda tidak meminta reset password.\n",
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// recordLoginFailure counts a failed login for the username and client address
+// and returns the lockout expiry if either became locked
+func (h *AuthHandler) recordLoginFailure(userKey, ipKey string) *time.Time {
+	var lockedUntil *time.Time
+
+	userLock, err := models.RecordLoginFailure(h.DB, userKey, security.UsernameLockoutPolicyFromEnv())
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+	} else if userLock != nil {
+		lockedUntil = userLock
+	}
+
+	ipLock, err := models.RecordLoginFailure(h.DB, ipKey, security.IPLockoutPolicyFromEnv())
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+	} else if ipLock != nil && (lockedUntil == nil || ipLock.After(*lockedUntil)) {
+		lockedUntil = ipLock
+	}
+
+	return lockedUntil
+}
+
+// writeLoginLocked responds with 429 and a Retry-After header
+func writeLoginLocked(w http.ResponseWriter, lockedUntil time.Time) {
+	retryAfter := int(time.Until(lockedUntil).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error:   "Too many failed login attempts",
+		Message: fmt.Sprintf("Terlalu banyak percobaan login gagal, coba lagi dalam %d detik", retryAfter),
+	})
+}
+
+// issueSession generates an access token and a new refresh token for the user
+func (h *AuthHandler) issueSession(r *http.Request, user *models.User) (*models.LoginResponse, error) {
+	token, err := middleware.GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := middleware.RefreshTokenExpiry()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := models.CreateRefreshToken(h.DB, user.ID, ttl, r.UserAgent(), clientIP(r))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		User:         *user,
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// frontendURL returns the base URL of the web app used in emailed links
+func frontendURL() string {
+	base := os.Getenv("FRONTEND_URL")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// durationFromEnv parses a duration from the environment, falling back to def
+func durationFromEnv(key, def string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		value = def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s format: %v", key, err)
+	}
+	return d, nil
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only honoured
+// when TRUST_PROXY_HEADERS is set, since clients can forge it otherwise.
+func clientIP(r *http.Request) string {
+	trustProxy, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS"))
+	if forwarded := r.Header.Get("X-Forwarded-For"); trustProxy && forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // GetProfile returns the current user's profile
 func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -258,6 +768,7 @@ func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update user fields
+	emailChanged := updateReq.Email != "" && !strings.EqualFold(updateReq.Email, user.Email)
 	if updateReq.Username != "" {
 		user.Username = updateReq.Username
 	}
@@ -279,6 +790,16 @@ func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A new email address has to be verified again
+	if emailChanged {
+		if err := models.ResetEmailVerification(h.DB, user.ID); err != nil {
+			log.Printf("Error resetting email verification for user %d: %v", user.ID, err)
+		} else {
+			user.EmailVerifiedAt = nil
+			h.sendVerificationEmail(user)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(SuccessResponse{
 		Success: true,
@@ -328,12 +849,12 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate new password length
-	if len(changePasswordReq.NewPassword) < 3 {
+	// Validate password against the password policy
+	if err := security.PasswordPolicyFromEnv().Validate(changePasswordReq.NewPassword, user.Username, user.Email); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "Password too short",
-			Message: "Password must be at least 3 characters long",
+			Error:   "Weak password",
+			Message: err.Error(),
 		})
 		return
 	}
@@ -141,28 +141,20 @@ func (h *CertificateHandler) GetUserCertificates(w http.ResponseWriter, r *http.
 	})
 }
 
-// GetAllCertificates returns all certificates (admin only)
+// GetAllCertificates returns a page of certificates (admin only)
 func (h *CertificateHandler) GetAllCertificates(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from context
-	userID, err := middleware.GetUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Failed to get user ID", http.StatusInternalServerError)
+	// Check that the user's role grants access to certificates
+	if !middleware.HasPermission(r, "certificates.view") {
+		http.Error(w, "Access denied. Permission certificates.view required.", http.StatusForbidden)
 		return
 	}
 
-	// Check if user is admin
-	user, err := models.GetUserByID(h.db, userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	list, ok := parseListQuery(w, r, models.CertificateListSpec)
+	if !ok {
 		return
 	}
 
-	if user.Role != "admin" {
-		http.Error(w, "Access denied. Admin role required.", http.StatusForbidden)
-		return
-	}
-
-	certificates, err := models.GetAllCertificates(h.db)
+	certificates, page, err := models.GetAllCertificates(h.db, instructorScope(r), list)
 	if err != nil {
 		http.Error(w, "Failed to get certificates", http.StatusInternalServerError)
 		return
@@ -172,31 +164,24 @@ func (h *CertificateHandler) GetAllCertificates(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":      true,
 		"certificates": certificates,
+		"pagination":   page,
 	})
 }
 
-// GetPendingCertificates returns all pending certificates (admin only)
+// GetPendingCertificates returns a page of pending certificates (admin only)
 func (h *CertificateHandler) GetPendingCertificates(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from context
-	userID, err := middleware.GetUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Failed to get user ID", http.StatusInternalServerError)
-		return
-	}
-
-	// Check if user is admin
-	user, err := models.GetUserByID(h.db, userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	// Check that the user's role grants access to certificates
+	if !middleware.HasPermission(r, "certificates.view") {
+		http.Error(w, "Access denied. Permission certificates.view required.", http.StatusForbidden)
 		return
 	}
 
-	if user.Role != "admin" {
-		http.Error(w, "Access denied. Admin role required.", http.StatusForbidden)
+	list, ok := parseListQuery(w, r, models.PendingCertificateListSpec)
+	if !ok {
 		return
 	}
 
-	certificates, err := models.GetPendingCertificates(h.db)
+	certificates, page, err := models.GetPendingCertificates(h.db, instructorScope(r), list)
 	if err != nil {
 		http.Error(w, "Failed to get pending certificates", http.StatusInternalServerError)
 		return
@@ -206,6 +191,7 @@ func (h *CertificateHandler) GetPendingCertificates(w http.ResponseWriter, r *ht
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":      true,
 		"certificates": certificates,
+		"pagination":   page,
 	})
 }
 
@@ -226,15 +212,24 @@ func (h *CertificateHandler) ApproveCertificate(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Check if user is admin
-	user, err := models.GetUserByID(h.db, userID)
+	// Check that the user's role grants certificate approval
+	if !middleware.HasPermission(r, "certificates.approve") {
+		http.Error(w, "Access denied. Permission certificates.approve required.", http.StatusForbidden)
+		return
+	}
+
+	// Course-scoped roles may only decide on certificates for their courses
+	courseID, err := models.GetCertificateCourseID(h.db, certID)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Certificate not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get certificate", http.StatusInternalServerError)
 		return
 	}
 
-	if user.Role != "admin" {
-		http.Error(w, "Access denied. Admin role required.", http.StatusForbidden)
+	if !requireCourseAccess(h.db, w, r, courseID) {
 		return
 	}
 
@@ -268,15 +263,24 @@ func (h *CertificateHandler) RejectCertificate(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Check if user is admin
-	user, err := models.GetUserByID(h.db, userID)
+	// Check that the user's role grants certificate approval
+	if !middleware.HasPermission(r, "certificates.approve") {
+		http.Error(w, "Access denied. Permission certificates.approve required.", http.StatusForbidden)
+		return
+	}
+
+	// Course-scoped roles may only decide on certificates for their courses
+	courseID, err := models.GetCertificateCourseID(h.db, certID)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Certificate not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get certificate", http.StatusInternalServerError)
 		return
 	}
 
-	if user.Role != "admin" {
-		http.Error(w, "Access denied. Admin role required.", http.StatusForbidden)
+	if !requireCourseAccess(h.db, w, r, courseID) {
 		return
 	}
 
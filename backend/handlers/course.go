@@ -3,12 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"lms-backend/middleware"
 	"lms-backend/models"
+	"lms-backend/xapi"
 
 	"github.com/gorilla/mux"
 )
@@ -22,7 +25,7 @@ func NewCourseHandler(db *sql.DB) *CourseHandler {
 	return &CourseHandler{DB: db}
 }
 
-// GetAllCourses returns all courses (public endpoint)
+// GetAllCourses returns the published courses (public endpoint)
 func (h *CourseHandler) GetAllCourses(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -75,6 +78,69 @@ func (h *CourseHandler) GetCourseByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	course, err := models.GetCourseByID(h.DB, id)
+	// Drafts and scheduled courses are hidden; admins preview them through the admin API
+	if err == nil && !course.IsReleased(time.Now()) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Course not found",
+				Message: "The requested course does not exist",
+			})
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Failed to fetch course",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Data:    course,
+	})
+}
+
+// GetEnrolledCourse returns a course for the authenticated user. Enrolled learners
+// get the content of the revision they enrolled in, so later edits don't change a
+// course under them; everyone else gets the current content of a released course.
+func (h *CourseHandler) GetEnrolledCourse(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid course ID",
+			Message: "Course ID must be a number",
+		})
+		return
+	}
+
+	course, err := models.GetCourseByID(h.DB, id)
+	var revision *models.CourseRevision
+	if err == nil {
+		revision, err = models.GetEnrollmentRevision(h.DB, userID, id)
+		if err == sql.ErrNoRows {
+			revision, err = nil, nil
+		}
+	}
+	if err == nil && revision == nil && !course.IsReleased(time.Now()) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			w.WriteHeader(http.StatusNotFound)
@@ -92,6 +158,20 @@ func (h *CourseHandler) GetCourseByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if revision != nil {
+		var snapshot models.CourseSnapshot
+		if err := json.Unmarshal(revision.Content, &snapshot); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Failed to read course revision",
+				Message: err.Error(),
+			})
+			return
+		}
+		snapshot.ApplyTo(course)
+		course.RevisionID = &revision.ID
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(SuccessResponse{
 		Success: true,
@@ -150,6 +230,19 @@ func (h *CourseHandler) EnrollInCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Enforce the email verification policy
+	if EmailVerificationRequired() {
+		user, err := middleware.GetUserFromContext(r)
+		if err == nil && !user.IsEmailVerified() {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Email not verified",
+				Message: "Silakan verifikasi email Anda sebelum mendaftar kursus",
+			})
+			return
+		}
+	}
+
 	var req struct {
 		CourseID int `json:"courseId"`
 	}
@@ -172,8 +265,11 @@ func (h *CourseHandler) EnrollInCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if course exists
+	// Check if course exists and is open for enrollment
 	course, err := models.GetCourseByID(h.DB, req.CourseID)
+	if err == nil && !course.IsVisible(time.Now()) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			w.WriteHeader(http.StatusNotFound)
@@ -213,6 +309,11 @@ func (h *CourseHandler) EnrollInCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordStatement(h.DB, userID, xapi.Statement{
+		Verb:   xapi.VerbRegistered,
+		Object: courseActivity(req.CourseID, course.Title),
+	})
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(SuccessResponse{
 		Success: true,
@@ -245,7 +346,8 @@ func (h *CourseHandler) GetUserEnrollments(w http.ResponseWriter, r *http.Reques
 	// Get enrolled courses with enrollment info and actual progress from course_progress table
 	query := `
 		SELECT c.id, c.title, c.description, c.category, c.level, c.duration,
-		       c.instructor, c.rating, c.students, c.image,
+		       ` + models.InstructorNamesSQL("c.id", "c.instructor") + ` AS instructor,
+		       c.rating, c.students, c.image,
 		       ce.enrolled_at, ce.completed_at,
 		       COALESCE(cp.overall_progress, 0) as progress,
 		       COALESCE(cp.lessons_completed, 0) as lessons_completed,
@@ -327,42 +429,58 @@ func (h *CourseHandler) GetUserEnrollments(w http.ResponseWriter, r *http.Reques
 	})
 }
 
-// SearchCourses searches courses by title, description, or category
+// SearchCourses runs a full-text search over the visible courses' title,
+// description, category, instructors and lesson text. Results are ranked, have
+// the matched words highlighted, can be filtered by category, level, duration
+// and minimum rating, and are paged with the nextCursor of the previous page.
 func (h *CourseHandler) SearchCourses(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	query := r.URL.Query().Get("q")
-	if query == "" {
+	query := r.URL.Query()
+	params := models.CourseSearchParams{
+		Query:      strings.TrimSpace(query.Get("q")),
+		Categories: splitSearchFilter(query["category"]),
+		Levels:     splitSearchFilter(query["level"]),
+		Durations:  splitSearchFilter(query["duration"]),
+		Cursor:     query.Get("cursor"),
+	}
+	if value := query.Get("minRating"); value != "" {
+		rating, err := strconv.ParseFloat(value, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Invalid minRating",
+				Message: "minRating must be a number between 0 and 5",
+			})
+			return
+		}
+		params.MinRating = &rating
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > models.MaxCourseSearchLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Invalid limit",
+				Message: fmt.Sprintf("limit must be between 1 and %d", models.MaxCourseSearchLimit),
+			})
+			return
+		}
+		params.Limit = limit
+	}
+
+	courses, nextCursor, err := models.SearchCourses(h.DB, params)
+	if err == models.ErrInvalidSearchCursor {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "Missing search query",
-			Message: "Search query parameter 'q' is required",
+			Error:   "Invalid cursor",
+			Message: "cursor must be the nextCursor of a previous search",
 		})
 		return
 	}
-
-	// Search in title, description, and category
-	searchQuery := `
-		SELECT id, title, description, category, level, duration, instructor,
-		       rating, students, image, intro_material, lessons, pre_test,
-		       post_test, post_work, final_project, created_at, updated_at
-		FROM courses
-		WHERE LOWER(title) LIKE LOWER($1)
-		   OR LOWER(description) LIKE LOWER($1)
-		   OR LOWER(category) LIKE LOWER($1)
-		   OR LOWER(instructor) LIKE LOWER($1)
-		ORDER BY 
-			CASE WHEN LOWER(title) LIKE LOWER($1) THEN 1
-			     WHEN LOWER(description) LIKE LOWER($1) THEN 2
-			     ELSE 3 END,
-			created_at DESC
-	`
-
-	searchTerm := "%" + strings.ToLower(query) + "%"
-	rows, err := h.DB.Query(searchQuery, searchTerm)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(ErrorResponse{
@@ -371,37 +489,30 @@ func (h *CourseHandler) SearchCourses(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	defer rows.Close()
-
-	var courses []models.Course
-	for rows.Next() {
-		var course models.Course
-		err := rows.Scan(
-			&course.ID, &course.Title, &course.Description, &course.Category,
-			&course.Level, &course.Duration, &course.Instructor, &course.Rating,
-			&course.Students, &course.Image, &course.IntroMaterial, &course.Lessons,
-			&course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
-			&course.CreatedAt, &course.UpdatedAt,
-		)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(ErrorResponse{
-				Error:   "Failed to scan course",
-				Message: err.Error(),
-			})
-			return
-		}
-		courses = append(courses, course)
-	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(SuccessResponse{
 		Success: true,
 		Message: "Search completed",
 		Data: map[string]interface{}{
-			"query":   query,
-			"results": len(courses),
-			"courses": courses,
+			"query":      params.Query,
+			"results":    len(courses),
+			"courses":    courses,
+			"nextCursor": nextCursor,
 		},
 	})
+}
+
+// splitSearchFilter accepts a filter as repeated parameters and as a
+// comma-separated list
+func splitSearchFilter(values []string) []string {
+	var filter []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				filter = append(filter, item)
+			}
+		}
+	}
+	return filter
 }
\ No newline at end of file
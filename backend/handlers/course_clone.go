@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CloneCourse copies a course, with its lessons, modules, quizzes, stage locks and
+// configuration, into a new draft (admin only). The optional body sets the new
+// title and whether the copy is a template.
+func (h *AdminHandler) CloneCourse(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CloneCourseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// SCORM packages are copied to new directories, removed again when the
+	// clone fails
+	var scormCopies []string
+	copyScorm := func(storagePath string) (string, error) {
+		target := filepath.Join("./uploads", "scorm", uuid.New().String())
+		scormCopies = append(scormCopies, target)
+		return target, copyScormStorage(storagePath, target)
+	}
+
+	authorID, _ := middleware.GetUserIDFromContext(r)
+	courseID, err := models.CloneCourse(h.db, sourceID, req, authorID, copyScorm)
+	if err != nil {
+		for _, dir := range scormCopies {
+			os.RemoveAll(dir)
+		}
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error cloning course %d: %v", sourceID, err)
+		http.Error(w, "Failed to clone course", http.StatusInternalServerError)
+		return
+	}
+
+	course, err := models.GetCourseByID(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting cloned course %d: %v", courseID, err)
+		http.Error(w, "Failed to get cloned course", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Course cloned successfully",
+		"course":  course,
+	})
+}
+
+// SetCourseTemplate marks a course as a template or a regular course (admin only)
+func (h *AdminHandler) SetCourseTemplate(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		IsTemplate *bool `json:"isTemplate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsTemplate == nil {
+		http.Error(w, "isTemplate is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`UPDATE courses SET is_template = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, *req.IsTemplate, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error updating template flag of course %d: %v", courseID, err)
+		http.Error(w, "Failed to update course", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    "Course updated successfully",
+		"isTemplate": *req.IsTemplate,
+	})
+}
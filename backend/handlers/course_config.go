@@ -30,15 +30,9 @@ type CourseConfigResponse struct {
 // UpdateCourseConfigHandler handles updating course configuration
 func UpdateCourseConfigHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if user is admin
-		user, err := middleware.GetUserFromContext(r)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		if user.Role != "admin" {
-			http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
+		// Check if user can edit courses
+		if !middleware.HasPermission(r, "courses.edit") {
+			http.Error(w, "Forbidden: Permission courses.edit required", http.StatusForbidden)
 			return
 		}
 
@@ -51,6 +45,10 @@ func UpdateCourseConfigHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if !requireCourseAccess(db, w, r, courseID) {
+			return
+		}
+
 		// Parse request body
 		var req CourseConfigRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -85,15 +83,9 @@ func UpdateCourseConfigHandler(db *sql.DB) http.HandlerFunc {
 // GetCourseConfigHandler handles getting course configuration
 func GetCourseConfigHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if user is admin
-		user, err := middleware.GetUserFromContext(r)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		if user.Role != "admin" {
-			http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
+		// Check if user can edit courses
+		if !middleware.HasPermission(r, "courses.edit") {
+			http.Error(w, "Forbidden: Permission courses.edit required", http.StatusForbidden)
 			return
 		}
 
@@ -106,6 +98,10 @@ func GetCourseConfigHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if !requireCourseAccess(db, w, r, courseID) {
+			return
+		}
+
 		// Get course configuration
 		hasPostWork, hasFinalProject, certificateDelay, stepWeights, err := models.GetCourseConfiguration(db, courseID)
 		if err != nil {
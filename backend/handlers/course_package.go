@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxCoursePackageSize limits an imported package, files included
+const maxCoursePackageSize = 100 << 20
+
+// Zip package layout: the manifest and content as JSON, files under files/
+const (
+	packageManifestPath = "manifest.json"
+	packageContentPath  = "course.json"
+)
+
+// ExportCoursePackage downloads a course with its lessons, modules, quizzes,
+// stage locks, configuration and referenced uploaded files (admin only). The
+// default is a zip archive; ?format=json returns a single JSON bundle with the
+// files base64 encoded.
+func (h *AdminHandler) ExportCoursePackage(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "zip" && format != "json" {
+		http.Error(w, "format must be zip or json", http.StatusBadRequest)
+		return
+	}
+
+	pkg, err := models.ExportCoursePackage(h.db, courseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrScormNotPackaged) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error exporting course %d: %v", courseID, err)
+		http.Error(w, "Failed to export course", http.StatusInternalServerError)
+		return
+	}
+
+	var body bytes.Buffer
+	if format == "json" {
+		for i := range pkg.Manifest.Files {
+			file := &pkg.Manifest.Files[i]
+			if file.Data, err = os.ReadFile(file.FilePath); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = json.NewEncoder(&body).Encode(pkg)
+		}
+	} else {
+		err = writeCoursePackageZip(&body, pkg)
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error writing package of course %d: %v", courseID, err)
+		http.Error(w, "Failed to export course", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"course-%d.json\"", courseID))
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"course-%d.zip\"", courseID))
+	}
+	w.Write(body.Bytes())
+}
+
+// ImportCoursePackage recreates an exported course as a new draft with new IDs
+// (admin only). It accepts a zip or JSON package as the "package" field of a
+// multipart form, or a JSON bundle as the request body.
+func (h *AdminHandler) ImportCoursePackage(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCoursePackageSize)
+	var data []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("package")
+		if err != nil {
+			http.Error(w, "Failed to get package from form", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		http.Error(w, "Failed to read package (max 100MB)", http.StatusBadRequest)
+		return
+	}
+
+	pkg, err := readCoursePackage(data)
+	if err == nil {
+		err = pkg.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	files, err := saveCoursePackageFiles(pkg, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCoursePackage) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error saving course package files: %v", err)
+		http.Error(w, "Failed to save package files", http.StatusInternalServerError)
+		return
+	}
+
+	courseID, err := models.ImportCoursePackage(h.db, pkg, files, userID)
+	if err != nil {
+		removeCoursePackageFiles(files)
+		if errors.Is(err, models.ErrInvalidCoursePackage) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error importing course package: %v", err)
+		http.Error(w, "Failed to import course", http.StatusInternalServerError)
+		return
+	}
+
+	course, err := models.GetCourseByID(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting imported course %d: %v", courseID, err)
+		http.Error(w, "Failed to get imported course", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Course imported successfully",
+		"course":  course,
+	})
+}
+
+// writeCoursePackageZip writes a package as a zip archive
+func writeCoursePackageZip(out io.Writer, pkg *models.CoursePackage) error {
+	archive := zip.NewWriter(out)
+
+	writeJSON := func(name string, value interface{}) error {
+		entry, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	if err := writeJSON(packageManifestPath, pkg.Manifest); err != nil {
+		return err
+	}
+	if err := writeJSON(packageContentPath, pkg.Content); err != nil {
+		return err
+	}
+
+	for _, file := range pkg.Manifest.Files {
+		entry, err := archive.Create(file.Path)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(file.FilePath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(entry, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// readCoursePackage parses a zip package or a JSON bundle. Files of a zip
+// package are read into the manifest so both kinds carry them in Data.
+func readCoursePackage(data []byte) (*models.CoursePackage, error) {
+	var pkg models.CoursePackage
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return nil, fmt.Errorf("%w: not a zip archive or JSON bundle", models.ErrInvalidCoursePackage)
+		}
+		return &pkg, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidCoursePackage, err)
+	}
+	entries := make(map[string]*zip.File, len(archive.File))
+	for _, entry := range archive.File {
+		entries[entry.Name] = entry
+	}
+
+	// The size limit applies to the decompressed entries together, so a small
+	// archive cannot expand past it
+	var total int64
+	readEntry := func(name string) ([]byte, error) {
+		entry, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is missing", models.ErrInvalidCoursePackage, name)
+		}
+		src, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", models.ErrInvalidCoursePackage, name, err)
+		}
+		defer src.Close()
+		content, err := io.ReadAll(io.LimitReader(src, maxCoursePackageSize-total+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", models.ErrInvalidCoursePackage, name, err)
+		}
+		total += int64(len(content))
+		if total > maxCoursePackageSize {
+			return nil, fmt.Errorf("%w: package is too large when extracted", models.ErrInvalidCoursePackage)
+		}
+		return content, nil
+	}
+
+	manifest, err := readEntry(packageManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifest, &pkg.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", models.ErrInvalidCoursePackage, packageManifestPath, err)
+	}
+	content, err := readEntry(packageContentPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &pkg.Content); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", models.ErrInvalidCoursePackage, packageContentPath, err)
+	}
+
+	paths := make(map[string]bool, len(pkg.Manifest.Files))
+	for i := range pkg.Manifest.Files {
+		file := &pkg.Manifest.Files[i]
+		if paths[file.Path] {
+			return nil, fmt.Errorf("%w: duplicate file path %s", models.ErrInvalidCoursePackage, file.Path)
+		}
+		paths[file.Path] = true
+		if file.Data, err = readEntry(file.Path); err != nil {
+			return nil, err
+		}
+	}
+	return &pkg, nil
+}
+
+// saveCoursePackageFiles writes the files of a package to the uploads
+// directory under new names. Only file types accepted by uploads are saved.
+func saveCoursePackageFiles(pkg *models.CoursePackage, userID int) ([]models.ImportedFile, error) {
+	uploadsDir := "./uploads"
+	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
+		return nil, err
+	}
+
+	files := make([]models.ImportedFile, 0, len(pkg.Manifest.Files))
+	for _, file := range pkg.Manifest.Files {
+		fileType := getFileType(file.OriginalName)
+		if fileType == "" {
+			removeCoursePackageFiles(files)
+			return nil, fmt.Errorf("%w: file %d has an unsupported type", models.ErrInvalidCoursePackage, file.ID)
+		}
+		if file.Data == nil && file.Size > 0 {
+			removeCoursePackageFiles(files)
+			return nil, fmt.Errorf("%w: file %d is missing from the package", models.ErrInvalidCoursePackage, file.ID)
+		}
+
+		newFilename := fmt.Sprintf("%d_%s%s", userID, uuid.New().String(), filepath.Ext(file.OriginalName))
+		filePath := filepath.Join(uploadsDir, newFilename)
+		if err := os.WriteFile(filePath, file.Data, 0644); err != nil {
+			removeCoursePackageFiles(files)
+			return nil, err
+		}
+
+		files = append(files, models.ImportedFile{
+			PackageID:    file.ID,
+			FileName:     newFilename,
+			OriginalName: file.OriginalName,
+			FilePath:     filePath,
+			Size:         int64(len(file.Data)),
+			MimeType:     file.MimeType,
+			FileType:     fileType,
+		})
+	}
+	return files, nil
+}
+
+// removeCoursePackageFiles deletes saved package files after a failed import
+func removeCoursePackageFiles(files []models.ImportedFile) {
+	for _, file := range files {
+		if err := os.Remove(file.FilePath); err != nil {
+			log.Printf("[ADMIN ERROR] Failed to remove imported file %s: %v", file.FilePath, err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Course Revisions
+
+// revisionRouteIDs parses the course and, when present, revision ID of a revision
+// route and checks that the caller may manage the course
+func (h *AdminHandler) revisionRouteIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	courseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	revisionID := 0
+	if value, ok := vars["revisionId"]; ok {
+		revisionID, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid revision ID", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return 0, 0, false
+	}
+	return courseID, revisionID, true
+}
+
+// recordCourseRevision snapshots a course saved in tx as a new revision by the caller
+func (h *AdminHandler) recordCourseRevision(w http.ResponseWriter, r *http.Request, tx *sql.Tx, courseID int) bool {
+	authorID, _ := middleware.GetUserIDFromContext(r)
+	if _, err := models.CreateCourseRevision(tx, courseID, authorID, ""); err != nil {
+		log.Printf("[ADMIN ERROR] Failed to record revision of course %d: %v", courseID, err)
+		http.Error(w, "Failed to record course revision", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// commitLessonChange records the revision of a course whose lessons changed in
+// tx and commits both together; the lesson change locked the course, so the
+// revision sees exactly that change
+func (h *AdminHandler) commitLessonChange(w http.ResponseWriter, r *http.Request, tx *sql.Tx, courseID int, message string) bool {
+	if !h.recordCourseRevision(w, r, tx, courseID) {
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ADMIN ERROR] %s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// GetCourseRevisions lists the revisions of a course, newest first
+func (h *AdminHandler) GetCourseRevisions(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.revisionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := models.GetCourseRevisions(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting revisions for course %d: %v", courseID, err)
+		http.Error(w, "Failed to get revisions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"revisions": revisions,
+	})
+}
+
+// GetCourseRevision gets a revision with its content
+func (h *AdminHandler) GetCourseRevision(w http.ResponseWriter, r *http.Request) {
+	courseID, revisionID, ok := h.revisionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	revision, err := models.GetCourseRevision(h.db, courseID, revisionID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting revision %d: %v", revisionID, err)
+		http.Error(w, "Failed to get revision", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"revision": revision,
+	})
+}
+
+// DiffCourseRevision lists the changes a revision made, compared with the revision
+// before it or with the revision given as ?against=<revisionId>
+func (h *AdminHandler) DiffCourseRevision(w http.ResponseWriter, r *http.Request) {
+	courseID, revisionID, ok := h.revisionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	revision, err := models.GetCourseRevision(h.db, courseID, revisionID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting revision %d: %v", revisionID, err)
+		http.Error(w, "Failed to get revision", http.StatusInternalServerError)
+		return
+	}
+
+	var againstID int
+	if value := r.URL.Query().Get("against"); value != "" {
+		againstID, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid revision ID in against", http.StatusBadRequest)
+			return
+		}
+	} else {
+		againstID, err = models.GetPreviousRevisionID(h.db, courseID, revision.RevisionNumber)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("[ADMIN ERROR] Error getting revision before %d: %v", revisionID, err)
+			http.Error(w, "Failed to get revision", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// The first revision is compared with an empty course
+	base := &models.CourseRevision{Content: json.RawMessage("{}")}
+	if againstID != 0 {
+		base, err = models.GetCourseRevision(h.db, courseID, againstID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Revision to compare against not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[ADMIN ERROR] Error getting revision %d: %v", againstID, err)
+			http.Error(w, "Failed to get revision", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	changes, err := models.DiffCourseRevisions(base.Content, revision.Content)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error comparing revisions %d and %d: %v", againstID, revisionID, err)
+		http.Error(w, "Failed to compare revisions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"from":    base.RevisionNumber,
+		"to":      revision.RevisionNumber,
+		"changes": changes,
+	})
+}
+
+// RestoreCourseRevision writes a revision's content back to the course as a new revision
+func (h *AdminHandler) RestoreCourseRevision(w http.ResponseWriter, r *http.Request) {
+	courseID, revisionID, ok := h.revisionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	revision, err := models.GetCourseRevision(h.db, courseID, revisionID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting revision %d: %v", revisionID, err)
+		http.Error(w, "Failed to get revision", http.StatusInternalServerError)
+		return
+	}
+
+	authorID, _ := middleware.GetUserIDFromContext(r)
+	restored, err := models.RestoreCourseRevision(h.db, courseID, revision, authorID)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidLessons) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error restoring revision %d of course %d: %v", revisionID, courseID, err)
+		http.Error(w, "Failed to restore revision", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Revision restored successfully",
+		"revision": restored,
+	})
+}
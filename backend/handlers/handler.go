@@ -22,4 +22,14 @@ func NewQuizHandler(db *sql.DB) *Handler {
 // NewSubmissionHandler creates a new submission handler
 func NewSubmissionHandler(db *sql.DB) *Handler {
 	return &Handler{DB: db}
-}
\ No newline at end of file
+}
+
+// NewScormHandler creates a new SCORM lesson handler
+func NewScormHandler(db *sql.DB) *Handler {
+	return &Handler{DB: db}
+}
+
+// NewXAPIHandler creates a new xAPI statements handler
+func NewXAPIHandler(db *sql.DB) *Handler {
+	return &Handler{DB: db}
+}
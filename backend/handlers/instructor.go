@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// InstructorHandler serves data for the courses a user is assigned to as instructor
+type InstructorHandler struct {
+	db *sql.DB
+}
+
+func NewInstructorHandler(db *sql.DB) *InstructorHandler {
+	return &InstructorHandler{db: db}
+}
+
+// GetMyCourses gets the courses the current user is assigned to
+func (h *InstructorHandler) GetMyCourses(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	courses, err := models.GetInstructorCourses(h.db, userID)
+	if err != nil {
+		log.Printf("[INSTRUCTOR ERROR] Error getting courses for user %d: %v", userID, err)
+		http.Error(w, "Failed to get courses", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    courses,
+	})
+}
+
+// GetCourseSubmissions gets all submissions for a course the current user is assigned to
+func (h *InstructorHandler) GetCourseSubmissions(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["courseId"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireCourseAssignment(h.db, w, r, courseID) {
+		return
+	}
+
+	submissions, err := models.GetCourseSubmissionsWithGrades(h.db, courseID)
+	if err != nil {
+		http.Error(w, "Failed to get submissions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"submissions": submissions,
+	})
+}
+
+// GetTestResults gets pre test and post test results for the current user's courses
+func (h *InstructorHandler) GetTestResults(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	list, ok := parseListQuery(w, r, models.TestResultListSpec)
+	if !ok {
+		return
+	}
+
+	results, page, err := models.GetTestResults(h.db, userID, list)
+	if err != nil {
+		log.Printf("[INSTRUCTOR ERROR] Error querying test results for user %d: %v", userID, err)
+		http.Error(w, "Failed to get test results", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       results,
+		"pagination": page,
+	})
+}
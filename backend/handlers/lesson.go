@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lms-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Lesson Management
+
+// lessonRouteIDs parses the course and, when present, lesson ID of a lesson route
+// and checks that the caller may manage the course
+func (h *AdminHandler) lessonRouteIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	courseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	lessonID := 0
+	if value, ok := vars["lessonId"]; ok {
+		lessonID, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid lesson ID", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return 0, 0, false
+	}
+	return courseID, lessonID, true
+}
+
+// GetCourseLessons gets the lessons of a course in order
+func (h *AdminHandler) GetCourseLessons(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	lessons, err := models.GetCourseLessons(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting lessons for course %d: %v", courseID, err)
+		http.Error(w, "Failed to get lessons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"lessons": lessons,
+	})
+}
+
+// GetCourseLesson gets a single lesson of a course
+func (h *AdminHandler) GetCourseLesson(w http.ResponseWriter, r *http.Request) {
+	courseID, lessonID, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	lesson, err := models.GetLesson(h.db, courseID, lessonID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Lesson not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting lesson %d: %v", lessonID, err)
+		http.Error(w, "Failed to get lesson", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"lesson":  lesson,
+	})
+}
+
+// CreateLesson adds a lesson to a course
+func (h *AdminHandler) CreateLesson(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.LessonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Title == nil || *req.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to create lesson", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	lesson, err := models.CreateLesson(tx, courseID, req)
+	if err != nil {
+		h.writeLessonError(w, err, "Course not found", "Failed to create lesson")
+		return
+	}
+	if !h.commitLessonChange(w, r, tx, courseID, "Failed to create lesson") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Lesson created successfully",
+		"lesson":  lesson,
+	})
+}
+
+// UpdateLesson updates the provided fields of a lesson
+func (h *AdminHandler) UpdateLesson(w http.ResponseWriter, r *http.Request) {
+	courseID, lessonID, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.LessonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Title != nil && *req.Title == "" {
+		http.Error(w, "Title cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to update lesson", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	lesson, err := models.UpdateLesson(tx, courseID, lessonID, req)
+	if err != nil {
+		h.writeLessonError(w, err, "Lesson not found", "Failed to update lesson")
+		return
+	}
+	if !h.commitLessonChange(w, r, tx, courseID, "Failed to update lesson") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Lesson updated successfully",
+		"lesson":  lesson,
+	})
+}
+
+// DeleteLesson removes a lesson together with the progress recorded on it
+func (h *AdminHandler) DeleteLesson(w http.ResponseWriter, r *http.Request) {
+	courseID, lessonID, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to delete lesson", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.DeleteLesson(tx, courseID, lessonID); err != nil {
+		h.writeLessonError(w, err, "Lesson not found", "Failed to delete lesson")
+		return
+	}
+	if !h.commitLessonChange(w, r, tx, courseID, "Failed to delete lesson") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Lesson deleted successfully",
+	})
+}
+
+// ReorderLessons sets the order of a course's lessons
+func (h *AdminHandler) ReorderLessons(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.LessonOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to reorder lessons", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.ReorderLessons(tx, courseID, req.LessonIDs); err != nil {
+		h.writeLessonError(w, err, "Course not found", "Failed to reorder lessons")
+		return
+	}
+	if !h.commitLessonChange(w, r, tx, courseID, "Failed to reorder lessons") {
+		return
+	}
+
+	lessons, err := models.GetCourseLessons(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting lessons for course %d: %v", courseID, err)
+		http.Error(w, "Failed to get lessons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Lessons reordered successfully",
+		"lessons": lessons,
+	})
+}
+
+// writeLessonError maps lesson model errors to responses
+func (h *AdminHandler) writeLessonError(w http.ResponseWriter, err error, notFound, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidLessons), err == models.ErrLessonOrderMismatch, err == models.ErrModuleNotInCourse:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("[ADMIN ERROR] %s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"lms-backend/models"
+)
+
+// parseListQuery reads the paging, sort and filter parameters of a list
+// endpoint, writing a 400 and returning false when they are invalid
+func parseListQuery(w http.ResponseWriter, r *http.Request, spec models.ListSpec) (*models.ListQuery, bool) {
+	list, err := models.ParseListQuery(r.URL.Query(), spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return list, true
+}
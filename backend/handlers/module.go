@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lms-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Module Management
+
+// moduleRouteIDs parses the course and, when present, module ID of a module route
+// and checks that the caller may manage the course
+func (h *AdminHandler) moduleRouteIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	courseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	moduleID := 0
+	if value, ok := vars["moduleId"]; ok {
+		moduleID, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid module ID", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return 0, 0, false
+	}
+	return courseID, moduleID, true
+}
+
+// GetCourseModules gets the modules of a course in order
+func (h *AdminHandler) GetCourseModules(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.moduleRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	modules, err := models.GetCourseModules(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting modules for course %d: %v", courseID, err)
+		http.Error(w, "Failed to get modules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"modules": modules,
+	})
+}
+
+// CreateModule adds a module to a course
+func (h *AdminHandler) CreateModule(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.moduleRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ModuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Title == nil || *req.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	module, err := models.CreateModule(h.db, courseID, req)
+	if err != nil {
+		h.writeModuleError(w, err, "Course not found", "Failed to create module")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Module created successfully",
+		"module":  module,
+	})
+}
+
+// UpdateModule updates the provided fields of a module
+func (h *AdminHandler) UpdateModule(w http.ResponseWriter, r *http.Request) {
+	courseID, moduleID, ok := h.moduleRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ModuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Title != nil && *req.Title == "" {
+		http.Error(w, "Title cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	module, err := models.UpdateModule(h.db, courseID, moduleID, req)
+	if err != nil {
+		h.writeModuleError(w, err, "Module not found", "Failed to update module")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Module updated successfully",
+		"module":  module,
+	})
+}
+
+// DeleteModule removes a module; its lessons and quizzes stay in the course
+func (h *AdminHandler) DeleteModule(w http.ResponseWriter, r *http.Request) {
+	courseID, moduleID, ok := h.moduleRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteModule(h.db, courseID, moduleID); err != nil {
+		h.writeModuleError(w, err, "Module not found", "Failed to delete module")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Module deleted successfully",
+	})
+}
+
+// ReorderModules sets the order of a course's modules
+func (h *AdminHandler) ReorderModules(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.moduleRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ModuleOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.ReorderModules(h.db, courseID, req.ModuleIDs); err != nil {
+		h.writeModuleError(w, err, "Course not found", "Failed to reorder modules")
+		return
+	}
+
+	modules, err := models.GetCourseModules(h.db, courseID)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting modules for course %d: %v", courseID, err)
+		http.Error(w, "Failed to get modules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Modules reordered successfully",
+		"modules": modules,
+	})
+}
+
+// writeModuleError maps module model errors to responses
+func (h *AdminHandler) writeModuleError(w http.ResponseWriter, err error, notFound, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case err == models.ErrModuleOrderMismatch:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("[ADMIN ERROR] %s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
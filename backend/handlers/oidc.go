@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"lms-backend/models"
+	"lms-backend/oidc"
+)
+
+// oidcLoginStateTTL bounds how long a user may take at the identity provider
+const oidcLoginStateTTL = "10m"
+
+// oidcStateCookie ties a login state to the browser that started the login,
+// so a callback carrying someone else's state is refused (login CSRF)
+const oidcStateCookie = "oidc_login_state"
+
+// oidcStateCookiePath limits the state cookie to the single sign-on endpoints
+const oidcStateCookiePath = "/api/public/sso/oidc"
+
+// errOIDCLoginRejected marks SSO failures whose message can be shown to the user
+var errOIDCLoginRejected = errors.New("single sign-on rejected")
+
+// OIDCLogin starts single sign-on by redirecting to the identity provider
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Single sign-on is not configured",
+			Message: "Set OIDC_ISSUER_URL and OIDC_CLIENT_ID to enable single sign-on",
+		})
+		return
+	}
+
+	if err := h.startOIDCLogin(w, r); err != nil {
+		log.Printf("Error starting OIDC login: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to start single sign-on",
+			Message: "The identity provider is not reachable, please try again later",
+		})
+	}
+}
+
+// startOIDCLogin stores a fresh state, nonce and PKCE verifier and redirects to the provider
+func (h *AuthHandler) startOIDCLogin(w http.ResponseWriter, r *http.Request) error {
+	var secrets [3]string
+	for i := range secrets {
+		value, err := oidc.RandomString(32)
+		if err != nil {
+			return err
+		}
+		secrets[i] = value
+	}
+	state, nonce, codeVerifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := h.OIDC.AuthCodeURL(r.Context(), state, nonce, oidc.CodeChallengeS256(codeVerifier))
+	if err != nil {
+		return err
+	}
+
+	ttl, err := durationFromEnv("OIDC_LOGIN_STATE_EXPIRY", oidcLoginStateTTL)
+	if err != nil {
+		return err
+	}
+
+	if err := models.CreateOIDCLoginState(h.DB, state, nonce, codeVerifier, ttl); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    oidcStateHash(state),
+		Path:     oidcStateCookiePath,
+		Expires:  time.Now().Add(ttl),
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+	return nil
+}
+
+// OIDCCallback completes single sign-on. It exchanges the authorization code,
+// signs the user in and redirects to the web app with the session tokens in the
+// URL fragment.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error: "Single sign-on is not configured",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		log.Printf("OIDC provider returned error: %s %s", providerError, query.Get("error_description"))
+		redirectOIDCResult(w, r, url.Values{"error": {"Single sign-on was cancelled or denied"}})
+		return
+	}
+
+	// The state must come back to the browser that started the login
+	cookie, err := r.Cookie(oidcStateCookie)
+	clearOIDCStateCookie(w)
+	if err != nil || query.Get("state") == "" || cookie.Value != oidcStateHash(query.Get("state")) {
+		redirectOIDCResult(w, r, url.Values{"error": {"Single sign-on session expired, please try again"}})
+		return
+	}
+
+	nonce, codeVerifier, err := models.ConsumeOIDCLoginState(h.DB, query.Get("state"))
+	if err != nil {
+		if err != models.ErrOIDCStateInvalid {
+			log.Printf("Error reading OIDC login state: %v", err)
+		}
+		redirectOIDCResult(w, r, url.Values{"error": {"Single sign-on session expired, please try again"}})
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(r.Context(), query.Get("code"), codeVerifier, nonce)
+	if err != nil {
+		log.Printf("Error completing OIDC login: %v", err)
+		redirectOIDCResult(w, r, url.Values{"error": {"Single sign-on failed"}})
+		return
+	}
+
+	user, err := h.resolveOIDCUser(claims)
+	if err != nil {
+		message := "Single sign-on failed"
+		if errors.Is(err, errOIDCLoginRejected) {
+			message = strings.TrimPrefix(err.Error(), errOIDCLoginRejected.Error()+": ")
+		}
+		log.Printf("Error resolving OIDC user %s/%s: %v", claims.Issuer, claims.Subject, err)
+		redirectOIDCResult(w, r, url.Values{"error": {message}})
+		return
+	}
+
+	// Users with 2FA enabled get a challenge to complete at /login/2fa, as
+	// with password login, instead of a session
+	if user.TwoFactorEnabled {
+		challenge, err := h.startTwoFactorChallenge(user)
+		if err != nil {
+			log.Printf("Error starting two-factor login for OIDC user %d: %v", user.ID, err)
+			redirectOIDCResult(w, r, url.Values{"error": {"Single sign-on failed"}})
+			return
+		}
+		redirectOIDCResult(w, r, url.Values{
+			"twoFactorRequired": {"true"},
+			"challengeToken":    {challenge.ChallengeToken},
+			"expiresAt":         {challenge.ExpiresAt.UTC().Format(time.RFC3339)},
+		})
+		return
+	}
+
+	session, err := h.issueSession(r, user)
+	if err != nil {
+		log.Printf("Error issuing session for OIDC user %d: %v", user.ID, err)
+		redirectOIDCResult(w, r, url.Values{"error": {"Single sign-on failed"}})
+		return
+	}
+
+	redirectOIDCResult(w, r, url.Values{
+		"token":        {session.Token},
+		"refreshToken": {session.RefreshToken},
+	})
+}
+
+// resolveOIDCUser finds the user for verified ID token claims, linking an existing
+// account by email or provisioning a new one on first login
+func (h *AuthHandler) resolveOIDCUser(claims *oidc.IDTokenClaims) (*models.User, error) {
+	cfg := h.OIDC.Config()
+
+	if claims.Email == "" {
+		return nil, oidcRejected("the identity provider did not share an email address")
+	}
+	if !cfg.EmailAllowed(claims.Email) {
+		return nil, oidcRejected("this email domain is not allowed to sign in")
+	}
+
+	userID, err := models.GetUserIDByOIDCIdentity(h.DB, claims.Issuer, claims.Subject)
+	if err == nil {
+		if cfg.SyncRoles {
+			h.syncOIDCRole(userID, claims)
+		}
+		return models.GetUserByID(h.DB, userID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Linking or creating an account by email is only safe once the provider vouches for it
+	if !claims.EmailVerified {
+		return nil, oidcRejected("the email address is not verified by the identity provider")
+	}
+
+	user, err := models.GetUserByEmail(h.DB, claims.Email)
+	if err == nil {
+		if err := models.LinkOIDCIdentity(h.DB, user.ID, claims.Issuer, claims.Subject); err != nil {
+			return nil, err
+		}
+		if err := models.MarkEmailVerified(h.DB, user.ID); err != nil {
+			return nil, err
+		}
+		if cfg.SyncRoles {
+			h.syncOIDCRole(user.ID, claims)
+		}
+		return models.GetUserByID(h.DB, user.ID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if !cfg.AutoProvision {
+		return nil, oidcRejected("no account exists for this email address")
+	}
+
+	username := claims.PreferredUsername
+	if username == "" || strings.Contains(username, "@") {
+		username = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	fullName := claims.Name
+	if fullName == "" {
+		fullName = username
+	}
+
+	return models.CreateOIDCUser(h.DB, models.OIDCUserParams{
+		Username: username,
+		Email:    claims.Email,
+		FullName: fullName,
+		Role:     h.oidcRole(claims),
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+	})
+}
+
+// oidcRole maps the role claim to an existing LMS role, falling back to "user"
+func (h *AuthHandler) oidcRole(claims *oidc.IDTokenClaims) string {
+	role := h.OIDC.Config().MapRole(claims.Roles)
+	exists, err := models.RoleExists(h.DB, role)
+	if err != nil || !exists {
+		log.Printf("OIDC role mapping produced unknown role %q, using \"user\"", role)
+		return "user"
+	}
+	return role
+}
+
+func (h *AuthHandler) syncOIDCRole(userID int, claims *oidc.IDTokenClaims) {
+	if err := models.UpdateUserRole(h.DB, userID, h.oidcRole(claims)); err != nil {
+		log.Printf("Error syncing OIDC role for user %d: %v", userID, err)
+	}
+}
+
+func oidcRejected(reason string) error {
+	return fmt.Errorf("%w: %s", errOIDCLoginRejected, reason)
+}
+
+// oidcStateHash is the value of the state cookie for a login state
+func oidcStateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// clearOIDCStateCookie removes the state cookie once the callback used it
+func clearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     oidcStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectOIDCResult sends the browser back to the web app with the result in the
+// URL fragment, which is never sent to servers or logged in access logs
+func redirectOIDCResult(w http.ResponseWriter, r *http.Request, result url.Values) {
+	target := os.Getenv("OIDC_SUCCESS_REDIRECT")
+	if target == "" {
+		target = frontendURL() + "/sso/callback"
+	}
+	http.Redirect(w, r, target+"#"+result.Encode(), http.StatusFound)
+}
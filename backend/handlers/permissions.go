@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+)
+
+// instructorScope returns the caller's user ID when their role is course-scoped,
+// so list queries can be limited to assigned courses, or 0 for global roles
+func instructorScope(r *http.Request) int {
+	access, err := middleware.GetAccessFromContext(r)
+	if err != nil {
+		// -1 never matches an assignment, so an unresolved caller sees nothing
+		return -1
+	}
+	if !access.IsCourseScoped() {
+		return 0
+	}
+	return access.UserID
+}
+
+// requireCourseAccess writes an error and returns false unless the caller may act on courseID
+func requireCourseAccess(db *sql.DB, w http.ResponseWriter, r *http.Request, courseID int) bool {
+	access, err := middleware.GetAccessFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user permissions", http.StatusInternalServerError)
+		return false
+	}
+
+	allowed, err := access.CanAccessCourse(db, courseID)
+	if err != nil {
+		http.Error(w, "Failed to check course access", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		http.Error(w, "You are not assigned to this course", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireCourseAssignment writes an error and returns false unless the caller is
+// assigned to courseID, whatever the scope of their role
+func requireCourseAssignment(db *sql.DB, w http.ResponseWriter, r *http.Request, courseID int) bool {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return false
+	}
+
+	assigned, err := models.IsCourseInstructor(db, userID, courseID)
+	if err != nil {
+		http.Error(w, "Failed to check course assignment", http.StatusInternalServerError)
+		return false
+	}
+	if !assigned {
+		http.Error(w, "You are not assigned to this course", http.StatusForbidden)
+		return false
+	}
+	return true
+}
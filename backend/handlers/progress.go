@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/gorilla/mux"
 	"lms-backend/middleware"
 	"lms-backend/models"
+	"lms-backend/xapi"
 )
 
 // determineCurrentStep calculates the correct current step based on completed steps
@@ -68,12 +70,32 @@ func (h *Handler) UpdateLessonProgressHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Progress can only be recorded against a lesson of the revision the
+	// learner is enrolled in
+	isLesson, err := models.EnrollmentHasLesson(h.DB, userID, req.CourseID, req.LessonID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !isLesson {
+		http.Error(w, "Lesson not found in course", http.StatusNotFound)
+		return
+	}
+
 	// Update lesson progress
+	wasCompleted := lessonCompleted(h.DB, userID, req.CourseID, req.LessonID)
 	err = models.UpdateLessonProgress(h.DB, userID, req.CourseID, req.LessonID, req.Progress, req.TimeSpent, req.Completed)
 	if err != nil {
 		http.Error(w, "Failed to update lesson progress", http.StatusInternalServerError)
 		return
 	}
+	if req.Completed && !wasCompleted {
+		recordLessonCompleted(h.DB, userID, req.CourseID, req.LessonID, req.TimeSpent, nil)
+	}
+
+	if err := models.UpdateModuleProgress(h.DB, userID, req.CourseID); err != nil {
+		log.Printf("[ERROR] UpdateLessonProgress - Failed to update module progress: %v", err)
+	}
 
 	// Note: Course overall progress should only be updated via SyncProgressHandler
 	// which calculates progress based on all 6 steps (intro, pretest, lessons, posttest, postwork, finalproject)
@@ -364,13 +386,21 @@ func (h *Handler) SyncProgressHandler(w http.ResponseWriter, r *http.Request) {
 					timeSpent = int(t)
 				}
 
+				wasCompleted := lessonCompleted(h.DB, userID, req.CourseID, lessonID)
 				err = models.UpdateLessonProgress(h.DB, userID, req.CourseID, lessonID, progress, timeSpent, completed)
 				if err != nil {
 					// Log error but continue with other lessons
 					continue
 				}
+				if completed && !wasCompleted {
+					recordLessonCompleted(h.DB, userID, req.CourseID, lessonID, timeSpent, nil)
+				}
 			}
 		}
+
+		if err := models.UpdateModuleProgress(h.DB, userID, req.CourseID); err != nil {
+			log.Printf("[ERROR] SyncProgress - Failed to update module progress: %v", err)
+		}
 	}
 
 	// Determine the correct current step based on completed steps
@@ -449,6 +479,14 @@ func (h *Handler) SyncProgressHandler(w http.ResponseWriter, r *http.Request) {
         // Log error but don't fail the request
         // Certificate can be requested manually later
       }
+
+      // The first certificate request marks the course as completed
+      completion := true
+      recordStatement(h.DB, userID, xapi.Statement{
+        Verb:   xapi.VerbCompleted,
+        Object: courseActivity(req.CourseID, ""),
+        Result: &xapi.Result{Completion: &completion, Duration: xapi.Duration(req.TotalTimeSpent)},
+      })
     }
   }
 
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Question Bank Management
+
+// bankQuestionRequest creates or replaces a question of a course's bank
+type bankQuestionRequest struct {
+	Topic      string              `json:"topic"`
+	Difficulty string              `json:"difficulty"`
+	Question   models.QuizQuestion `json:"question"`
+}
+
+// questionRouteIDs parses the course and, when present, question ID of a
+// question bank route and checks that the caller may manage the course
+func (h *AdminHandler) questionRouteIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	courseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	questionID := 0
+	if value, ok := vars["questionId"]; ok {
+		questionID, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid question ID", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+
+	if !requireCourseAccess(h.db, w, r, courseID) {
+		return 0, 0, false
+	}
+	return courseID, questionID, true
+}
+
+// GetBankQuestions gets a page of a course's question bank
+func (h *AdminHandler) GetBankQuestions(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.questionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+	list, ok := parseListQuery(w, r, models.BankQuestionListSpec)
+	if !ok {
+		return
+	}
+
+	questions, page, err := models.GetBankQuestions(h.db, courseID, list)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting question bank of course %d: %v", courseID, err)
+		http.Error(w, "Failed to get questions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"questions":  questions,
+		"pagination": page,
+	})
+}
+
+// GetBankQuestion gets a single question of a course's question bank
+func (h *AdminHandler) GetBankQuestion(w http.ResponseWriter, r *http.Request) {
+	courseID, questionID, ok := h.questionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	question, err := models.GetBankQuestion(h.db, courseID, questionID)
+	if err != nil {
+		h.writeQuestionError(w, err, "Failed to get question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"question": question,
+	})
+}
+
+// CreateBankQuestion adds a question to a course's question bank
+func (h *AdminHandler) CreateBankQuestion(w http.ResponseWriter, r *http.Request) {
+	courseID, _, ok := h.questionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req bankQuestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	question := &models.BankQuestion{
+		CourseID:   courseID,
+		Topic:      req.Topic,
+		Difficulty: req.Difficulty,
+		Question:   req.Question,
+		CreatedBy:  &userID,
+	}
+	if err := models.CreateBankQuestion(h.db, question); err != nil {
+		h.writeQuestionError(w, err, "Failed to create question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Question created successfully",
+		"question": question,
+	})
+}
+
+// UpdateBankQuestion replaces a question of a course's question bank
+func (h *AdminHandler) UpdateBankQuestion(w http.ResponseWriter, r *http.Request) {
+	courseID, questionID, ok := h.questionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req bankQuestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	question := &models.BankQuestion{
+		ID:         questionID,
+		CourseID:   courseID,
+		Topic:      req.Topic,
+		Difficulty: req.Difficulty,
+		Question:   req.Question,
+	}
+	if err := models.UpdateBankQuestion(h.db, question); err != nil {
+		h.writeQuestionError(w, err, "Failed to update question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Question updated successfully",
+		"question": question,
+	})
+}
+
+// DeleteBankQuestion removes a question from a course's question bank
+func (h *AdminHandler) DeleteBankQuestion(w http.ResponseWriter, r *http.Request) {
+	courseID, questionID, ok := h.questionRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteBankQuestion(h.db, courseID, questionID); err != nil {
+		h.writeQuestionError(w, err, "Failed to delete question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Question deleted successfully",
+	})
+}
+
+// writeQuestionError maps a question bank error to its HTTP response
+func (h *AdminHandler) writeQuestionError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Question not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidQuestion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrInvalidDrawRules):
+		http.Error(w, "Question is needed by a quiz's draw rules: "+err.Error(), http.StatusConflict)
+	default:
+		log.Printf("[ADMIN ERROR] %s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -43,14 +45,9 @@ func (h *Handler) GetQuizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Hide correct answers and explanations from questions
-	var questions []map[string]interface{}
-	if err := json.Unmarshal(quiz.Questions, &questions); err == nil {
-		for i := range questions {
-			delete(questions[i], "correctAnswer")
-			delete(questions[i], "explanation")
-		}
-		quiz.Questions, _ = json.Marshal(questions)
+	// Hide answer keys and explanations from questions
+	if questions, err := models.PublicQuestionsJSON(quiz.Questions); err == nil {
+		quiz.Questions = questions
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -92,15 +89,10 @@ func (h *Handler) GetQuizzesByCourseHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Hide correct answers and explanations from all quizzes
+	// Hide answer keys and explanations from all quizzes
 	for i := range quizzes {
-		var questions []map[string]interface{}
-		if err := json.Unmarshal(quizzes[i].Questions, &questions); err == nil {
-			for j := range questions {
-				delete(questions[j], "correctAnswer")
-				delete(questions[j], "explanation")
-			}
-			quizzes[i].Questions, _ = json.Marshal(questions)
+		if questions, err := models.PublicQuestionsJSON(quizzes[i].Questions); err == nil {
+			quizzes[i].Questions = questions
 		}
 	}
 
@@ -111,7 +103,8 @@ func (h *Handler) GetQuizzesByCourseHandler(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-// StartQuizAttemptHandler starts a new quiz attempt
+// StartQuizAttemptHandler starts a new quiz attempt, or resumes the user's
+// open attempt, the same way as StartQuizAttemptEnhancedHandler
 func (h *Handler) StartQuizAttemptHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
@@ -155,19 +148,32 @@ func (h *Handler) StartQuizAttemptHandler(w http.ResponseWriter, r *http.Request
 
 	log.Printf("[DEBUG] StartQuizAttempt - User enrolled, starting attempt...")
 
-	attempt, err := models.StartQuizAttempt(h.DB, userID, quizID)
+	attempt, err := models.StartQuizAttemptEnhanced(h.DB, userID, quizID)
+	if errors.Is(err, models.ErrInvalidDrawRules) {
+		http.Error(w, "The question bank can no longer fill this quiz: "+err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, models.ErrMaxAttempts) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("[ERROR] StartQuizAttempt - Failed to start quiz attempt: %v", err)
 		http.Error(w, "Failed to start quiz attempt", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[DEBUG] StartQuizAttempt - Successfully created attempt: %+v", attempt)
+	log.Printf("[DEBUG] StartQuizAttempt - Successfully started attempt: %+v", attempt)
+
+	message := "Quiz attempt started successfully"
+	if attempt.Resumed {
+		message = "Quiz attempt resumed"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Quiz attempt started successfully",
+		"message": message,
 		"data":    attempt,
 	})
 }
@@ -177,7 +183,6 @@ func (h *Handler) SubmitQuizHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		AttemptID int             `json:"attemptId"`
 		Answers   json.RawMessage `json:"answers"`
-		TimeSpent int             `json:"timeSpent"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -185,11 +190,17 @@ func (h *Handler) SubmitQuizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := models.SubmitQuizAttempt(h.DB, req.AttemptID, req.Answers, req.TimeSpent)
+	result, err := models.SubmitQuizAttempt(h.DB, req.AttemptID, req.Answers)
+	if errors.Is(err, models.ErrAttemptExpired) {
+		finishQuizAttempt(h.DB, req.AttemptID)
+		http.Error(w, "Time limit exceeded; the attempt was graded with its saved answers", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to submit quiz", http.StatusInternalServerError)
 		return
 	}
+	finishQuizAttempt(h.DB, req.AttemptID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -329,10 +340,15 @@ func (h *Handler) CreateQuizHandler(w http.ResponseWriter, r *http.Request) {
 		Title       string          `json:"title"`
 		Description string          `json:"description"`
 		CourseID    int             `json:"courseId"`
+		ModuleID    *int            `json:"moduleId"`
 		QuizType    string          `json:"quizType"`
 		Questions   json.RawMessage `json:"questions"`
 		TimeLimit   int             `json:"timeLimit"`
 		PassingScore int            `json:"passingScore"`
+		// DrawRules assemble each attempt from the course's question bank
+		DrawRules      []models.QuizDrawRule `json:"drawRules"`
+		ShuffleOptions bool                  `json:"shuffleOptions"`
+		Scoring        models.QuizScoring    `json:"scoring"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -346,17 +362,47 @@ func (h *Handler) CreateQuizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.ValidateQuizQuestions(req.Questions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.ModuleID != nil {
+		ok, err := models.ModuleBelongsToCourse(h.DB, req.CourseID, *req.ModuleID)
+		if err != nil {
+			http.Error(w, "Failed to create quiz: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, models.ErrModuleNotInCourse.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Quizzes drawing from the question bank need no questions of their own
+	if len(req.Questions) == 0 {
+		req.Questions = json.RawMessage("[]")
+	}
+
 	quiz := &models.Quiz{
-		Title:        req.Title,
-		Description:  req.Description,
-		CourseID:     req.CourseID,
-		QuizType:     req.QuizType,
-		Questions:    req.Questions,
-		TimeLimit:    req.TimeLimit,
-		PassingScore: req.PassingScore,
+		Title:          req.Title,
+		Description:    req.Description,
+		CourseID:       req.CourseID,
+		ModuleID:       req.ModuleID,
+		QuizType:       req.QuizType,
+		Questions:      req.Questions,
+		TimeLimit:      req.TimeLimit,
+		PassingScore:   req.PassingScore,
+		DrawRules:      req.DrawRules,
+		ShuffleOptions: req.ShuffleOptions,
+		Scoring:        req.Scoring,
 	}
 
 	if err := models.CreateQuiz(h.DB, quiz); err != nil {
+		if errors.Is(err, models.ErrInvalidDrawRules) || errors.Is(err, models.ErrInvalidScoring) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to create quiz: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -385,6 +431,14 @@ func (h *Handler) UpdateQuizHandler(w http.ResponseWriter, r *http.Request) {
 		Questions   json.RawMessage `json:"questions"`
 		TimeLimit   int             `json:"timeLimit"`
 		PassingScore int            `json:"passingScore"`
+		// ModuleID moves the quiz into a module of its course; 0 removes it from its module
+		ModuleID *int `json:"moduleId"`
+		// DrawRules and ShuffleOptions are left unchanged when omitted; empty
+		// draw rules make the quiz use its own questions again
+		DrawRules      *[]models.QuizDrawRule `json:"drawRules"`
+		ShuffleOptions *bool                  `json:"shuffleOptions"`
+		// Scoring is left unchanged when omitted
+		Scoring *models.QuizScoring `json:"scoring"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -398,6 +452,68 @@ func (h *Handler) UpdateQuizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Omitted questions keep the quiz's current questions
+	if string(req.Questions) == "null" {
+		req.Questions = nil
+	}
+	if len(req.Questions) > 0 {
+		if err := models.ValidateQuizQuestions(req.Questions); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to update quiz: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if req.ModuleID != nil {
+		err := models.SetQuizModule(tx, quizID, *req.ModuleID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Quiz not found", http.StatusNotFound)
+			return
+		}
+		if err == models.ErrModuleNotInCourse {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update quiz: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := models.SetQuizAssembly(tx, quizID, req.DrawRules, req.ShuffleOptions); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Quiz not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrInvalidDrawRules) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update quiz: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Scoring != nil {
+		if err := models.SetQuizScoring(tx, quizID, *req.Scoring); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Quiz not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, models.ErrInvalidScoring) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to update quiz: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	quiz := &models.Quiz{
 		ID:           quizID,
 		Title:        req.Title,
@@ -408,7 +524,16 @@ func (h *Handler) UpdateQuizHandler(w http.ResponseWriter, r *http.Request) {
 		PassingScore: req.PassingScore,
 	}
 
-	if err := models.UpdateQuiz(h.DB, quiz); err != nil {
+	if err := models.UpdateQuiz(tx, quiz); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Quiz not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update quiz: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		http.Error(w, "Failed to update quiz: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -442,9 +567,14 @@ func (h *Handler) DeleteQuizHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetAllQuizzesHandler gets all quizzes (admin only)
+// GetAllQuizzesHandler gets a page of quizzes (admin only)
 func (h *Handler) GetAllQuizzesHandler(w http.ResponseWriter, r *http.Request) {
-	quizzes, err := models.GetAllQuizzes(h.DB)
+	list, ok := parseListQuery(w, r, models.QuizListSpec)
+	if !ok {
+		return
+	}
+
+	quizzes, page, err := models.GetAllQuizzes(h.DB, list)
 	if err != nil {
 		http.Error(w, "Failed to get quizzes: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -452,7 +582,8 @@ func (h *Handler) GetAllQuizzesHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    quizzes,
+		"success":    true,
+		"data":       quizzes,
+		"pagination": page,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"lms-backend/models"
+)
+
+// finishQuizAttempt updates what depends on a graded attempt: the progress of
+// its module and the learner's xAPI result
+func finishQuizAttempt(database *sql.DB, attemptID int) {
+	if err := models.UpdateModuleProgressForAttempt(database, attemptID); err != nil {
+		log.Printf("[ERROR] Failed to update module progress for attempt %d: %v", attemptID, err)
+	}
+	recordQuizResult(database, attemptID)
+}
+
+// StartQuizAttemptSweeper periodically grades attempts at timed quizzes that
+// were abandoned past their deadline, every QUIZ_ATTEMPT_SWEEP_INTERVAL
+func StartQuizAttemptSweeper(database *sql.DB) {
+	interval, err := durationFromEnv("QUIZ_ATTEMPT_SWEEP_INTERVAL", "1m")
+	if err != nil || interval <= 0 {
+		log.Printf("[ERROR] Invalid QUIZ_ATTEMPT_SWEEP_INTERVAL, using 1m")
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sweepQuizAttempts(database)
+		}
+	}()
+}
+
+// sweepQuizAttempts closes the expired attempts once
+func sweepQuizAttempts(database *sql.DB) {
+	closed, err := models.CloseExpiredQuizAttempts(database)
+	if err != nil {
+		log.Printf("[ERROR] Failed to close expired quiz attempts: %v", err)
+	}
+	for _, attemptID := range closed {
+		finishQuizAttempt(database, attemptID)
+	}
+	if len(closed) > 0 {
+		log.Printf("Closed %d expired quiz attempt(s)", len(closed))
+	}
+}
@@ -3,7 +3,8 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -15,6 +16,9 @@ import (
 // Global database variable for enhanced handlers
 var db *sql.DB
 
+// maxQuizAnswersSize limits the answers saved or submitted in one request
+const maxQuizAnswersSize = 1 << 20
+
 // SetEnhancedHandlerDB sets the database connection for enhanced handlers
 func SetEnhancedHandlerDB(database *sql.DB) {
 	db = database
@@ -81,22 +85,21 @@ func GetQuizEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 		"maxAttempts": quiz.MaxAttempts,
 		"passingScore": quiz.PassingScore,
 		"quizType":    quiz.QuizType,
+		"drawRules":   quiz.DrawRules,
+		"scoring":     quiz.Scoring,
 		"isActive":    quiz.IsActive,
 		"createdAt":   quiz.CreatedAt,
 		"updatedAt":   quiz.UpdatedAt,
 	}
 
-	// Process questions to remove sensitive fields
+	// Process questions to remove sensitive fields. A quiz with draw rules
+	// has no fixed questions; each attempt comes with its own.
 	var safeQuestions []map[string]interface{}
-	for _, question := range quiz.Questions {
-		safeQuestion := map[string]interface{}{
-			"id":       question.ID,
-			"question": question.Question,
-			"options":   question.Options,
-			"points":   question.Points,
-			// correctAnswer and explanation are intentionally omitted
+	if len(quiz.DrawRules) == 0 {
+		for _, question := range quiz.Questions {
+			// The answer key and explanation are intentionally omitted
+			safeQuestions = append(safeQuestions, question.PublicView())
 		}
-		safeQuestions = append(safeQuestions, safeQuestion)
 	}
 	quizData["questions"] = safeQuestions
 
@@ -157,21 +160,98 @@ func StartQuizAttemptEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start attempt
+	// Start attempt, or resume the open one
 	attempt, err := models.StartQuizAttemptEnhanced(db, userID, quiz.ID)
+	if errors.Is(err, models.ErrInvalidDrawRules) {
+		http.Error(w, "The question bank can no longer fill this quiz: "+err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	message := "Quiz attempt started successfully"
+	if attempt.Resumed {
+		message = "Quiz attempt resumed"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Quiz attempt started successfully",
+		"message": message,
 		"data":    attempt,
 	})
 }
 
+// SaveQuizAnswersEnhancedHandler autosaves the partial answers of an open attempt
+func SaveQuizAnswersEnhancedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	attemptID, err := strconv.Atoi(mux.Vars(r)["attemptId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid attempt ID",
+		})
+		return
+	}
+
+	var req struct {
+		Answers map[string]interface{} `json:"answers"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxQuizAnswersSize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Answers == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Verify that the attempt belongs to the authenticated user
+	var attemptUserID int
+	err = db.QueryRow("SELECT user_id FROM quiz_attempts WHERE id = $1", attemptID).Scan(&attemptUserID)
+	if err != nil {
+		http.Error(w, "Attempt not found", http.StatusNotFound)
+		return
+	}
+	if attemptUserID != userID {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	remaining, err := models.SaveQuizAttemptAnswers(db, attemptID, req.Answers)
+	switch {
+	case errors.Is(err, models.ErrAttemptExpired):
+		finishQuizAttempt(db, attemptID)
+		http.Error(w, "Time limit exceeded; the attempt was graded with its saved answers", http.StatusConflict)
+		return
+	case errors.Is(err, models.ErrAttemptCompleted):
+		http.Error(w, "Attempt already completed", http.StatusConflict)
+		return
+	case errors.Is(err, models.ErrUnknownAnswer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to save answers of quiz attempt %d: %v", attemptID, err)
+		http.Error(w, "Failed to save answers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Answers saved",
+		"data": map[string]interface{}{
+			"attemptId":        attemptID,
+			"remainingSeconds": remaining,
+		},
+	})
+}
+
 // SubmitQuizEnhancedHandler handles submitting a quiz attempt
 func SubmitQuizEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := middleware.GetUserIDFromContext(r)
@@ -195,6 +275,7 @@ func SubmitQuizEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var submission models.QuizSubmissionEnhanced
+	r.Body = http.MaxBytesReader(w, r.Body, maxQuizAnswersSize)
 	err = json.NewDecoder(r.Body).Decode(&submission)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -218,10 +299,16 @@ func SubmitQuizEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Submit quiz
 	result, err := models.SubmitQuizAttemptEnhanced(db, submission)
+	if errors.Is(err, models.ErrAttemptExpired) {
+		finishQuizAttempt(db, attemptID)
+		http.Error(w, "Time limit exceeded; the attempt was graded with its saved answers", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	finishQuizAttempt(db, attemptID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -335,8 +422,9 @@ func GetQuizResultEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get basic result without correct answers (for security)
 	query := `
-	SELECT qa.id, qa.score, qa.time_spent, qa.attempt_number, qa.passed, qa.answers,
-	       q.questions, q.max_attempts, q.passing_score
+	SELECT qa.id, qa.score, COALESCE(qa.points, 0), COALESCE(qa.max_points, 0), qa.time_spent, qa.attempt_number,
+	       qa.passed, qa.auto_submitted, qa.answers, COALESCE(qa.question_set, q.questions), q.max_attempts,
+	       q.passing_score, q.negative_marking, q.score_rounding
 	FROM quiz_attempts qa
 	JOIN quizzes q ON qa.quiz_id = q.id
 	WHERE qa.id = $1
@@ -346,9 +434,11 @@ func GetQuizResultEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 	var result models.QuizResultEnhanced
 	var answersJSON, questionsJSON []byte
 	var maxAttempts, passingScore int
+	var scoring models.QuizScoring
 
-	err = row.Scan(&result.AttemptID, &result.Score, &result.TimeSpent, &result.AttemptNumber, 
-		&result.Passed, &answersJSON, &questionsJSON, &maxAttempts, &passingScore)
+	err = row.Scan(&result.AttemptID, &result.Score, &result.Points, &result.MaxPoints, &result.TimeSpent,
+		&result.AttemptNumber, &result.Passed, &result.AutoSubmitted, &answersJSON, &questionsJSON, &maxAttempts,
+		&passingScore, &scoring.NegativeMarking, &scoring.Rounding)
 	if err != nil {
 		http.Error(w, "Error getting result", http.StatusInternalServerError)
 		return
@@ -369,22 +459,10 @@ func GetQuizResultEnhancedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Count correct answers without revealing them
-	correctCount := 0
-	for _, question := range questions {
-		questionIDStr := fmt.Sprintf("%d", question.ID)
-		
-		// Count correct answers
-		if userAnswer, exists := result.Answers[questionIDStr]; exists {
-			if userAnswerInt, ok := userAnswer.(float64); ok {
-				if int(userAnswerInt) == question.CorrectAnswer {
-					correctCount++
-				}
-			}
-		}
-	}
-
-	result.CorrectCount = correctCount
-	result.TotalCount = len(questions)
+	grade := models.GradeQuiz(questions, result.Answers, scoring)
+	result.CorrectCount = grade.CorrectCount
+	result.TotalCount = grade.TotalCount
+	result.Credit = grade.Credit
 
 	// DO NOT include CorrectAnswers and Explanations for security
 	result.CorrectAnswers = nil
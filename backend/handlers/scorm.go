@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+	"lms-backend/xapi"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SCORM package limits: the uploaded zip and its extracted content, and the
+// body of a runtime commit
+const (
+	maxScormPackageSize   = 200 << 20
+	maxScormExtractedSize = 500 << 20
+	maxScormCommitSize    = 1 << 20
+)
+
+// scormAssetTokenTTL is how long a launch URL keeps serving a package's assets
+const scormAssetTokenTTL = 4 * time.Hour
+
+// scormAssetScope is the scoped token scope granting access to a package's assets
+func scormAssetScope(packageID int) string {
+	return fmt.Sprintf("scorm:%d", packageID)
+}
+
+// UploadScormPackage stores a SCORM 1.2, SCORM 2004 or cmi5 zip as the content of
+// a lesson, which becomes a "scorm" lesson (admin only). Uploading again replaces
+// the package.
+func (h *AdminHandler) UploadScormPackage(w http.ResponseWriter, r *http.Request) {
+	courseID, lessonID, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxScormPackageSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Failed to parse form (max 200MB)", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("package")
+	if err != nil {
+		http.Error(w, "Failed to get package from form", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		http.Error(w, "Package must be a zip archive", http.StatusBadRequest)
+		return
+	}
+	manifest, err := models.ParseScormPackage(archive)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storagePath := filepath.Join("./uploads", "scorm", uuid.New().String())
+	if err := extractScormPackage(archive, storagePath); err != nil {
+		os.RemoveAll(storagePath)
+		if errors.Is(err, models.ErrInvalidScormPackage) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error extracting SCORM package for lesson %d: %v", lessonID, err)
+		http.Error(w, "Failed to save package", http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(r)
+	pkg := &models.ScormPackage{
+		CourseID:     courseID,
+		LessonID:     lessonID,
+		Version:      manifest.Version,
+		Identifier:   manifest.Identifier,
+		Title:        manifest.Title,
+		LaunchPath:   manifest.LaunchPath,
+		StoragePath:  storagePath,
+		MasteryScore: manifest.MasteryScore,
+		UploadedBy:   &userID,
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		os.RemoveAll(storagePath)
+		http.Error(w, "Failed to save package", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	oldStoragePath, err := models.SaveScormPackage(tx, pkg)
+	if err != nil {
+		os.RemoveAll(storagePath)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[ADMIN ERROR] Error saving SCORM package for lesson %d: %v", lessonID, err)
+		http.Error(w, "Failed to save package", http.StatusInternalServerError)
+		return
+	}
+	if !h.commitLessonChange(w, r, tx, courseID, "Failed to save package") {
+		os.RemoveAll(storagePath)
+		return
+	}
+	if oldStoragePath != "" {
+		if err := os.RemoveAll(oldStoragePath); err != nil {
+			log.Printf("[ADMIN ERROR] Failed to remove replaced SCORM package %s: %v", oldStoragePath, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "SCORM package uploaded successfully",
+		"data":    pkg,
+	})
+}
+
+// GetScormPackageAdmin gets the package of a SCORM lesson (admin only)
+func (h *AdminHandler) GetScormPackageAdmin(w http.ResponseWriter, r *http.Request) {
+	courseID, lessonID, ok := h.lessonRouteIDs(w, r)
+	if !ok {
+		return
+	}
+
+	pkg, err := models.GetScormPackageByLesson(h.db, courseID, lessonID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "SCORM package not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error getting SCORM package for lesson %d: %v", lessonID, err)
+		http.Error(w, "Failed to get package", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    pkg,
+	})
+}
+
+// extractScormPackage writes the files of a package archive below dir
+func extractScormPackage(archive *zip.Reader, dir string) error {
+	var extracted int64
+	for _, file := range archive.File {
+		name := path.Clean(strings.ReplaceAll(file.Name, "\\", "/"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%w: %s is outside the package", models.ErrInvalidScormPackage, file.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", models.ErrInvalidScormPackage, file.Name, err)
+		}
+		dst, err := os.Create(target)
+		if err != nil {
+			src.Close()
+			return err
+		}
+		written, err := io.Copy(dst, io.LimitReader(src, maxScormExtractedSize-extracted+1))
+		src.Close()
+		dst.Close()
+		if err != nil {
+			return err
+		}
+		extracted += written
+		if extracted > maxScormExtractedSize {
+			return fmt.Errorf("%w: extracted content is larger than 500MB", models.ErrInvalidScormPackage)
+		}
+	}
+	return nil
+}
+
+// copyScormStorage copies the extracted files of a package from src to dst
+func copyScormStorage(src, dst string) error {
+	return filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		in, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// scormRouteIDs parses the course and lesson IDs of a learner SCORM route and
+// checks that the caller is enrolled in the course
+func (h *Handler) scormRouteIDs(w http.ResponseWriter, r *http.Request) (int, int, int, bool) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return 0, 0, 0, false
+	}
+
+	vars := mux.Vars(r)
+	courseID, err := strconv.Atoi(vars["courseId"])
+	if err != nil {
+		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return 0, 0, 0, false
+	}
+	lessonID, err := strconv.Atoi(vars["lessonId"])
+	if err != nil {
+		http.Error(w, "Invalid lesson ID", http.StatusBadRequest)
+		return 0, 0, 0, false
+	}
+
+	enrolled, err := models.IsUserEnrolledInCourse(h.DB, userID, courseID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return 0, 0, 0, false
+	}
+	if !enrolled {
+		http.Error(w, "User not enrolled in course", http.StatusForbidden)
+		return 0, 0, 0, false
+	}
+	return userID, courseID, lessonID, true
+}
+
+// getLessonScormPackage loads the package of a SCORM lesson, writing an error when there is none
+func (h *Handler) getLessonScormPackage(w http.ResponseWriter, courseID, lessonID int) (*models.ScormPackage, bool) {
+	pkg, err := models.GetScormPackageByLesson(h.DB, courseID, lessonID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "SCORM package not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to get SCORM package for lesson %d: %v", lessonID, err)
+		http.Error(w, "Failed to get SCORM package", http.StatusInternalServerError)
+		return nil, false
+	}
+	return pkg, true
+}
+
+// LaunchScormLesson returns the URL that plays a SCORM lesson's package, valid
+// for a few hours, together with the learner's stored runtime data
+func (h *Handler) LaunchScormLesson(w http.ResponseWriter, r *http.Request) {
+	userID, courseID, lessonID, ok := h.scormRouteIDs(w, r)
+	if !ok {
+		return
+	}
+	pkg, ok := h.getLessonScormPackage(w, courseID, lessonID)
+	if !ok {
+		return
+	}
+
+	token, err := middleware.GenerateScopedToken(userID, scormAssetScope(pkg.ID), scormAssetTokenTTL)
+	if err != nil {
+		log.Printf("[ERROR] Failed to generate SCORM asset token: %v", err)
+		http.Error(w, "Failed to launch package", http.StatusInternalServerError)
+		return
+	}
+
+	runtime, err := models.GetScormRuntime(h.DB, pkg, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get SCORM runtime for package %d: %v", pkg.ID, err)
+		http.Error(w, "Failed to get runtime data", http.StatusInternalServerError)
+		return
+	}
+
+	recordStatement(h.DB, userID, xapi.Statement{
+		Verb:    xapi.VerbLaunched,
+		Object:  lessonActivity(courseID, lessonID),
+		Context: inCourse(courseID),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"package":   pkg,
+			"launchUrl": fmt.Sprintf("/api/public/scorm/%d/%s/%s", pkg.ID, token, pkg.LaunchPath),
+			"runtime":   runtime,
+		},
+	})
+}
+
+// GetScormRuntimeHandler gets the learner's runtime data for a SCORM lesson
+func (h *Handler) GetScormRuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, courseID, lessonID, ok := h.scormRouteIDs(w, r)
+	if !ok {
+		return
+	}
+	pkg, ok := h.getLessonScormPackage(w, courseID, lessonID)
+	if !ok {
+		return
+	}
+
+	runtime, err := models.GetScormRuntime(h.DB, pkg, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get SCORM runtime for package %d: %v", pkg.ID, err)
+		http.Error(w, "Failed to get runtime data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    runtime,
+	})
+}
+
+// CommitScormRuntimeHandler stores the cmi elements a SCORM 1.2 or 2004 package
+// set since its last commit. A completed or passed status completes the lesson.
+func (h *Handler) CommitScormRuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, courseID, lessonID, ok := h.scormRouteIDs(w, r)
+	if !ok {
+		return
+	}
+	pkg, ok := h.getLessonScormPackage(w, courseID, lessonID)
+	if !ok {
+		return
+	}
+	if pkg.Version == models.ScormVersionCmi5 {
+		http.Error(w, "cmi5 packages do not use the SCORM runtime API", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Values map[string]string `json:"values"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxScormCommitSize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	runtime, err := models.SaveScormRuntime(h.DB, pkg, userID, req.Values)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidScormValue) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ERROR] Failed to save SCORM runtime for package %d: %v", pkg.ID, err)
+		http.Error(w, "Failed to save runtime data", http.StatusInternalServerError)
+		return
+	}
+
+	if runtime.Completed() {
+		wasCompleted := lessonCompleted(h.DB, userID, courseID, lessonID)
+		err := models.UpdateLessonProgress(h.DB, userID, courseID, lessonID, 100, runtime.SessionTime, true)
+		if err != nil {
+			log.Printf("[ERROR] Failed to complete SCORM lesson %d: %v", lessonID, err)
+			http.Error(w, "Failed to update lesson progress", http.StatusInternalServerError)
+			return
+		}
+		if !wasCompleted {
+			var score *xapi.Score
+			if runtime.ScoreRaw != nil {
+				score = xapi.PercentageScore(*runtime.ScoreRaw)
+			}
+			recordLessonCompleted(h.DB, userID, courseID, lessonID, runtime.TotalTime, score)
+		}
+		if err := models.UpdateModuleProgress(h.DB, userID, courseID); err != nil {
+			log.Printf("[ERROR] CommitScormRuntime - Failed to update module progress: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    runtime,
+	})
+}
+
+// ServeScormAsset serves a file of a SCORM package. Packages run in an iframe
+// that cannot send the Authorization header, so the launch URL carries a scoped
+// token in its path and relative links inside the package keep it.
+func (h *Handler) ServeScormAsset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	packageID, err := strconv.Atoi(vars["packageId"])
+	if err != nil {
+		http.Error(w, "Invalid package ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := middleware.ValidateScopedToken(vars["token"], scormAssetScope(packageID)); err != nil {
+		http.Error(w, "Invalid or expired launch URL", http.StatusUnauthorized)
+		return
+	}
+
+	pkg, err := models.GetScormPackage(h.DB, packageID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "SCORM package not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get SCORM package", http.StatusInternalServerError)
+		return
+	}
+
+	// Cleaning a rooted path drops any ".." that would leave the package
+	name := path.Clean("/" + vars["path"])
+	w.Header().Del("Content-Type")
+	http.ServeFile(w, r, filepath.Join(pkg.StoragePath, filepath.FromSlash(name)))
+}
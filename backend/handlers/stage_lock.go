@@ -138,24 +138,17 @@ func (h *StageLockHandler) UpdateStageLock(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Get user role from context
-	userRole, err := middleware.GetUserRoleFromContext(r)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	// Only roles that can edit courses can update stage locks
+	if !middleware.HasPermission(r, "courses.edit") {
+		w.WriteHeader(http.StatusForbidden)
 		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "Failed to get user role",
-			Message: err.Error(),
+			Error:   "Access denied",
+			Message: "Permission courses.edit is required to update stage locks",
 		})
 		return
 	}
 
-	// Only admin can update stage locks
-	if userRole != "admin" {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "Access denied",
-			Message: "Only admin can update stage locks",
-		})
+	if !requireCourseAccess(h.DB, w, r, courseID) {
 		return
 	}
 
@@ -189,11 +182,24 @@ func (h *StageLockHandler) UpdateStageLock(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	// Modules are locked individually with the stage name "module:<id>"
+	if moduleID, ok := models.ParseModuleStageName(req.StageName); ok {
+		validStage, err = models.ModuleBelongsToCourse(h.DB, courseID, moduleID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error:   "Failed to update stage lock",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
 	if !validStage {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(ErrorResponse{
 			Error:   "Invalid stage name",
-			Message: "Stage name must be one of: intro, pretest, lessons, posttest, postwork, finalproject",
+			Message: "Stage name must be one of: intro, pretest, lessons, posttest, postwork, finalproject, or module:<id> for a module of the course",
 		})
 		return
 	}
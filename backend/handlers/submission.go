@@ -75,6 +75,7 @@ func (h *Handler) CreatePostWorkSubmissionHandler(w http.ResponseWriter, r *http
 		return
 	}
 	fmt.Printf("[DEBUG] Submission created successfully: %+v\n", submission)
+	recordSubmission(h.DB, userID, req.CourseID, "postwork", req.Title)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -192,6 +193,7 @@ func (h *Handler) CreateFinalProjectSubmissionHandler(w http.ResponseWriter, r *
 		http.Error(w, "Failed to create submission", http.StatusInternalServerError)
 		return
 	}
+	recordSubmission(h.DB, userID, req.CourseID, "finalproject", req.Title)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -187,10 +187,9 @@ func (h *SurveyHandler) GetSurveyFeedbackHandler(w http.ResponseWriter, r *http.
 
 // GetAllSurveyFeedbackHandler gets all survey feedback for a course (admin only)
 func (h *SurveyHandler) GetAllSurveyFeedbackHandler(w http.ResponseWriter, r *http.Request) {
-	// Get user role from context (assuming role is set in middleware)
-	userRole, ok := r.Context().Value("userRole").(string)
-	if !ok || userRole != "admin" {
-		respondWithError(w, http.StatusForbidden, "Admin access required")
+	// Check that the user's role grants access to survey feedback
+	if !middleware.HasPermission(r, "surveys.view") {
+		respondWithError(w, http.StatusForbidden, "Permission surveys.view required")
 		return
 	}
 
@@ -208,6 +207,11 @@ func (h *SurveyHandler) GetAllSurveyFeedbackHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// Course-scoped roles only see feedback for their assigned courses
+	if !requireCourseAccess(h.DB, w, r, courseID) {
+		return
+	}
+
 	// Get all survey feedback for the course
 	feedbacks, err := h.getAllSurveyFeedback(courseID)
 	if err != nil {
@@ -222,6 +226,30 @@ func (h *SurveyHandler) GetAllSurveyFeedbackHandler(w http.ResponseWriter, r *ht
 	})
 }
 
+// GetInstructorSurveyFeedbackHandler gets all survey feedback for a course the user is assigned to
+func (h *SurveyHandler) GetInstructorSurveyFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["courseId"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid course ID")
+		return
+	}
+
+	if !requireCourseAssignment(h.DB, w, r, courseID) {
+		return
+	}
+
+	feedbacks, err := h.getAllSurveyFeedback(courseID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving survey feedback")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    feedbacks,
+	})
+}
+
 // Helper method to check if user is enrolled in course
 func (h *SurveyHandler) isUserEnrolledInCourse(userID, courseID int) (bool, error) {
 	query := `SELECT COUNT(*) FROM course_enrollments WHERE user_id = $1 AND course_id = $2`
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+	"lms-backend/security"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// SetupTwoFactor generates a TOTP secret for the current user and returns the
+// provisioning URI to show as a QR code. 2FA is not active until EnableTwoFactor.
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Already enabled",
+			Message: "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to generate secret",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := models.SetPendingTOTPSecret(h.DB, user.ID, secret); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to save secret",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Scan the QR code and confirm with a code to enable two-factor authentication",
+		Data: models.TwoFactorSetupResponse{
+			Secret:          secret,
+			ProvisioningURI: security.TOTPProvisioningURI(totpIssuer(), user.Email, secret),
+		},
+	})
+}
+
+// EnableTwoFactor confirms the pending secret with a code and returns recovery codes
+func (h *AuthHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Already enabled",
+			Message: "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !h.checkTOTP(w, user.ID, req.Code) {
+		return
+	}
+
+	codes, err := security.GenerateRecoveryCodes(recoveryCodeCount)
+	if err == nil {
+		err = models.EnableTOTP(h.DB, user.ID, codes)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to enable two-factor authentication",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+		Data: map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// DisableTwoFactor turns 2FA off after checking the password and a current code
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.TwoFactorDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid password",
+			Message: "Password saat ini tidak benar",
+		})
+		return
+	}
+
+	if !h.checkTOTP(w, user.ID, req.Code) {
+		return
+	}
+
+	if err := models.DisableTOTP(h.DB, user.ID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to disable two-factor authentication",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a current code
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to get user",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Not enabled",
+			Message: "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !h.checkTOTP(w, user.ID, req.Code) {
+		return
+	}
+
+	codes, err := security.GenerateRecoveryCodes(recoveryCodeCount)
+	if err == nil {
+		err = models.ReplaceRecoveryCodes(h.DB, user.ID, codes)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to regenerate recovery codes",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Recovery codes regenerated",
+		Data: map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// LoginTwoFactor completes a login started by Login for a user with 2FA enabled
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Missing required fields",
+			Message: "Challenge token and a code or recovery code are required",
+		})
+		return
+	}
+
+	challengeID, userID, err := models.StartLoginChallengeAttempt(h.DB, req.ChallengeToken)
+	if err == models.ErrLoginChallengeInvalid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid challenge",
+			Message: "Sesi login telah berakhir, silakan login kembali",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to verify code",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid challenge",
+			Message: "User not found",
+		})
+		return
+	}
+
+	// Wrong codes count against the same lockout as wrong passwords, so new
+	// challenges cannot be used to keep guessing
+	userKey := models.UsernameThrottleKey(user.Username)
+	ipKey := models.IPThrottleKey(clientIP(r))
+	lockedUntil, err := models.GetLoginLockedUntil(h.DB, userKey, ipKey)
+	if err != nil {
+		log.Printf("Error checking login lockout: %v", err)
+	}
+	if lockedUntil != nil {
+		writeLoginLocked(w, *lockedUntil)
+		return
+	}
+
+	var ok bool
+	if req.Code != "" {
+		ok, err = models.VerifyTOTPCode(h.DB, userID, req.Code)
+	} else {
+		ok, err = models.UseRecoveryCode(h.DB, userID, req.RecoveryCode)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to verify code",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !ok {
+		if lockedUntil = h.recordLoginFailure(userKey, ipKey); lockedUntil != nil {
+			writeLoginLocked(w, *lockedUntil)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid code",
+			Message: "Kode verifikasi salah",
+		})
+		return
+	}
+
+	if err := models.CompleteLoginChallenge(h.DB, challengeID); err != nil {
+		log.Printf("Error completing login challenge %d: %v", challengeID, err)
+	}
+	if err := models.ClearLoginFailures(h.DB, userKey); err != nil {
+		log.Printf("Error clearing login failures: %v", err)
+	}
+
+	session, err := h.issueSession(r, user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to generate token",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    session,
+	})
+}
+
+// writeTwoFactorChallenge starts the second login step for a user with 2FA enabled
+func (h *AuthHandler) writeTwoFactorChallenge(w http.ResponseWriter, user *models.User) {
+	challenge, err := h.startTwoFactorChallenge(user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to start two-factor login",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuccessResponse{
+		Success: true,
+		Message: "Two-factor authentication required",
+		Data:    challenge,
+	})
+}
+
+// startTwoFactorChallenge creates the challenge a user with 2FA completes
+// at /login/2fa instead of getting a session
+func (h *AuthHandler) startTwoFactorChallenge(user *models.User) (*models.TwoFactorChallengeResponse, error) {
+	ttl, err := durationFromEnv("TWO_FACTOR_CHALLENGE_EXPIRY", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := models.CreateLoginChallenge(h.DB, user.ID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	}, nil
+}
+
+// checkTOTP verifies a code for the user and writes an error response when it fails
+func (h *AuthHandler) checkTOTP(w http.ResponseWriter, userID int, code string) bool {
+	if code == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Missing code",
+			Message: "Verification code is required",
+		})
+		return false
+	}
+
+	ok, err := models.VerifyTOTPCode(h.DB, userID, code)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Failed to verify code",
+			Message: err.Error(),
+		})
+		return false
+	}
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:   "Invalid code",
+			Message: "Kode verifikasi salah",
+		})
+		return false
+	}
+	return true
+}
+
+// totpIssuer is the account issuer shown in authenticator apps
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "AgileKu LMS"
+}
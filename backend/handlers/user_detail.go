@@ -136,10 +136,9 @@ func (h *UserDetailHandler) UpdateUserDetail(w http.ResponseWriter, r *http.Requ
 func (h *UserDetailHandler) GetUserDetailByID(w http.ResponseWriter, r *http.Request) {
 	log.Println("[USER_DETAIL_HANDLER] GetUserDetailByID called")
 	
-	// Check if user is admin
-	userRole, err := middleware.GetUserRoleFromContext(r)
-	if err != nil || userRole != "admin" {
-		log.Printf("[USER_DETAIL_HANDLER] Access denied - admin role required, error: %v, role: %s", err, userRole)
+	// Check that the user's role grants user management
+	if !middleware.HasPermission(r, "users.manage") {
+		log.Println("[USER_DETAIL_HANDLER] Access denied - permission users.manage required")
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
@@ -186,28 +185,26 @@ func (h *UserDetailHandler) GetUserDetailByID(w http.ResponseWriter, r *http.Req
 	log.Printf("[USER_DETAIL_HANDLER] Successfully returned user detail for user ID: %d (admin request)", userID)
 }
 
-// GetAllUserDetails gets all user details (admin only)
+// GetAllUserDetails gets a page of user details (admin only)
 func (h *UserDetailHandler) GetAllUserDetails(w http.ResponseWriter, r *http.Request) {
 	log.Println("[USER_DETAIL_HANDLER] GetAllUserDetails called")
 	
-	// Check if user is admin
-	userRole, err := middleware.GetUserRoleFromContext(r)
-	if err != nil {
-		log.Printf("[USER_DETAIL_HANDLER] User role not found in context: %v", err)
-		http.Error(w, "Role not found", http.StatusUnauthorized)
+	// Check that the user's role grants user management
+	if !middleware.HasPermission(r, "users.manage") {
+		log.Println("[USER_DETAIL_HANDLER] Access denied - permission users.manage required")
+		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	if userRole != "admin" {
-		log.Printf("[USER_DETAIL_HANDLER] Access denied - user role: %s", userRole)
-		http.Error(w, "Access denied", http.StatusForbidden)
+	log.Println("[USER_DETAIL_HANDLER] Access confirmed, getting all user details")
+
+	list, ok := parseListQuery(w, r, models.UserDetailListSpec)
+	if !ok {
 		return
 	}
 
-	log.Println("[USER_DETAIL_HANDLER] Admin access confirmed, getting all user details")
-
-	// Get all user details from database
-	userDetails, err := models.GetAllUserDetails(h.DB)
+	// Get a page of user details from database
+	userDetails, page, err := models.GetAllUserDetails(h.DB, list)
 	if err != nil {
 		log.Printf("[USER_DETAIL_HANDLER] Database error getting all user details: %v", err)
 		http.Error(w, "Failed to get user details", http.StatusInternalServerError)
@@ -217,7 +214,12 @@ func (h *UserDetailHandler) GetAllUserDetails(w http.ResponseWriter, r *http.Req
 	log.Printf("[USER_DETAIL_HANDLER] Successfully retrieved %d user details", len(userDetails))
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(userDetails); err != nil {
+	response := map[string]interface{}{
+		"success":     true,
+		"userDetails": userDetails,
+		"pagination":  page,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("[USER_DETAIL_HANDLER] JSON encoding error: %v", err)
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
@@ -230,10 +232,9 @@ func (h *UserDetailHandler) GetAllUserDetails(w http.ResponseWriter, r *http.Req
 func (h *UserDetailHandler) UpdateUserDetailByID(w http.ResponseWriter, r *http.Request) {
 	log.Println("[USER_DETAIL_HANDLER] UpdateUserDetailByID called")
 	
-	// Check if user is admin
-	userRole, err := middleware.GetUserRoleFromContext(r)
-	if err != nil || userRole != "admin" {
-		log.Printf("[USER_DETAIL_HANDLER] Access denied - admin role required, error: %v, role: %s", err, userRole)
+	// Check that the user's role grants user management
+	if !middleware.HasPermission(r, "users.manage") {
+		log.Println("[USER_DETAIL_HANDLER] Access denied - permission users.manage required")
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
@@ -315,10 +316,9 @@ func (h *UserDetailHandler) UpdateUserDetailByID(w http.ResponseWriter, r *http.
 func (h *UserDetailHandler) DeleteUserDetailByID(w http.ResponseWriter, r *http.Request) {
 	log.Println("[USER_DETAIL_HANDLER] DeleteUserDetailByID called")
 	
-	// Check if user is admin
-	userRole, err := middleware.GetUserRoleFromContext(r)
-	if err != nil || userRole != "admin" {
-		log.Printf("[USER_DETAIL_HANDLER] Access denied - admin role required, error: %v, role: %s", err, userRole)
+	// Check that the user's role grants user management
+	if !middleware.HasPermission(r, "users.manage") {
+		log.Println("[USER_DETAIL_HANDLER] Access denied - permission users.manage required")
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"lms-backend/mail"
+	"lms-backend/models"
+	"lms-backend/security"
+)
+
+const (
+	// userImportMaxBytes caps the size of an uploaded import file
+	userImportMaxBytes = 5 << 20
+	// userImportMaxRows caps the number of users created by one import
+	userImportMaxRows = 5000
+)
+
+// userImportColumns maps accepted header spellings to import fields
+var userImportColumns = map[string]string{
+	"username":  "username",
+	"email":     "email",
+	"fullname":  "full_name",
+	"name":      "full_name",
+	"role":      "role",
+	"courseids": "course_ids",
+	"courses":   "course_ids",
+	"password":  "password",
+}
+
+// ImportUsers creates users from a CSV file (admin only). The file is sent as the
+// "file" field of a multipart form or as the raw request body and needs a header
+// row with username, email and full_name columns; role, course_ids (separated by
+// ";") and password are optional. With ?dryRun=true the file is only validated.
+// Nothing is created unless every row is valid.
+func (h *AdminHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	sendInvites := true
+	if value := r.URL.Query().Get("sendInvites"); value != "" {
+		sendInvites, _ = strconv.ParseBool(value)
+	}
+
+	body, err := userImportBody(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	rows, errs := parseUserImportCSV(body)
+	if len(rows) > 0 {
+		dbErrs, err := models.ValidateUserImport(h.db, rows)
+		if err != nil {
+			log.Printf("[ADMIN ERROR] Failed to validate user import: %v", err)
+			http.Error(w, "Failed to validate import", http.StatusInternalServerError)
+			return
+		}
+		errs = append(errs, dbErrs...)
+	}
+
+	if errs == nil {
+		errs = []models.UserImportError{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(errs) > 0 || dryRun {
+		status := http.StatusOK
+		message := "Import file is valid"
+		if len(errs) > 0 {
+			status = http.StatusUnprocessableEntity
+			message = "Import file has errors, no users were created"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": len(errs) == 0,
+			"dryRun":  dryRun,
+			"message": message,
+			"total":   len(rows),
+			"errors":  errs,
+		})
+		return
+	}
+
+	imported, err := models.ImportUsers(h.db, rows)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Failed to import users: %v", err)
+		http.Error(w, "Failed to import users, no users were created", http.StatusInternalServerError)
+		return
+	}
+
+	if sendInvites {
+		for _, user := range imported {
+			if user.Invited {
+				h.sendAccountInviteEmail(user)
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"dryRun":  false,
+		"message": fmt.Sprintf("%d users imported", len(imported)),
+		"total":   len(rows),
+		"errors":  errs,
+		"users":   imported,
+	})
+}
+
+// userImportBody returns the uploaded CSV, from a multipart "file" field or the raw body
+func userImportBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, userImportMaxBytes)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(userImportMaxBytes); err != nil {
+			return nil, errors.New("Failed to parse form (max 5MB)")
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("Failed to get file from form")
+		}
+		return file, nil
+	}
+
+	return r.Body, nil
+}
+
+// parseUserImportCSV reads import rows and reports the field errors that need no
+// database lookup. Row numbers count the header as row 1, matching spreadsheets.
+func parseUserImportCSV(body io.Reader) ([]models.UserImportRow, []models.UserImportError) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, []models.UserImportError{{Message: "file is empty"}}
+	}
+	if err != nil {
+		return nil, []models.UserImportError{{Message: fmt.Sprintf("invalid CSV: %v", err)}}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		name = strings.NewReplacer("_", "", " ", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if field, ok := userImportColumns[name]; ok {
+			columns[field] = i
+		}
+	}
+
+	var errs []models.UserImportError
+	for _, field := range []string{"username", "email", "full_name"} {
+		if _, ok := columns[field]; !ok {
+			errs = append(errs, models.UserImportError{Field: field, Message: "missing column " + field})
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	policy := security.PasswordPolicyFromEnv()
+	var rows []models.UserImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, models.UserImportError{Row: line, Message: fmt.Sprintf("invalid CSV: %v", err)})
+			break
+		}
+
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := models.UserImportRow{
+			Row:      line,
+			Username: value("username"),
+			Email:    value("email"),
+			FullName: value("full_name"),
+			Role:     value("role"),
+			Password: value("password"),
+		}
+		if row.Username == "" && row.Email == "" && row.FullName == "" {
+			// Skip blank lines left by spreadsheet exports
+			continue
+		}
+
+		if len(rows) == userImportMaxRows {
+			errs = append(errs, models.UserImportError{Row: line, Message: fmt.Sprintf("too many rows (max %d)", userImportMaxRows)})
+			break
+		}
+
+		errs = append(errs, validateUserImportRow(&row, value("course_ids"), policy)...)
+		rows = append(rows, row)
+	}
+
+	return rows, errs
+}
+
+// validateUserImportRow checks the fields of one row and parses its course IDs
+func validateUserImportRow(row *models.UserImportRow, courseIDs string, policy security.PasswordPolicy) []models.UserImportError {
+	var errs []models.UserImportError
+	fail := func(field, message string) {
+		errs = append(errs, models.UserImportError{Row: row.Row, Field: field, Message: message})
+	}
+
+	switch {
+	case row.Username == "":
+		fail("username", "username is required")
+	case len(row.Username) < 3 || len(row.Username) > 50:
+		fail("username", "username must be 3 to 50 characters")
+	}
+
+	switch {
+	case row.Email == "":
+		fail("email", "email is required")
+	case len(row.Email) > 100 || !strings.Contains(row.Email, "@") || strings.ContainsAny(row.Email, " \t"):
+		fail("email", "email is not valid")
+	}
+
+	switch {
+	case row.FullName == "":
+		fail("full_name", "full name is required")
+	case len(row.FullName) > 100:
+		fail("full_name", "full name must be at most 100 characters")
+	}
+
+	for _, part := range strings.FieldsFunc(courseIDs, func(r rune) bool { return r == ';' || r == ',' || r == ' ' }) {
+		courseID, err := strconv.Atoi(part)
+		if err != nil || courseID <= 0 {
+			fail("course_ids", fmt.Sprintf("invalid course ID %q", part))
+			continue
+		}
+		row.CourseIDs = append(row.CourseIDs, courseID)
+	}
+
+	if row.Password != "" {
+		if err := policy.Validate(row.Password, row.Username, row.Email); err != nil {
+			fail("password", err.Error())
+		}
+	}
+
+	return errs
+}
+
+// sendAccountInviteEmail mails an imported user a link to set their password.
+// Failures are logged; the admin can resend by triggering a password reset.
+func (h *AdminHandler) sendAccountInviteEmail(user models.ImportedUser) {
+	ttl, err := durationFromEnv("USER_INVITE_EXPIRY", "72h")
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error reading invite expiry: %v", err)
+		return
+	}
+
+	token, err := models.CreatePasswordResetToken(h.db, user.ID, ttl)
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error creating invite token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := frontendURL() + "/reset-password?token=" + url.QueryEscape(token)
+	err = h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Akun LMS Anda telah dibuat",
+		Body: "Halo " + user.FullName + ",\n\n" +
+			"Akun LMS dengan username " + user.Username + " telah dibuat untuk Anda. " +
+			"Buka link berikut untuk membuat password:\n\n" + link + "\n\n" +
+			"Link ini berlaku selama " + ttl.String() + " dan hanya dapat digunakan sekali.\n",
+	})
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Error sending invite email to user %d: %v", user.ID, err)
+	}
+}
+
+// ExportUsers streams all users with their profile details and enrolled course
+// IDs as CSV (admin only). The columns match the import format where they overlap.
+// Text users entered is escaped against formula injection with csvText.
+func (h *AdminHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.csv"`, time.Now().Format("20060102")))
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"id", "username", "email", "full_name", "role", "course_ids", "email_verified", "created_at",
+		"phone", "location", "occupation", "education", "bio", "learning_style", "skill_level",
+		"email_notifications", "push_notifications", "weekly_reports",
+	})
+
+	flusher, _ := w.(http.Flusher)
+	count := 0
+	err := models.ForEachUserExportRow(h.db, func(user *models.UserExportRow) error {
+		courseIDs := make([]string, len(user.CourseIDs))
+		for i, id := range user.CourseIDs {
+			courseIDs[i] = strconv.FormatInt(id, 10)
+		}
+
+		writer.Write([]string{
+			strconv.Itoa(user.ID), csvText(user.Username), csvText(user.Email), csvText(user.FullName), user.Role,
+			strings.Join(courseIDs, ";"), strconv.FormatBool(user.EmailVerified),
+			user.CreatedAt.Format(time.RFC3339),
+			csvText(user.Phone.String), csvText(user.Location.String), csvText(user.Occupation.String),
+			csvText(user.Education.String), csvText(user.Bio.String), csvText(user.LearningStyle.String),
+			csvText(user.SkillLevel.String),
+			csvBool(user.EmailNotifications.Bool, user.EmailNotifications.Valid),
+			csvBool(user.PushNotifications.Bool, user.PushNotifications.Valid),
+			csvBool(user.WeeklyReports.Bool, user.WeeklyReports.Valid),
+		})
+
+		count++
+		if count%500 == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return writer.Error()
+	})
+	writer.Flush()
+
+	// Headers are already sent, so a failure can only be logged and the file is truncated
+	if err != nil {
+		log.Printf("[ADMIN ERROR] Failed to export users after %d rows: %v", count, err)
+	}
+}
+
+// csvText prefixes text that a spreadsheet would read as a formula with a quote
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvBool formats an optional boolean, leaving it empty when unset
+func csvBool(value, valid bool) string {
+	if !valid {
+		return ""
+	}
+	return strconv.FormatBool(value)
+}
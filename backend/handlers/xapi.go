@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lms-backend/middleware"
+	"lms-backend/models"
+	"lms-backend/xapi"
+
+	"github.com/google/uuid"
+)
+
+// maxXAPIRequestSize limits the statements posted in one request
+const maxXAPIRequestSize = 5 << 20
+
+// xapiForwarder sends stored statements to an external LRS; nil keeps them local
+var xapiForwarder *xapi.Forwarder
+
+// SetXAPIForwarder sets the external LRS statements are forwarded to
+func SetXAPIForwarder(forwarder *xapi.Forwarder) {
+	xapiForwarder = forwarder
+}
+
+// learnerAgent identifies a user in statements by their account on the LMS
+func learnerAgent(userID int) xapi.Agent {
+	return xapi.Agent{
+		ObjectType: "Agent",
+		Account:    &xapi.Account{HomePage: frontendURL(), Name: strconv.Itoa(userID)},
+	}
+}
+
+// lmsAuthority is the authority of the statements the LMS emits itself
+func lmsAuthority() xapi.Agent {
+	return xapi.Agent{
+		ObjectType: "Agent",
+		Name:       "LMS",
+		Account:    &xapi.Account{HomePage: frontendURL(), Name: "lms"},
+	}
+}
+
+// Activity IDs are the frontend URLs of courses and their content
+func courseActivity(courseID int, title string) xapi.Activity {
+	return xapi.NewActivity(fmt.Sprintf("%s/courses/%d", frontendURL(), courseID), xapi.ActivityTypeCourse, title)
+}
+
+func lessonActivity(courseID, lessonID int) xapi.Activity {
+	return xapi.NewActivity(fmt.Sprintf("%s/courses/%d/lessons/%d", frontendURL(), courseID, lessonID), xapi.ActivityTypeLesson, "")
+}
+
+func quizActivity(courseID, quizID int, title string) xapi.Activity {
+	return xapi.NewActivity(fmt.Sprintf("%s/courses/%d/quizzes/%d", frontendURL(), courseID, quizID), xapi.ActivityTypeAssessment, title)
+}
+
+func submissionActivity(courseID int, kind, title string) xapi.Activity {
+	return xapi.NewActivity(fmt.Sprintf("%s/courses/%d/%s", frontendURL(), courseID, kind), xapi.ActivityTypePerformance, title)
+}
+
+// inCourse sets a course as the parent context of a statement
+func inCourse(courseID int) *xapi.Context {
+	return &xapi.Context{ContextActivities: &xapi.ContextActivities{
+		Parent: []xapi.Activity{courseActivity(courseID, "")},
+	}}
+}
+
+// recordStatement stores a statement the LMS emits about a learner and
+// queues it for forwarding when an external LRS is configured. Failures are logged only:
+// tracking never fails the request that caused it.
+func recordStatement(database *sql.DB, userID int, statement xapi.Statement) {
+	statement.Actor = learnerAgent(userID)
+	raw, err := json.Marshal(statement)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode xAPI statement: %v", err)
+		return
+	}
+	prepared, err := xapi.Prepare(raw, lmsAuthority(), time.Now().UTC())
+	if err != nil {
+		log.Printf("[ERROR] Failed to prepare xAPI statement: %v", err)
+		return
+	}
+	if _, err := models.StoreXAPIStatement(database, prepared, &userID); err != nil {
+		log.Printf("[ERROR] Failed to store xAPI statement %s: %v", prepared.ID, err)
+		return
+	}
+	wakeXAPIForwarder()
+}
+
+// lessonCompleted reports whether a learner has already completed a lesson, so
+// a completion statement is only emitted the first time
+func lessonCompleted(database *sql.DB, userID, courseID, lessonID int) bool {
+	progress, err := models.GetLessonProgress(database, userID, courseID, lessonID)
+	return err == nil && progress.Completed
+}
+
+// recordLessonCompleted emits "completed" for a lesson
+func recordLessonCompleted(database *sql.DB, userID, courseID, lessonID, timeSpent int, score *xapi.Score) {
+	completion := true
+	recordStatement(database, userID, xapi.Statement{
+		Verb:    xapi.VerbCompleted,
+		Object:  lessonActivity(courseID, lessonID),
+		Result:  &xapi.Result{Completion: &completion, Score: score, Duration: xapi.Duration(timeSpent)},
+		Context: inCourse(courseID),
+	})
+}
+
+// recordQuizResult emits "passed" or "failed" with the score of a submitted
+// quiz attempt
+func recordQuizResult(database *sql.DB, attemptID int) {
+	attempt, err := models.GetXAPIAttempt(database, attemptID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get quiz attempt %d for xAPI: %v", attemptID, err)
+		return
+	}
+	verb := xapi.VerbFailed
+	if attempt.Passed {
+		verb = xapi.VerbPassed
+	}
+	completion := true
+	recordStatement(database, attempt.UserID, xapi.Statement{
+		Verb:   verb,
+		Object: quizActivity(attempt.CourseID, attempt.QuizID, attempt.QuizTitle),
+		Result: &xapi.Result{
+			Score:      xapi.PercentageScore(float64(attempt.Score)),
+			Success:    &attempt.Passed,
+			Completion: &completion,
+			Duration:   xapi.Duration(attempt.TimeSpent),
+		},
+		Context: inCourse(attempt.CourseID),
+	})
+}
+
+// recordSubmission emits "completed" for a postwork or final project submission
+func recordSubmission(database *sql.DB, userID, courseID int, kind, title string) {
+	completion := true
+	recordStatement(database, userID, xapi.Statement{
+		Verb:    xapi.VerbCompleted,
+		Object:  submissionActivity(courseID, kind, title),
+		Result:  &xapi.Result{Completion: &completion},
+		Context: inCourse(courseID),
+	})
+}
+
+// statementUserID returns the LMS user an actor's account identifies, if any
+func statementUserID(actor json.RawMessage) *int {
+	var agent xapi.Agent
+	if json.Unmarshal(actor, &agent) != nil || agent.Account == nil || agent.Account.HomePage != frontendURL() {
+		return nil
+	}
+	userID, err := strconv.Atoi(agent.Account.Name)
+	if err != nil || userID <= 0 {
+		return nil
+	}
+	return &userID
+}
+
+// GetStatements is the statements resource of the built-in LRS. Learners see
+// their own statements; xapi.manage can read everyone's. It supports the
+// statementId, verb, activity, agent, since, until, limit and ascending
+// parameters, and pages with the "more" URL of the result.
+func (h *Handler) GetStatements(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("X-Experience-API-Version", xapi.Version)
+
+	query := r.URL.Query()
+	filter := models.XAPIStatementFilter{
+		StatementID: query.Get("statementId"),
+		Verb:        query.Get("verb"),
+		Activity:    query.Get("activity"),
+		Ascending:   query.Get("ascending") == "true",
+	}
+	if filter.StatementID != "" {
+		if _, err := uuid.Parse(filter.StatementID); err != nil {
+			http.Error(w, "statementId must be a UUID", http.StatusBadRequest)
+			return
+		}
+	}
+	if !middleware.HasPermission(r, "xapi.manage") {
+		filter.UserID = &userID
+	}
+	if agent := query.Get("agent"); agent != "" {
+		if !json.Valid([]byte(agent)) {
+			http.Error(w, "agent must be a JSON object", http.StatusBadRequest)
+			return
+		}
+		filter.Agent = json.RawMessage(agent)
+	}
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				http.Error(w, name+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			parsed = parsed.UTC()
+			*target = &parsed
+		}
+	}
+	for name, target := range map[string]*int{"limit": &filter.Limit, "cursor": &filter.After} {
+		if value := query.Get(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+				http.Error(w, name+" must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	statements, more, err := models.GetXAPIStatements(h.DB, filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get xAPI statements: %v", err)
+		http.Error(w, "Failed to get statements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if filter.StatementID != "" {
+		if len(statements) == 0 {
+			http.Error(w, "Statement not found", http.StatusNotFound)
+			return
+		}
+		w.Write(statements[0])
+		return
+	}
+
+	moreURL := ""
+	if more > 0 {
+		query.Set("cursor", strconv.Itoa(more))
+		moreURL = r.URL.Path + "?" + query.Encode()
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"statements": statements,
+		"more":       moreURL,
+	})
+}
+
+// PostStatements stores one statement or an array of statements and returns
+// their ids. Learners may only store statements about themselves; xapi.manage
+// can store statements for any actor. Re-posting a stored statement is
+// accepted, a different statement with a stored id is a conflict.
+func (h *Handler) PostStatements(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("X-Experience-API-Version", xapi.Version)
+
+	var body json.RawMessage
+	r.Body = http.MaxBytesReader(w, r.Body, maxXAPIRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	raw := []json.RawMessage{body}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(raw) == 0 {
+		http.Error(w, "No statements to store", http.StatusBadRequest)
+		return
+	}
+
+	manager := middleware.HasPermission(r, "xapi.manage")
+	now := time.Now().UTC()
+	prepared := make([]*xapi.Prepared, 0, len(raw))
+	seen := make(map[string]bool)
+	for i, statement := range raw {
+		p, err := xapi.Prepare(statement, learnerAgent(userID), now)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("statement %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		if seen[p.ID] {
+			http.Error(w, fmt.Sprintf("statement %d: id %s is repeated", i, p.ID), http.StatusBadRequest)
+			return
+		}
+		seen[p.ID] = true
+		if actorID := statementUserID(p.Actor); !manager && (actorID == nil || *actorID != userID) {
+			http.Error(w, fmt.Sprintf("statement %d: actor must be the authenticated user", i), http.StatusForbidden)
+			return
+		}
+		prepared = append(prepared, p)
+	}
+
+	// Check every id before storing anything so a conflict rejects the batch
+	ids := make([]string, 0, len(prepared))
+	stored := make([]*xapi.Prepared, 0, len(prepared))
+	for _, p := range prepared {
+		ids = append(ids, p.ID)
+		matches, err := models.XAPIStatementMatches(h.DB, p)
+		if err == sql.ErrNoRows {
+			stored = append(stored, p)
+			continue
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to check xAPI statement %s: %v", p.ID, err)
+			http.Error(w, "Failed to store statements", http.StatusInternalServerError)
+			return
+		}
+		if !matches {
+			http.Error(w, fmt.Sprintf("A different statement with id %s is already stored", p.ID), http.StatusConflict)
+			return
+		}
+	}
+	for _, p := range stored {
+		if _, err := models.StoreXAPIStatement(h.DB, p, statementUserID(p.Actor)); err != nil {
+			log.Printf("[ERROR] Failed to store xAPI statement %s: %v", p.ID, err)
+			http.Error(w, "Failed to store statements", http.StatusInternalServerError)
+			return
+		}
+	}
+	wakeXAPIForwarder()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ids)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"lms-backend/models"
+	"lms-backend/xapi"
+)
+
+// Statements are forwarded in batches; a batch that fails is retried with
+// exponential backoff, and statements that failed xapiForwardMaxAttempts
+// times are given up so they do not hold up the rest. A batch the LRS rejects
+// as invalid is split to find the statements it rejects.
+const (
+	xapiForwardBatchSize   = 50
+	xapiForwardMaxAttempts = 10
+	xapiForwardMinBackoff  = time.Second
+	xapiForwardMaxBackoff  = 5 * time.Minute
+)
+
+// xapiForwardWake wakes the forwarding worker when statements are stored. It
+// holds at most one pending wake-up; the statements themselves wait in the
+// database.
+var xapiForwardWake = make(chan struct{}, 1)
+
+// wakeXAPIForwarder tells the forwarding worker new statements are stored
+func wakeXAPIForwarder() {
+	if xapiForwarder == nil {
+		return
+	}
+	select {
+	case xapiForwardWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartXAPIForwarder sends stored statements that have not reached the
+// external LRS, when one is configured. It runs when statements are stored
+// and every XAPI_FORWARD_INTERVAL, so statements that failed before, or were
+// stored while the LRS was down, are retried.
+func StartXAPIForwarder(database *sql.DB) {
+	if xapiForwarder == nil {
+		return
+	}
+	interval, err := durationFromEnv("XAPI_FORWARD_INTERVAL", "1m")
+	if err != nil || interval <= 0 {
+		log.Printf("[ERROR] Invalid XAPI_FORWARD_INTERVAL, using 1m")
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		backoff := xapiForwardMinBackoff
+		for {
+			sent, err := forwardXAPIBatch(database)
+			if err != nil {
+				log.Printf("[ERROR] Failed to forward xAPI statements, retrying in %s: %v", backoff, err)
+				time.Sleep(backoff)
+				if backoff *= 2; backoff > xapiForwardMaxBackoff {
+					backoff = xapiForwardMaxBackoff
+				}
+				continue
+			}
+			backoff = xapiForwardMinBackoff
+			if sent == xapiForwardBatchSize {
+				continue
+			}
+			select {
+			case <-xapiForwardWake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// forwardXAPIBatch sends the next batch of unforwarded statements and
+// returns how many it took, sent or rejected by the LRS
+func forwardXAPIBatch(database *sql.DB) (int, error) {
+	pending, err := models.GetUnforwardedXAPIStatements(database, xapiForwardBatchSize, xapiForwardMaxAttempts)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	if err := sendXAPIStatements(database, pending); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// sendXAPIStatements sends statements and marks them forwarded. A batch the
+// LRS rejects as invalid is split in halves until the statements it rejects
+// are on their own, so only those count a failed attempt; other failures
+// count against the whole batch and are returned.
+func sendXAPIStatements(database *sql.DB, pending []models.XAPIPendingStatement) error {
+	raw := make([]json.RawMessage, len(pending))
+	ids := make([]string, len(pending))
+	for i, statement := range pending {
+		raw[i] = statement.Statement
+		ids[i] = statement.StatementID
+	}
+
+	err := xapiForwarder.Send(raw)
+	if errors.Is(err, xapi.ErrStatementsRejected) && len(pending) > 1 {
+		half := len(pending) / 2
+		if err := sendXAPIStatements(database, pending[:half]); err != nil {
+			return err
+		}
+		return sendXAPIStatements(database, pending[half:])
+	}
+	if err != nil {
+		if err := models.RecordXAPIForwardFailure(database, ids); err != nil {
+			log.Printf("[ERROR] Failed to record xAPI forwarding failure: %v", err)
+		}
+		if errors.Is(err, xapi.ErrStatementsRejected) {
+			log.Printf("[ERROR] External LRS rejected xAPI statement %s: %v", ids[0], err)
+			return nil
+		}
+		return err
+	}
+	return models.MarkXAPIStatementsForwarded(database, ids)
+}
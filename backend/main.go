@@ -5,9 +5,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
 	"lms-backend/config"
 	"lms-backend/middleware"
+	"lms-backend/migrations"
 	"lms-backend/routes"
 	"lms-backend/seed"
 
@@ -21,6 +23,12 @@ func main() {
 		log.Println("Warning: .env file not found, using environment variables")
 	}
 
+	// Handle "migrate" subcommand without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	// Initialize database connection
 	db, err := config.InitDB()
 	if err != nil {
@@ -48,4 +56,52 @@ func main() {
 	// Start server
 	fmt.Printf("Server running on port %s\n", port)
 	log.Fatal(http.ListenAndServe(":"+port, handler))
-}
\ No newline at end of file
+}
+
+// runMigrate implements "lms-backend migrate up|down [n]|status"
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: lms-backend migrate up|down [n]|status")
+	}
+
+	db, err := config.OpenDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		if err := migrations.Up(db); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Println("Database is up to date")
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+		}
+		rolledBack, err := migrations.Down(db, steps)
+		if err != nil {
+			log.Fatalf("Rollback failed after %d migration(s): %v", rolledBack, err)
+		}
+		log.Printf("Rolled back %d migration(s)", rolledBack)
+	case "status":
+		statuses, err := migrations.Status(db)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Printf("%03d_%s\tapplied %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%03d_%s\tpending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		log.Fatalf("Unknown migrate command: %s", args[0])
+	}
+}
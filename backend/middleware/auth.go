@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -16,9 +18,10 @@ import (
 )
 
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
@@ -26,6 +29,9 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
+// AccessContextKey holds the *models.Access resolved for the authenticated user
+const AccessContextKey contextKey = "access"
+
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(user *models.User) (string, error) {
 	jwtSecret := os.Getenv("JWT_SECRET")
@@ -44,9 +50,10 @@ func GenerateJWT(user *models.User) (string, error) {
 	}
 
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
+		UserID:       user.ID,
+		Username:     user.Username,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -63,6 +70,21 @@ func GenerateJWT(user *models.User) (string, error) {
 	return tokenString, nil
 }
 
+// RefreshTokenExpiry returns the lifetime of refresh tokens from REFRESH_TOKEN_EXPIRY
+func RefreshTokenExpiry() (time.Duration, error) {
+	expiryStr := os.Getenv("REFRESH_TOKEN_EXPIRY")
+	if expiryStr == "" {
+		expiryStr = "720h"
+	}
+
+	expiry, err := time.ParseDuration(expiryStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid REFRESH_TOKEN_EXPIRY format: %v", err)
+	}
+
+	return expiry, nil
+}
+
 // ValidateJWT validates a JWT token and returns the claims
 func ValidateJWT(tokenString string) (*Claims, error) {
 	jwtSecret := os.Getenv("JWT_SECRET")
@@ -89,6 +111,69 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// ScopedClaims authorize one user to read one resource, such as the assets of a
+// SCORM package, where the Authorization header cannot be sent
+type ScopedClaims struct {
+	UserID int    `json:"user_id"`
+	Scope  string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// scopedTokenKey derives the signing key of scoped tokens from JWT_SECRET, so a
+// scoped token is never accepted as a login token
+func scopedTokenKey() ([]byte, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET not set")
+	}
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("scoped-token"))
+	return mac.Sum(nil), nil
+}
+
+// GenerateScopedToken generates a token that grants userID access to scope for ttl
+func GenerateScopedToken(userID int, scope string, ttl time.Duration) (string, error) {
+	key, err := scopedTokenKey()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &ScopedClaims{
+		UserID: userID,
+		Scope:  scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "lms-backend",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// ValidateScopedToken validates a scoped token for scope and returns its user ID
+func ValidateScopedToken(tokenString, scope string) (int, error) {
+	key, err := scopedTokenKey()
+	if err != nil {
+		return 0, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &ScopedClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse token: %v", err)
+	}
+
+	claims, ok := token.Claims.(*ScopedClaims)
+	if !ok || !token.Valid || claims.Scope != scope {
+		return 0, fmt.Errorf("invalid token")
+	}
+	return claims.UserID, nil
+}
+
 // AuthMiddleware validates JWT tokens for protected routes
 func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -129,30 +214,61 @@ func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Add user and user role to request context
+			// Reject tokens issued before the user's sessions were revoked
+			if claims.TokenVersion != user.TokenVersion {
+				fmt.Printf("[AUTH DEBUG] Token version %d does not match current version %d for user %d\n", claims.TokenVersion, user.TokenVersion, user.ID)
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
+
+			// Resolve the permissions granted by the user's role
+			access, err := models.GetAccessForUser(db, user)
+			if err != nil {
+				fmt.Printf("[AUTH DEBUG] Failed to load permissions for role %s: %v\n", user.Role, err)
+				http.Error(w, "Failed to load permissions", http.StatusInternalServerError)
+				return
+			}
+
+			// Add user, user role and permissions to request context
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
 			ctx = context.WithValue(ctx, "userRole", user.Role)
+			ctx = context.WithValue(ctx, AccessContextKey, access)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// AdminMiddleware ensures the user has admin role
-func AdminMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, ok := r.Context().Value(UserContextKey).(*models.User)
-		if !ok {
-			http.Error(w, "User not found in context", http.StatusInternalServerError)
-			return
-		}
+// RequirePermission ensures the user's role grants permission
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(UserContextKey).(*models.User)
+			if !ok {
+				http.Error(w, "User not found in context", http.StatusInternalServerError)
+				return
+			}
 
-		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
-			return
-		}
+			if !HasPermission(r, permission) {
+				http.Error(w, "Permission required: "+permission, http.StatusForbidden)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			// Optionally require admin accounts to have 2FA enabled; other roles
+			// granted permissions, like instructors, are not affected
+			if user.Role == "admin" && AdminTwoFactorRequired() && !user.TwoFactorEnabled {
+				http.Error(w, "Two-factor authentication must be enabled for admin accounts", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AdminTwoFactorRequired reports whether REQUIRE_ADMIN_2FA is enabled
+func AdminTwoFactorRequired() bool {
+	value, err := strconv.ParseBool(os.Getenv("REQUIRE_ADMIN_2FA"))
+	return err == nil && value
 }
 
 // GetUserFromContext extracts user from request context
@@ -164,6 +280,21 @@ func GetUserFromContext(r *http.Request) (*models.User, error) {
 	return user, nil
 }
 
+// GetAccessFromContext extracts the user's permissions from request context
+func GetAccessFromContext(r *http.Request) (*models.Access, error) {
+	access, ok := r.Context().Value(AccessContextKey).(*models.Access)
+	if !ok {
+		return nil, fmt.Errorf("access not found in context")
+	}
+	return access, nil
+}
+
+// HasPermission reports whether the authenticated user's role grants permission
+func HasPermission(r *http.Request, permission string) bool {
+	access, err := GetAccessFromContext(r)
+	return err == nil && access.Has(permission)
+}
+
 // GetUserIDFromContext extracts user ID from request context
 func GetUserIDFromContext(r *http.Request) (int, error) {
 	user, err := GetUserFromContext(r)
@@ -0,0 +1,23 @@
+-- Rollback: Baseline schema
+-- Drops every table created by 001_baseline_schema.up.sql. This destroys all data.
+
+DROP TABLE IF EXISTS course_stage_locks;
+DROP TABLE IF EXISTS user_details;
+DROP TABLE IF EXISTS survey_feedback;
+DROP TABLE IF EXISTS grades;
+DROP TABLE IF EXISTS file_uploads;
+DROP TABLE IF EXISTS final_project_submissions;
+DROP TABLE IF EXISTS postwork_submissions;
+DROP TABLE IF EXISTS quiz_attempts;
+DROP TABLE IF EXISTS quizzes;
+DROP TABLE IF EXISTS certificates;
+DROP TABLE IF EXISTS announcements;
+DROP TABLE IF EXISTS course_progress;
+DROP TABLE IF EXISTS lesson_progress;
+DROP TABLE IF EXISTS user_progress;
+DROP TABLE IF EXISTS course_enrollments;
+DROP TABLE IF EXISTS courses;
+DROP TABLE IF EXISTS users;
+
+DROP FUNCTION IF EXISTS update_updated_at_column();
+DROP FUNCTION IF EXISTS update_course_stage_locks_updated_at();
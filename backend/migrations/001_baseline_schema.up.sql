@@ -0,0 +1,309 @@
+-- Migration: Baseline schema
+-- Consolidates the tables previously created by config.createTables,
+-- seed.createNewTables and the hand-run SQL files into a single definition.
+-- Every statement is idempotent so databases created by the old code paths
+-- can adopt the migration runner without being rebuilt.
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    email VARCHAR(100) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    full_name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS courses (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    category VARCHAR(100),
+    level VARCHAR(50),
+    duration VARCHAR(50),
+    instructor VARCHAR(100),
+    rating DECIMAL(3,2) DEFAULT 0.0,
+    students INTEGER DEFAULT 0,
+    image VARCHAR(500),
+    intro_material JSONB,
+    lessons JSONB,
+    pre_test JSONB,
+    post_test JSONB,
+    post_work JSONB,
+    final_project JSONB,
+    has_post_work BOOLEAN DEFAULT TRUE,
+    has_final_project BOOLEAN DEFAULT TRUE,
+    certificate_delay INTEGER DEFAULT 7,
+    step_weights JSONB DEFAULT '{"intro": 5, "pretest": 10, "lessons": 30, "posttest": 15, "postwork": 20, "finalproject": 20}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS course_enrollments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+    enrolled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    progress INTEGER DEFAULT 0,
+    completed_at TIMESTAMP,
+    UNIQUE(user_id, course_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_progress (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+    lesson_id INTEGER NOT NULL,
+    completed BOOLEAN DEFAULT FALSE,
+    completed_at TIMESTAMP,
+    UNIQUE(user_id, course_id, lesson_id)
+);
+
+CREATE TABLE IF NOT EXISTS lesson_progress (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+    lesson_id INTEGER NOT NULL,
+    completed BOOLEAN DEFAULT FALSE,
+    progress INTEGER DEFAULT 0 CHECK (progress >= 0 AND progress <= 100),
+    time_spent INTEGER DEFAULT 0,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    UNIQUE(user_id, course_id, lesson_id)
+);
+
+CREATE TABLE IF NOT EXISTS course_progress (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+    current_step VARCHAR(50) DEFAULT 'intro',
+    completed_steps TEXT DEFAULT '[]',
+    overall_progress INTEGER DEFAULT 0 CHECK (overall_progress >= 0 AND overall_progress <= 100),
+    lessons_completed INTEGER DEFAULT 0,
+    total_lessons INTEGER DEFAULT 0,
+    quizzes_completed INTEGER DEFAULT 0,
+    total_quizzes INTEGER DEFAULT 0,
+    time_spent INTEGER DEFAULT 0,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    UNIQUE(user_id, course_id)
+);
+
+CREATE TABLE IF NOT EXISTS announcements (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    priority VARCHAR(20) DEFAULT 'normal' CHECK (priority IN ('normal', 'medium', 'high')),
+    target_audience VARCHAR(20) DEFAULT 'all' CHECK (target_audience IN ('all', 'users', 'admins')),
+    author VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS certificates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    cert_number VARCHAR(255) UNIQUE NOT NULL,
+    user_name VARCHAR(255) NOT NULL,
+    course_name VARCHAR(255) NOT NULL,
+    instructor VARCHAR(255) NOT NULL,
+    completion_date TIMESTAMP NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    approved_by INTEGER REFERENCES users(id),
+    approved_at TIMESTAMP,
+    rejection_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, course_id)
+);
+
+CREATE TABLE IF NOT EXISTS quizzes (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    lesson_id INTEGER,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    questions JSONB NOT NULL,
+    time_limit INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 1,
+    passing_score INTEGER DEFAULT 70,
+    quiz_type VARCHAR(20) DEFAULT 'quiz' CHECK (quiz_type IN ('pretest', 'posttest', 'quiz', 'lesson')),
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS quiz_attempts (
+    id SERIAL PRIMARY KEY,
+    quiz_id INTEGER NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    answers JSONB,
+    score INTEGER DEFAULT 0,
+    time_spent INTEGER DEFAULT 0,
+    completed BOOLEAN DEFAULT FALSE,
+    passed BOOLEAN DEFAULT FALSE,
+    attempt_number INTEGER DEFAULT 1,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    submitted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS postwork_submissions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    lesson_id INTEGER, -- NULL allowed for course-level PostWork
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    content TEXT,
+    attachments JSONB,
+    status VARCHAR(20) DEFAULT 'submitted' CHECK (status IN ('submitted', 'reviewed', 'approved', 'rejected')),
+    score INTEGER,
+    feedback TEXT,
+    submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    reviewed_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS final_project_submissions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    content TEXT,
+    attachments JSONB,
+    github_url VARCHAR(500),
+    live_url VARCHAR(500),
+    status VARCHAR(20) DEFAULT 'submitted' CHECK (status IN ('submitted', 'reviewed', 'approved', 'rejected')),
+    score INTEGER,
+    feedback TEXT,
+    submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    reviewed_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, course_id)
+);
+
+CREATE TABLE IF NOT EXISTS file_uploads (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    original_name VARCHAR(255) NOT NULL,
+    file_path VARCHAR(500) NOT NULL,
+    file_size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    file_type VARCHAR(50) NOT NULL,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- submission_id is nullable: grades can be given for a course as a whole,
+-- and graded_by is nullable because CreateGrade does not always know the grader.
+CREATE TABLE IF NOT EXISTS grades (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    submission_id INTEGER,
+    grade DECIMAL(5,2) NOT NULL CHECK (grade >= 0 AND grade <= 100),
+    feedback TEXT,
+    graded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    graded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS survey_feedback (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    rating INTEGER NOT NULL CHECK (rating >= 1 AND rating <= 5),
+    difficulty INTEGER DEFAULT 0 CHECK (difficulty >= 0 AND difficulty <= 5),
+    clarity INTEGER DEFAULT 0 CHECK (clarity >= 0 AND clarity <= 5),
+    usefulness INTEGER DEFAULT 0 CHECK (usefulness >= 0 AND usefulness <= 5),
+    feedback TEXT,
+    post_test_score INTEGER DEFAULT 0,
+    post_test_passed BOOLEAN DEFAULT FALSE,
+    submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, course_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_details (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(20),
+    location VARCHAR(255),
+    occupation VARCHAR(255),
+    education VARCHAR(255),
+    bio TEXT,
+    learning_style VARCHAR(50) CHECK (learning_style IN ('visual', 'auditory', 'kinesthetic', 'reading')),
+    skill_level VARCHAR(50) CHECK (skill_level IN ('beginner', 'intermediate', 'advanced', 'expert')),
+    email_notifications BOOLEAN DEFAULT TRUE,
+    push_notifications BOOLEAN DEFAULT TRUE,
+    weekly_reports BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS course_stage_locks (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    stage_name VARCHAR(50) NOT NULL,
+    is_locked BOOLEAN NOT NULL DEFAULT FALSE,
+    lock_message TEXT DEFAULT '',
+    locked_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    locked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(course_id, stage_name)
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_certificates_user_id ON certificates(user_id);
+CREATE INDEX IF NOT EXISTS idx_certificates_course_id ON certificates(course_id);
+CREATE INDEX IF NOT EXISTS idx_certificates_status ON certificates(status);
+CREATE INDEX IF NOT EXISTS idx_certificates_user_course_status ON certificates(user_id, course_id, status);
+CREATE INDEX IF NOT EXISTS idx_grades_user_id ON grades(user_id);
+CREATE INDEX IF NOT EXISTS idx_grades_course_id ON grades(course_id);
+CREATE INDEX IF NOT EXISTS idx_grades_submission_id ON grades(submission_id);
+CREATE INDEX IF NOT EXISTS idx_course_stage_locks_course_id ON course_stage_locks(course_id);
+CREATE INDEX IF NOT EXISTS idx_course_stage_locks_stage_name ON course_stage_locks(stage_name);
+CREATE INDEX IF NOT EXISTS idx_course_stage_locks_is_locked ON course_stage_locks(is_locked);
+CREATE INDEX IF NOT EXISTS idx_courses_config ON courses(has_post_work, has_final_project, certificate_delay);
+
+-- Keep updated_at current on tables that are edited in place
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_certificates_updated_at ON certificates;
+CREATE TRIGGER update_certificates_updated_at BEFORE UPDATE ON certificates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_grades_updated_at ON grades;
+CREATE TRIGGER update_grades_updated_at BEFORE UPDATE ON grades
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS trigger_update_course_stage_locks_updated_at ON course_stage_locks;
+DROP TRIGGER IF EXISTS update_course_stage_locks_updated_at ON course_stage_locks;
+CREATE TRIGGER update_course_stage_locks_updated_at BEFORE UPDATE ON course_stage_locks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN courses.has_post_work IS 'Whether this course includes post work assignments';
+COMMENT ON COLUMN courses.has_final_project IS 'Whether this course includes final project';
+COMMENT ON COLUMN courses.certificate_delay IS 'Certificate issuance delay in days (0 = immediate)';
+COMMENT ON COLUMN courses.step_weights IS 'JSON object containing step weights for progress calculation';
@@ -0,0 +1,3 @@
+-- Rollback: Reconcile legacy schema
+-- Intentionally empty. The reconciled columns and constraints are part of the
+-- baseline schema, so there is nothing to undo short of rolling back 001.
@@ -0,0 +1,41 @@
+-- Migration: Reconcile legacy schema
+-- Databases created before the migration runner may have been built by
+-- config.createTables, seed.createNewTables or the hand-run SQL files, which
+-- disagreed on a few columns and constraints. Bring them in line with
+-- 001_baseline_schema. On a fresh database every statement is a no-op.
+
+-- quizzes: lesson quizzes are created by the admin quiz endpoint
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS lesson_id INTEGER;
+ALTER TABLE quizzes DROP CONSTRAINT IF EXISTS quizzes_quiz_type_check;
+ALTER TABLE quizzes ADD CONSTRAINT quizzes_quiz_type_check
+    CHECK (quiz_type IN ('pretest', 'posttest', 'quiz', 'lesson'));
+
+-- postwork_submissions: lesson-level postwork and optional description
+ALTER TABLE postwork_submissions ADD COLUMN IF NOT EXISTS lesson_id INTEGER;
+ALTER TABLE postwork_submissions ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE postwork_submissions ALTER COLUMN content DROP NOT NULL;
+
+-- certificates: approval workflow columns
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS status VARCHAR(20) DEFAULT 'pending';
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS approved_by INTEGER REFERENCES users(id);
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+ALTER TABLE certificates ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
+CREATE INDEX IF NOT EXISTS idx_certificates_status ON certificates(status);
+CREATE INDEX IF NOT EXISTS idx_certificates_user_course_status ON certificates(user_id, course_id, status);
+
+-- courses: configuration columns
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS has_post_work BOOLEAN DEFAULT TRUE;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS has_final_project BOOLEAN DEFAULT TRUE;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS certificate_delay INTEGER DEFAULT 7;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS step_weights JSONB DEFAULT '{"intro": 5, "pretest": 10, "lessons": 30, "posttest": 15, "postwork": 20, "finalproject": 20}';
+UPDATE courses SET step_weights = '{"intro": 5, "pretest": 10, "lessons": 30, "posttest": 15, "postwork": 20, "finalproject": 20}'
+WHERE step_weights IS NULL;
+
+-- grades: course-level grades without a submission, optional grader, decimal grade
+ALTER TABLE grades ADD COLUMN IF NOT EXISTS graded_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE grades ALTER COLUMN submission_id DROP NOT NULL;
+ALTER TABLE grades ALTER COLUMN graded_by DROP NOT NULL;
+ALTER TABLE grades ALTER COLUMN grade TYPE DECIMAL(5,2);
+ALTER TABLE grades ALTER COLUMN graded_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE grades ALTER COLUMN graded_at DROP NOT NULL;
+ALTER TABLE grades DROP CONSTRAINT IF EXISTS grades_submission_id_course_id_key;
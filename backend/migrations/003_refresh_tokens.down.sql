@@ -0,0 +1,4 @@
+-- Rollback: Refresh tokens and session revocation
+
+DROP TABLE IF EXISTS refresh_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
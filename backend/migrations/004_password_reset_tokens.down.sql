@@ -0,0 +1,3 @@
+-- Rollback: Password reset tokens
+
+DROP TABLE IF EXISTS password_reset_tokens;
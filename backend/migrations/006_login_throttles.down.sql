@@ -0,0 +1,3 @@
+-- Rollback: Login throttling
+
+DROP TABLE IF EXISTS login_throttles;
@@ -0,0 +1,10 @@
+-- Migration: Login throttling
+-- One row per throttled key, e.g. "user:alice" or "ip:203.0.113.7".
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key VARCHAR(255) PRIMARY KEY,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    last_failed_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
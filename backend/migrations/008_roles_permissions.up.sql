@@ -0,0 +1,85 @@
+-- Migration: roles, permissions and per-course instructor assignment
+-- users.role now references the roles table instead of a fixed CHECK list.
+-- Course-scoped roles only act on courses they are assigned to in course_instructors.
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT,
+    scope VARCHAR(20) NOT NULL DEFAULT 'global' CHECK (scope IN ('global', 'course')),
+    is_system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_name VARCHAR(50) NOT NULL REFERENCES roles(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    permission_name VARCHAR(100) NOT NULL REFERENCES permissions(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    PRIMARY KEY (role_name, permission_name)
+);
+
+INSERT INTO roles (name, description, scope, is_system) VALUES
+    ('user', 'Learner', 'global', TRUE),
+    ('admin', 'Full access to every admin feature', 'global', TRUE),
+    ('instructor', 'Reviews, grades and certifies learners in assigned courses', 'course', TRUE),
+    ('grader', 'Reviews and grades submissions in assigned courses', 'course', TRUE),
+    ('content_editor', 'Edits course content and quizzes', 'global', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('courses.view', 'View courses and their tests in the admin area'),
+    ('courses.manage', 'Create and delete courses and assign instructors'),
+    ('courses.edit', 'Edit course content, configuration and stage locks'),
+    ('quizzes.manage', 'Create, edit and delete quizzes'),
+    ('submissions.view', 'View learner submissions and grades'),
+    ('submissions.grade', 'Grade learner submissions'),
+    ('test_results.view', 'View quiz and test results'),
+    ('surveys.view', 'View survey feedback'),
+    ('certificates.view', 'View certificates'),
+    ('certificates.approve', 'Approve and reject certificate requests'),
+    ('users.manage', 'Manage user accounts and user details'),
+    ('roles.manage', 'Manage roles and permissions'),
+    ('announcements.manage', 'Manage announcements'),
+    ('dashboard.view', 'View dashboard statistics')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('instructor', 'courses.view'),
+    ('instructor', 'submissions.view'),
+    ('instructor', 'submissions.grade'),
+    ('instructor', 'test_results.view'),
+    ('instructor', 'surveys.view'),
+    ('instructor', 'certificates.view'),
+    ('instructor', 'certificates.approve'),
+    ('grader', 'courses.view'),
+    ('grader', 'submissions.view'),
+    ('grader', 'submissions.grade'),
+    ('grader', 'test_results.view'),
+    ('content_editor', 'courses.view'),
+    ('content_editor', 'courses.edit'),
+    ('content_editor', 'quizzes.manage')
+ON CONFLICT DO NOTHING;
+
+-- Replace the fixed role list with a reference to roles
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(50);
+UPDATE users SET role = 'user' WHERE role IS NULL OR role NOT IN (SELECT name FROM roles);
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey
+    FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+
+CREATE TABLE IF NOT EXISTS course_instructors (
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (course_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_instructors_user_id ON course_instructors(user_id);
@@ -0,0 +1,4 @@
+-- Rollback: link existing courses to instructor accounts
+-- The backfilled assignments cannot be told apart from ones made later through the
+-- API, and course_instructors is dropped by the previous migration's rollback, so
+-- there is nothing to undo here.
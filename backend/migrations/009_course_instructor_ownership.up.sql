@@ -0,0 +1,15 @@
+-- Migration: link existing courses to instructor accounts
+-- Courses whose free-text instructor matches exactly one user's full name get that
+-- user assigned in course_instructors. courses.instructor is kept as the display
+-- fallback for courses without assigned instructors (e.g. external trainers).
+-- Roles are not changed; admins grant the instructor role explicitly.
+
+INSERT INTO course_instructors (course_id, user_id)
+SELECT c.id, MIN(u.id)
+FROM courses c
+JOIN users u ON LOWER(TRIM(u.full_name)) = LOWER(TRIM(c.instructor))
+WHERE TRIM(COALESCE(c.instructor, '')) <> ''
+GROUP BY c.id
+HAVING COUNT(u.id) = 1
+ON CONFLICT (course_id, user_id) DO NOTHING;
+
@@ -0,0 +1,6 @@
+-- Rollback: OpenID Connect single sign-on
+
+DROP TABLE IF EXISTS oidc_login_states;
+DROP INDEX IF EXISTS idx_users_oidc_identity;
+ALTER TABLE users DROP COLUMN IF EXISTS oidc_subject;
+ALTER TABLE users DROP COLUMN IF EXISTS oidc_issuer;
@@ -0,0 +1,19 @@
+-- Rebuild the courses.lessons JSONB blob from the lessons table. Lessons keep
+-- their current IDs so lesson_progress stays consistent with the JSON.
+
+ALTER TABLE courses ADD COLUMN lessons JSONB;
+
+UPDATE courses c SET lessons = COALESCE((
+    SELECT jsonb_agg(
+        l.metadata || jsonb_build_object('id', l.id, 'title', l.title, 'type', l.type, 'content', l.content)
+        ORDER BY l.position, l.id)
+    FROM lessons l
+    WHERE l.course_id = c.id
+), '[]'::jsonb);
+
+ALTER TABLE quizzes DROP CONSTRAINT IF EXISTS quizzes_lesson_id_fkey;
+ALTER TABLE postwork_submissions DROP CONSTRAINT IF EXISTS postwork_submissions_lesson_id_fkey;
+ALTER TABLE user_progress DROP CONSTRAINT IF EXISTS user_progress_lesson_id_fkey;
+ALTER TABLE lesson_progress DROP CONSTRAINT IF EXISTS lesson_progress_lesson_id_fkey;
+
+DROP TABLE IF EXISTS lessons;
@@ -0,0 +1,91 @@
+-- Migration: normalized lessons
+-- Lessons move out of the courses.lessons JSONB blob into their own table with
+-- stable IDs, so editing a course can no longer orphan lesson_progress rows.
+-- Keys other than id, title, type and content are kept in lessons.metadata.
+
+CREATE TABLE lessons (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    type VARCHAR(50) NOT NULL DEFAULT 'reading',
+    content JSONB NOT NULL DEFAULT '[]',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    legacy_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_lessons_course_position ON lessons(course_id, position);
+
+-- Start the new IDs above every lesson ID in use, so remapped rows can never
+-- collide with rows that still hold a legacy ID
+SELECT setval('lessons_id_seq', GREATEST(
+    1,
+    (SELECT COALESCE(MAX(lesson_id), 0) FROM lesson_progress),
+    (SELECT COALESCE(MAX(lesson_id), 0) FROM user_progress),
+    (SELECT COALESCE(MAX(lesson_id), 0) FROM postwork_submissions),
+    (SELECT COALESCE(MAX(lesson_id), 0) FROM quizzes),
+    (SELECT COALESCE(MAX((e.elem->>'id')::int), 0)
+     FROM courses c
+     CROSS JOIN LATERAL jsonb_array_elements(
+         CASE WHEN jsonb_typeof(c.lessons::jsonb) = 'array' THEN c.lessons::jsonb ELSE '[]'::jsonb END
+     ) AS e(elem)
+     WHERE e.elem->>'id' ~ '^[0-9]{1,9}$')
+));
+
+-- Extract lessons in their JSON order. Non-numeric or oversized IDs (e.g. client
+-- timestamps) cannot be referenced by progress rows, so they get no legacy ID.
+INSERT INTO lessons (course_id, position, title, type, content, metadata, legacy_id)
+SELECT c.id,
+       e.ord,
+       LEFT(COALESCE(e.elem->>'title', ''), 255),
+       COALESCE(NULLIF(e.elem->>'type', ''), 'reading'),
+       CASE
+           WHEN jsonb_typeof(e.elem->'content') = 'array' THEN e.elem->'content'
+           WHEN jsonb_typeof(e.elem->'content') IN ('string', 'object')
+               THEN jsonb_build_array(jsonb_build_object('type', 'text', 'content', e.elem->'content'))
+           ELSE '[]'::jsonb
+       END,
+       e.elem - 'id' - 'title' - 'type' - 'content' - 'position',
+       CASE WHEN e.elem->>'id' ~ '^[0-9]{1,9}$' THEN (e.elem->>'id')::int END
+FROM courses c
+CROSS JOIN LATERAL jsonb_array_elements(
+    CASE WHEN jsonb_typeof(c.lessons::jsonb) = 'array' THEN c.lessons::jsonb ELSE '[]'::jsonb END
+) WITH ORDINALITY AS e(elem, ord)
+WHERE jsonb_typeof(e.elem) = 'object'
+ORDER BY c.id, e.ord;
+
+-- Point existing rows at the new IDs. When a course repeated a legacy ID the
+-- first lesson with it wins.
+CREATE TEMPORARY TABLE lesson_id_map ON COMMIT DROP AS
+SELECT DISTINCT ON (course_id, legacy_id) course_id, legacy_id, id AS lesson_id
+FROM lessons
+WHERE legacy_id IS NOT NULL
+ORDER BY course_id, legacy_id, position;
+
+UPDATE lesson_progress t SET lesson_id = m.lesson_id
+FROM lesson_id_map m WHERE m.course_id = t.course_id AND m.legacy_id = t.lesson_id;
+
+UPDATE user_progress t SET lesson_id = m.lesson_id
+FROM lesson_id_map m WHERE m.course_id = t.course_id AND m.legacy_id = t.lesson_id;
+
+UPDATE postwork_submissions t SET lesson_id = m.lesson_id
+FROM lesson_id_map m WHERE m.course_id = t.course_id AND m.legacy_id = t.lesson_id;
+
+UPDATE quizzes t SET lesson_id = m.lesson_id
+FROM lesson_id_map m WHERE m.course_id = t.course_id AND m.legacy_id = t.lesson_id;
+
+-- NOT VALID keeps rows whose lesson was already removed from the JSON before this
+-- migration, while every new or updated row must reference an existing lesson
+ALTER TABLE lesson_progress ADD CONSTRAINT lesson_progress_lesson_id_fkey
+    FOREIGN KEY (lesson_id) REFERENCES lessons(id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE user_progress ADD CONSTRAINT user_progress_lesson_id_fkey
+    FOREIGN KEY (lesson_id) REFERENCES lessons(id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE postwork_submissions ADD CONSTRAINT postwork_submissions_lesson_id_fkey
+    FOREIGN KEY (lesson_id) REFERENCES lessons(id) ON DELETE SET NULL NOT VALID;
+ALTER TABLE quizzes ADD CONSTRAINT quizzes_lesson_id_fkey
+    FOREIGN KEY (lesson_id) REFERENCES lessons(id) ON DELETE SET NULL NOT VALID;
+
+ALTER TABLE lessons DROP COLUMN legacy_id;
+ALTER TABLE courses DROP COLUMN lessons;
@@ -0,0 +1,6 @@
+ALTER TABLE course_progress DROP COLUMN IF EXISTS module_progress;
+DELETE FROM course_stage_locks WHERE module_id IS NOT NULL;
+ALTER TABLE course_stage_locks DROP COLUMN IF EXISTS module_id;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS module_id;
+ALTER TABLE lessons DROP COLUMN IF EXISTS module_id;
+DROP TABLE IF EXISTS course_modules;
@@ -0,0 +1,29 @@
+-- Migration: course modules
+-- Modules (sections) group a course's lessons and optional quizzes. Lessons keep
+-- their course-wide position; a module lists its lessons in that order.
+-- Module locks reuse course_stage_locks with the stage name "module:<id>".
+
+CREATE TABLE course_modules (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_course_modules_course_position ON course_modules(course_id, position);
+
+ALTER TABLE lessons ADD COLUMN module_id INTEGER REFERENCES course_modules(id) ON DELETE SET NULL;
+CREATE INDEX idx_lessons_module_id ON lessons(module_id);
+
+ALTER TABLE quizzes ADD COLUMN module_id INTEGER REFERENCES course_modules(id) ON DELETE SET NULL;
+CREATE INDEX idx_quizzes_module_id ON quizzes(module_id);
+
+-- Removing a module removes its lock
+ALTER TABLE course_stage_locks ADD COLUMN module_id INTEGER REFERENCES course_modules(id) ON DELETE CASCADE;
+
+-- Per-module progress keyed by module ID:
+-- {"<id>": {"lessonsCompleted", "totalLessons", "quizzesPassed", "totalQuizzes", "progress"}}
+ALTER TABLE course_progress ADD COLUMN module_progress JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_courses_status;
+ALTER TABLE courses DROP CONSTRAINT IF EXISTS courses_publish_window_check;
+ALTER TABLE courses DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE courses DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE courses DROP COLUMN IF EXISTS status;
@@ -0,0 +1,15 @@
+-- Migration: course draft/publish workflow
+-- A course is visible to learners when it is published and inside its optional
+-- publish_at/unpublish_at window. Existing courses were already visible, so they
+-- start out published; new courses start as drafts.
+
+ALTER TABLE courses ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'review', 'published', 'archived'));
+ALTER TABLE courses ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE courses ADD COLUMN publish_at TIMESTAMP;
+ALTER TABLE courses ADD COLUMN unpublish_at TIMESTAMP;
+ALTER TABLE courses ADD CONSTRAINT courses_publish_window_check
+    CHECK (publish_at IS NULL OR unpublish_at IS NULL OR unpublish_at > publish_at);
+
+CREATE INDEX idx_courses_status ON courses(status);
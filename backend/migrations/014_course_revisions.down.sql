@@ -0,0 +1,2 @@
+ALTER TABLE course_enrollments DROP COLUMN IF EXISTS revision_id;
+DROP TABLE IF EXISTS course_revisions;
@@ -0,0 +1,41 @@
+-- Migration: course revisions
+-- Every save of a course stores an immutable snapshot of its content. Enrollments
+-- are pinned to the revision that was current when the learner enrolled.
+
+CREATE TABLE course_revisions (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    revision_number INTEGER NOT NULL,
+    content JSONB NOT NULL,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(course_id, revision_number)
+);
+
+-- Snapshot the current content of every course as its first revision
+INSERT INTO course_revisions (course_id, revision_number, content, note)
+SELECT c.id, 1, jsonb_build_object(
+        'title', c.title,
+        'description', c.description,
+        'category', c.category,
+        'level', c.level,
+        'duration', c.duration,
+        'image', c.image,
+        'introMaterial', c.intro_material,
+        'lessons', (SELECT COALESCE(jsonb_agg(
+                l.metadata || jsonb_build_object('id', l.id, 'moduleId', l.module_id, 'title', l.title, 'type', l.type, 'content', l.content)
+                ORDER BY l.position, l.id), '[]'::jsonb)
+            FROM lessons l WHERE l.course_id = c.id),
+        'preTest', c.pre_test,
+        'postTest', c.post_test,
+        'postWork', c.post_work,
+        'finalProject', c.final_project
+    ), 'Initial revision'
+FROM courses c;
+
+ALTER TABLE course_enrollments ADD COLUMN revision_id INTEGER REFERENCES course_revisions(id) ON DELETE SET NULL;
+
+UPDATE course_enrollments ce SET revision_id = r.id
+FROM course_revisions r
+WHERE r.course_id = ce.course_id AND r.revision_number = 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_courses_is_template;
+ALTER TABLE courses DROP COLUMN IF EXISTS is_template;
@@ -0,0 +1,6 @@
+-- Migration: template courses
+-- Templates are starting points for cloning and are never shown to learners,
+-- whatever their status.
+
+ALTER TABLE courses ADD COLUMN is_template BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX idx_courses_is_template ON courses(is_template) WHERE is_template;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scorm_runtime;
+DROP TABLE IF EXISTS scorm_packages;
@@ -0,0 +1,35 @@
+-- Migration: SCORM lessons
+-- A lesson of type "scorm" is backed by one uploaded SCORM 1.2, SCORM 2004 or
+-- cmi5 package, extracted under storage_path. Learners' runtime (cmi) data is
+-- kept per package; completion is copied into lesson_progress.
+
+CREATE TABLE scorm_packages (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    lesson_id INTEGER NOT NULL UNIQUE REFERENCES lessons(id) ON DELETE CASCADE,
+    version VARCHAR(10) NOT NULL CHECK (version IN ('1.2', '2004', 'cmi5')),
+    identifier VARCHAR(255) NOT NULL DEFAULT '',
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    launch_path TEXT NOT NULL,
+    storage_path TEXT NOT NULL,
+    mastery_score INTEGER, -- percentage, from the manifest
+    uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scorm_packages_course_id ON scorm_packages(course_id);
+
+CREATE TABLE scorm_runtime (
+    id SERIAL PRIMARY KEY,
+    package_id INTEGER NOT NULL REFERENCES scorm_packages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    cmi JSONB NOT NULL DEFAULT '{}', -- every cmi element the package has set
+    lesson_status VARCHAR(20) NOT NULL DEFAULT 'not attempted',
+    score_raw NUMERIC,
+    suspend_data TEXT NOT NULL DEFAULT '',
+    total_time INTEGER NOT NULL DEFAULT 0, -- seconds
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(package_id, user_id)
+);
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission_name = 'xapi.manage';
+DELETE FROM permissions WHERE name = 'xapi.manage';
+DROP TABLE IF EXISTS xapi_statements;
@@ -0,0 +1,29 @@
+-- Migration: xAPI statements
+-- Local statement store of the built-in LRS. Statements emitted by the LMS
+-- and statements posted to /api/protected/xapi/statements are kept as JSON;
+-- the columns next to it exist for filtering. forwarded_at is set once a
+-- statement has been sent to the external LRS (XAPI_FORWARD_ENDPOINT).
+
+CREATE TABLE xapi_statements (
+    id SERIAL PRIMARY KEY,
+    statement_id UUID NOT NULL UNIQUE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    verb_id TEXT NOT NULL,
+    object_id TEXT NOT NULL DEFAULT '',
+    statement JSONB NOT NULL,
+    stored_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    forwarded_at TIMESTAMP
+);
+
+CREATE INDEX idx_xapi_statements_user_id ON xapi_statements(user_id);
+CREATE INDEX idx_xapi_statements_verb_id ON xapi_statements(verb_id);
+CREATE INDEX idx_xapi_statements_object_id ON xapi_statements(object_id);
+CREATE INDEX idx_xapi_statements_stored_at ON xapi_statements(stored_at);
+
+INSERT INTO permissions (name, description) VALUES
+    ('xapi.manage', 'Read all xAPI statements and store statements for any learner')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('admin', 'xapi.manage')
+ON CONFLICT DO NOTHING;
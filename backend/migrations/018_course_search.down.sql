@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS refresh_course_search_on_instructor_name ON users;
+DROP TRIGGER IF EXISTS refresh_course_search_on_instructors ON course_instructors;
+DROP TRIGGER IF EXISTS refresh_course_search_on_lessons ON lessons;
+DROP TRIGGER IF EXISTS update_courses_search_vector ON courses;
+DROP FUNCTION IF EXISTS refresh_instructor_course_search_vectors();
+DROP FUNCTION IF EXISTS refresh_course_search_vector();
+DROP FUNCTION IF EXISTS update_course_search_vector();
+DROP INDEX IF EXISTS idx_courses_search_vector;
+ALTER TABLE courses DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS lesson_search_text(TEXT, JSONB);
@@ -0,0 +1,98 @@
+-- Migration: full-text course search
+-- courses.search_vector indexes the title (weight A), category and instructor
+-- names (B), description (C) and the text of every lesson (D). It uses the
+-- 'simple' configuration since course content mixes Indonesian and English.
+-- Triggers keep it current when a course, one of its lessons, its instructor
+-- assignments or an instructor's name changes.
+
+-- lesson_search_text returns the readable text of a lesson: its title and the
+-- title, content, description and items of its content blocks
+CREATE OR REPLACE FUNCTION lesson_search_text(lesson_title TEXT, lesson_content JSONB)
+RETURNS TEXT AS $$
+    SELECT concat_ws(' ', lesson_title, (
+        SELECT string_agg(concat_ws(' ',
+            block->>'title', block->>'content', block->>'description',
+            (SELECT string_agg(item, ' ')
+             FROM jsonb_array_elements_text(
+                CASE WHEN jsonb_typeof(block->'items') = 'array' THEN block->'items' ELSE '[]'::jsonb END
+             ) item)
+        ), ' ')
+        FROM jsonb_array_elements(
+            CASE WHEN jsonb_typeof(lesson_content) = 'array' THEN lesson_content ELSE '[]'::jsonb END
+        ) block
+        WHERE jsonb_typeof(block) = 'object'
+    ))
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE courses ADD COLUMN search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION update_course_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('simple', COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('simple', concat_ws(' ', NEW.category, NEW.instructor, (
+            SELECT string_agg(u.full_name, ' ')
+            FROM course_instructors ci
+            JOIN users u ON u.id = ci.user_id
+            WHERE ci.course_id = NEW.id
+        ))), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(NEW.description, '')), 'C') ||
+        setweight(to_tsvector('simple', COALESCE((
+            SELECT string_agg(lesson_search_text(l.title, l.content), ' ')
+            FROM lessons l
+            WHERE l.course_id = NEW.id
+        ), '')), 'D');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Setting search_vector (to anything) recomputes it, which is how the triggers
+-- below refresh a course
+CREATE TRIGGER update_courses_search_vector
+    BEFORE INSERT OR UPDATE OF title, description, category, instructor, search_vector ON courses
+    FOR EACH ROW EXECUTE FUNCTION update_course_search_vector();
+
+-- refresh_course_search_vector refreshes the course of a changed lesson or
+-- instructor assignment
+CREATE OR REPLACE FUNCTION refresh_course_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE courses SET search_vector = NULL WHERE id = OLD.course_id;
+    END IF;
+    IF TG_OP = 'INSERT' THEN
+        UPDATE courses SET search_vector = NULL WHERE id = NEW.course_id;
+    ELSIF TG_OP = 'UPDATE' THEN
+        IF NEW.course_id <> OLD.course_id THEN
+            UPDATE courses SET search_vector = NULL WHERE id = NEW.course_id;
+        END IF;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER refresh_course_search_on_lessons
+    AFTER INSERT OR UPDATE OF title, content, course_id OR DELETE ON lessons
+    FOR EACH ROW EXECUTE FUNCTION refresh_course_search_vector();
+
+CREATE TRIGGER refresh_course_search_on_instructors
+    AFTER INSERT OR UPDATE OR DELETE ON course_instructors
+    FOR EACH ROW EXECUTE FUNCTION refresh_course_search_vector();
+
+CREATE OR REPLACE FUNCTION refresh_instructor_course_search_vectors()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE courses SET search_vector = NULL
+    WHERE id IN (SELECT course_id FROM course_instructors WHERE user_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER refresh_course_search_on_instructor_name
+    AFTER UPDATE OF full_name ON users
+    FOR EACH ROW EXECUTE FUNCTION refresh_instructor_course_search_vectors();
+
+UPDATE courses SET search_vector = NULL;
+
+CREATE INDEX idx_courses_search_vector ON courses USING GIN (search_vector);
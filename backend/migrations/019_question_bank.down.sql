@@ -0,0 +1,4 @@
+ALTER TABLE quiz_attempts DROP COLUMN IF EXISTS question_set;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS shuffle_options;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS draw_rules;
+DROP TABLE IF EXISTS questions;
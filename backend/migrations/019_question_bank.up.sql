@@ -0,0 +1,24 @@
+-- Migration: question bank
+-- Reusable questions of a course, tagged by topic and difficulty. A quiz with
+-- draw_rules assembles every attempt from the bank instead of using its own
+-- questions. The questions an attempt got, in the order and with the option
+-- order it showed them, are kept in quiz_attempts.question_set so grading
+-- never depends on later edits to the bank or the quiz.
+
+CREATE TABLE questions (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    topic VARCHAR(100) NOT NULL DEFAULT '',
+    difficulty VARCHAR(20) NOT NULL DEFAULT 'medium' CHECK (difficulty IN ('easy', 'medium', 'hard')),
+    question JSONB NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_questions_course_topic ON questions(course_id, topic, difficulty);
+
+ALTER TABLE quizzes ADD COLUMN draw_rules JSONB;
+ALTER TABLE quizzes ADD COLUMN shuffle_options BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE quiz_attempts ADD COLUMN question_set JSONB;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_quiz_attempts_open_expiry;
+ALTER TABLE quiz_attempts DROP COLUMN IF EXISTS auto_submitted;
+ALTER TABLE quiz_attempts DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,11 @@
+-- Migration: quiz attempt deadline
+-- An attempt at a quiz with a time limit gets its deadline when it starts.
+-- Submissions after the deadline are refused and the attempt is graded with
+-- the answers saved before it; abandoned attempts are closed the same way by
+-- a background sweeper, which marks them auto_submitted. Attempts started
+-- before this migration keep no deadline.
+
+ALTER TABLE quiz_attempts ADD COLUMN expires_at TIMESTAMP;
+ALTER TABLE quiz_attempts ADD COLUMN auto_submitted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_quiz_attempts_open_expiry ON quiz_attempts(expires_at) WHERE completed = FALSE;
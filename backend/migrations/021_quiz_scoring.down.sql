@@ -0,0 +1,4 @@
+ALTER TABLE quiz_attempts DROP COLUMN IF EXISTS max_points;
+ALTER TABLE quiz_attempts DROP COLUMN IF EXISTS points;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS score_rounding;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS negative_marking;
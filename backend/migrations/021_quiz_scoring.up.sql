@@ -0,0 +1,12 @@
+-- Migration: weighted quiz scoring
+-- Questions are worth their points (1 when unset) and a quiz's score is the
+-- percentage of the points available, rounded per score_rounding. With
+-- negative_marking, a wrong answer deducts that share of the question's
+-- points. Attempts keep the points they earned next to the percentage; older
+-- attempts have no points.
+
+ALTER TABLE quizzes ADD COLUMN negative_marking NUMERIC(4,3) NOT NULL DEFAULT 0 CHECK (negative_marking >= 0 AND negative_marking <= 1);
+ALTER TABLE quizzes ADD COLUMN score_rounding VARCHAR(10) NOT NULL DEFAULT 'floor' CHECK (score_rounding IN ('floor', 'round', 'ceil'));
+
+ALTER TABLE quiz_attempts ADD COLUMN points NUMERIC(10,2);
+ALTER TABLE quiz_attempts ADD COLUMN max_points NUMERIC(10,2);
@@ -0,0 +1,2 @@
+ALTER TABLE courses ALTER COLUMN unpublish_at TYPE TIMESTAMP USING unpublish_at AT TIME ZONE 'UTC';
+ALTER TABLE courses ALTER COLUMN publish_at TYPE TIMESTAMP USING publish_at AT TIME ZONE 'UTC';
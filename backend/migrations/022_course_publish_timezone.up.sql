@@ -0,0 +1,7 @@
+-- Migration: time zone aware publishing window
+-- publish_at/unpublish_at were stored without a time zone, so a schedule sent
+-- with an offset lost it and was compared with CURRENT_TIMESTAMP in the
+-- session's zone. Existing values were written as UTC and are read as such.
+
+ALTER TABLE courses ALTER COLUMN publish_at TYPE TIMESTAMPTZ USING publish_at AT TIME ZONE 'UTC';
+ALTER TABLE courses ALTER COLUMN unpublish_at TYPE TIMESTAMPTZ USING unpublish_at AT TIME ZONE 'UTC';
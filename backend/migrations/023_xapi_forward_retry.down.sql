@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_xapi_statements_unforwarded;
+ALTER TABLE xapi_statements DROP COLUMN IF EXISTS forward_attempts;
@@ -0,0 +1,8 @@
+-- Migration: retried xAPI forwarding
+-- Statements not yet accepted by the external LRS are retried by a background
+-- worker. forward_attempts counts failed sends; statements that keep failing
+-- are given up after a number of attempts so they do not hold up the rest.
+
+ALTER TABLE xapi_statements ADD COLUMN forward_attempts INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_xapi_statements_unforwarded ON xapi_statements(forward_attempts, id) WHERE forwarded_at IS NULL;
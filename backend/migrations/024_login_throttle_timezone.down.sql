@@ -0,0 +1,2 @@
+ALTER TABLE login_throttles ALTER COLUMN last_failed_at TYPE TIMESTAMP;
+ALTER TABLE login_throttles ALTER COLUMN locked_until TYPE TIMESTAMP;
@@ -0,0 +1,8 @@
+-- Migration: time zone aware login lockouts
+-- locked_until and last_failed_at are written from the application clock.
+-- Stored without a time zone they lost the server's offset and were read back
+-- as UTC, so lockouts were off by that offset. Existing values were written in
+-- the server's local time, which the conversion assumes is the session's zone.
+
+ALTER TABLE login_throttles ALTER COLUMN locked_until TYPE TIMESTAMPTZ;
+ALTER TABLE login_throttles ALTER COLUMN last_failed_at TYPE TIMESTAMPTZ;
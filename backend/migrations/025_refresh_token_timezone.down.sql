@@ -0,0 +1 @@
+ALTER TABLE refresh_tokens ALTER COLUMN expires_at TYPE TIMESTAMP;
@@ -0,0 +1,8 @@
+-- Migration: time zone aware refresh token expiry
+-- expires_at is written from the application clock. Stored without a time
+-- zone it lost the server's offset and was read back as UTC, so refresh tokens
+-- lived longer or shorter than their TTL by that offset.
+-- Existing values were written in the server's local time, which the
+-- conversion assumes is the session's zone.
+
+ALTER TABLE refresh_tokens ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
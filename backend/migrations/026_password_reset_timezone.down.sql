@@ -0,0 +1 @@
+ALTER TABLE password_reset_tokens ALTER COLUMN expires_at TYPE TIMESTAMP;
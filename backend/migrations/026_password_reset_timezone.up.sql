@@ -0,0 +1,7 @@
+-- Migration: time zone aware password reset expiry
+-- Reset links expire at a time computed by the application. Without a time
+-- zone the stored expiry dropped the server's offset and came back as UTC, so
+-- links expired early or stayed valid past PASSWORD_RESET_EXPIRY. Existing rows
+-- are converted in the session's zone.
+
+ALTER TABLE password_reset_tokens ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE email_verification_tokens ALTER COLUMN expires_at TYPE TIMESTAMP;
@@ -0,0 +1,7 @@
+-- Migration: time zone aware email verification expiry
+-- Verification links were stored with an expiry in the server's local time
+-- but without its offset, and read back as UTC: outside UTC they expired
+-- early or late by the offset. Existing rows are converted in the session's
+-- zone.
+
+ALTER TABLE email_verification_tokens ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE xapi_statements ALTER COLUMN forwarded_at TYPE TIMESTAMP;
+ALTER TABLE xapi_statements ALTER COLUMN stored_at TYPE TIMESTAMP;
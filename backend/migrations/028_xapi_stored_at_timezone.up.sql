@@ -0,0 +1,8 @@
+-- Migration: time zone aware xAPI storage times
+-- stored_at and forwarded_at were set to CURRENT_TIMESTAMP in the session's
+-- zone but kept without it, while since/until filters are sent as instants.
+-- Outside UTC the filters were off by the zone's offset. Existing values are
+-- converted in the session's zone, the zone they were written in.
+
+ALTER TABLE xapi_statements ALTER COLUMN stored_at TYPE TIMESTAMPTZ;
+ALTER TABLE xapi_statements ALTER COLUMN forwarded_at TYPE TIMESTAMPTZ;
@@ -55,33 +55,45 @@ func CreateAnnouncement(db *sql.DB, req CreateAnnouncementRequest, author string
 	return announcement, nil
 }
 
-// GetAllAnnouncements retrieves all announcements
-func GetAllAnnouncements(db *sql.DB) ([]Announcement, error) {
-	query := `
-		SELECT id, title, content, priority, target_audience, author, created_at, updated_at
-		FROM announcements
-		ORDER BY created_at DESC
-	`
+// AnnouncementListSpec are the sort and filter fields of the announcement list
+var AnnouncementListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"id":             {Column: "id", Type: ListNumber},
+		"title":          {Column: "title", Type: ListText},
+		"priority":       {Column: "priority", Type: ListText},
+		"targetAudience": {Column: "target_audience", Type: ListText},
+		"author":         {Column: "author", Type: ListText},
+		"createdAt":      {Column: "created_at", Type: ListTime},
+		"updatedAt":      {Column: "updated_at", Type: ListTime},
+	},
+	SearchColumns: []string{"title", "content", "author"},
+	DefaultSort:   "-createdAt",
+	IDColumn:      "id",
+}
 
-	rows, err := db.Query(query)
+// GetAllAnnouncements retrieves a page of announcements
+func GetAllAnnouncements(db *sql.DB, list *ListQuery) ([]Announcement, *ListPage, error) {
+	rows, page, err := list.Query(db,
+		"id, title, content, priority, target_audience, author, created_at, updated_at",
+		"announcements", nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var announcements []Announcement
+	announcements := []Announcement{}
 	for rows.Next() {
 		var announcement Announcement
 		err := rows.Scan(&announcement.ID, &announcement.Title, &announcement.Content,
 			&announcement.Priority, &announcement.TargetAudience, &announcement.Author,
 			&announcement.CreatedAt, &announcement.UpdatedAt)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		announcements = append(announcements, announcement)
 	}
 
-	return announcements, nil
+	return announcements, page, rows.Err()
 }
 
 // GetAnnouncementsByAudience retrieves announcements for specific audience
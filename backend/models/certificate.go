@@ -190,11 +190,13 @@ func RequestCertificate(db *sql.DB, userID, courseID int) error {
 	return CreateCertificate(db, cert)
 }
 
-// ApproveCertificate approves a pending certificate
+// ApproveCertificate approves a pending certificate. The instructor names are
+// refreshed from the course's assigned instructors at approval time.
 func ApproveCertificate(db *sql.DB, certificateID, approvedBy int) error {
 	query := `
 		UPDATE certificates 
-		SET status = 'approved', approved_by = $1, approved_at = $2, updated_at = $2
+		SET status = 'approved', approved_by = $1, approved_at = $2, updated_at = $2,
+		    instructor = ` + InstructorNamesSQL("certificates.course_id", "certificates.instructor") + `
 		WHERE id = $3 AND status = 'pending'
 	`
 
@@ -216,56 +218,70 @@ func RejectCertificate(db *sql.DB, certificateID, rejectedBy int, reason string)
 	return err
 }
 
-// GetPendingCertificates retrieves all pending certificates for admin approval
-func GetPendingCertificates(db *sql.DB) ([]Certificate, error) {
-	query := `
-		SELECT id, user_id, course_id, cert_number, user_name, course_name, instructor,
-		       completion_date, issued_at, status, approved_by, approved_at, rejection_reason, created_at, updated_at
-		FROM certificates
-		WHERE status = 'pending'
-		ORDER BY created_at ASC
-	`
+// GetCertificateCourseID returns the course a certificate belongs to
+func GetCertificateCourseID(db *sql.DB, certificateID int) (int, error) {
+	var courseID int
+	err := db.QueryRow(`SELECT course_id FROM certificates WHERE id = $1`, certificateID).Scan(&courseID)
+	return courseID, err
+}
 
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// CertificateListSpec are the sort and filter fields of the admin certificate list
+var CertificateListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"id":             {Column: "id", Type: ListNumber},
+		"userId":         {Column: "user_id", Type: ListNumber},
+		"courseId":       {Column: "course_id", Type: ListNumber},
+		"certNumber":     {Column: "cert_number", Type: ListText},
+		"userName":       {Column: "user_name", Type: ListText},
+		"courseName":     {Column: "course_name", Type: ListText},
+		"instructor":     {Column: "instructor", Type: ListText},
+		"status":         {Column: "status", Type: ListText},
+		"approvedBy":     {Column: "approved_by", Type: ListNumber},
+		"completionDate": {Column: "completion_date", Type: ListTime},
+		"issuedAt":       {Column: "issued_at", Type: ListTime},
+		"approvedAt":     {Column: "approved_at", Type: ListTime},
+		"createdAt":      {Column: "created_at", Type: ListTime},
+		"updatedAt":      {Column: "updated_at", Type: ListTime},
+	},
+	SearchColumns: []string{"cert_number", "user_name", "course_name"},
+	DefaultSort:   "-createdAt",
+	IDColumn:      "id",
+}
 
-	var certificates []Certificate
-	for rows.Next() {
-		var cert Certificate
-		err := rows.Scan(
-			&cert.ID, &cert.UserID, &cert.CourseID, &cert.CertNumber, &cert.UserName,
-			&cert.CourseName, &cert.Instructor, &cert.CompletionDate, &cert.IssuedAt,
-			&cert.Status, &cert.ApprovedBy, &cert.ApprovedAt, &cert.RejectionReason,
-			&cert.CreatedAt, &cert.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		certificates = append(certificates, cert)
-	}
+// PendingCertificateListSpec are the sort and filter fields of the pending
+// certificate list, oldest first
+var PendingCertificateListSpec = ListSpec{
+	Fields:        CertificateListSpec.Fields,
+	SearchColumns: CertificateListSpec.SearchColumns,
+	DefaultSort:   "createdAt",
+	IDColumn:      "id",
+}
 
-	return certificates, nil
+// GetAllCertificates retrieves a page of certificates for admin management.
+// A non-zero instructorID limits the result to that instructor's courses.
+func GetAllCertificates(db *sql.DB, instructorID int, list *ListQuery) ([]Certificate, *ListPage, error) {
+	return listCertificates(db, list, []string{InstructorCourseFilter("course_id", "$1")}, instructorID)
 }
 
-// GetAllCertificates retrieves all certificates for admin management
-func GetAllCertificates(db *sql.DB) ([]Certificate, error) {
-	query := `
-		SELECT id, user_id, course_id, cert_number, user_name, course_name, instructor,
-		       completion_date, issued_at, status, approved_by, approved_at, rejection_reason, created_at, updated_at
-		FROM certificates
-		ORDER BY created_at DESC
-	`
+// GetPendingCertificates retrieves a page of pending certificates for admin
+// approval. A non-zero instructorID limits the result to that instructor's courses.
+func GetPendingCertificates(db *sql.DB, instructorID int, list *ListQuery) ([]Certificate, *ListPage, error) {
+	where := []string{"status = 'pending'", InstructorCourseFilter("course_id", "$1")}
+	return listCertificates(db, list, where, instructorID)
+}
+
+// listCertificates retrieves a page of the certificates matching where
+func listCertificates(db *sql.DB, list *ListQuery, where []string, args ...interface{}) ([]Certificate, *ListPage, error) {
+	columns := `id, user_id, course_id, cert_number, user_name, course_name, instructor,
+		       completion_date, issued_at, status, approved_by, approved_at, rejection_reason, created_at, updated_at`
 
-	rows, err := db.Query(query)
+	rows, page, err := list.Query(db, columns, "certificates", where, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var certificates []Certificate
+	certificates := []Certificate{}
 	for rows.Next() {
 		var cert Certificate
 		err := rows.Scan(
@@ -275,12 +291,12 @@ func GetAllCertificates(db *sql.DB) ([]Certificate, error) {
 			&cert.CreatedAt, &cert.UpdatedAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		certificates = append(certificates, cert)
 	}
 
-	return certificates, nil
+	return certificates, page, rows.Err()
 }
 
 // generateCertificateNumber generates a unique certificate number
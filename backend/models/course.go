@@ -20,6 +20,7 @@ type Course struct {
 	Image         string           `json:"image"`
 	IntroMaterial *json.RawMessage `json:"introMaterial,omitempty"`
 	Lessons       *json.RawMessage `json:"lessons,omitempty"`
+	Modules       *json.RawMessage `json:"modules,omitempty"` // only loaded by GetCourseByID
 	PreTest       *json.RawMessage `json:"preTest,omitempty"`
 	PostTest      *json.RawMessage `json:"postTest,omitempty"`
 	PostWork      *json.RawMessage `json:"postWork,omitempty"`
@@ -29,6 +30,14 @@ type Course struct {
 	HasFinalProject  bool             `json:"hasFinalProject"`
 	CertificateDelay int              `json:"certificateDelay"` // dalam hari (0 = immediate)
 	StepWeights      *json.RawMessage `json:"stepWeights,omitempty"` // dynamic step weights
+	// Publishing workflow: draft, review, published or archived
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publishAt,omitempty"`
+	UnpublishAt *time.Time `json:"unpublishAt,omitempty"`
+	// IsTemplate marks a course used as a starting point for clones; never shown to learners
+	IsTemplate bool `json:"isTemplate"`
+	// RevisionID is the revision an enrolled learner is reading, see GetEnrollmentRevision
+	RevisionID *int `json:"revisionId,omitempty"`
 	CreatedAt        time.Time        `json:"createdAt"`
 	UpdatedAt        time.Time        `json:"updatedAt"`
 }
@@ -60,14 +69,18 @@ type UserProgress struct {
 	CompletedAt *time.Time `json:"completedAt,omitempty"`
 }
 
-// GetAllCourses retrieves all courses from database
+// GetAllCourses retrieves the courses currently visible to learners
 func GetAllCourses(db *sql.DB) ([]Course, error) {
 	query := `
-		SELECT id, title, description, category, level, duration, instructor,
-		       rating, students, image, intro_material, lessons, pre_test,
+		SELECT id, title, description, category, level, duration,
+		       ` + InstructorNamesSQL("courses.id", "courses.instructor") + ` AS instructor,
+		       rating, students, image, intro_material,
+		       ` + LessonsJSONSQL("courses.id") + ` AS lessons, pre_test,
 		       post_test, post_work, final_project, has_post_work, has_final_project,
-		       certificate_delay, step_weights, created_at, updated_at
+		       certificate_delay, step_weights, status, publish_at, unpublish_at, is_template,
+		       created_at, updated_at
 		FROM courses
+		WHERE ` + CourseVisibleSQL("courses") + `
 		ORDER BY created_at DESC
 	`
 
@@ -86,7 +99,8 @@ func GetAllCourses(db *sql.DB) ([]Course, error) {
 			&course.Students, &course.Image, &course.IntroMaterial, &course.Lessons,
 			&course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
 			&course.HasPostWork, &course.HasFinalProject, &course.CertificateDelay,
-			&course.StepWeights, &course.CreatedAt, &course.UpdatedAt,
+			&course.StepWeights, &course.Status, &course.PublishAt, &course.UnpublishAt, &course.IsTemplate,
+			&course.CreatedAt, &course.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -100,10 +114,14 @@ func GetAllCourses(db *sql.DB) ([]Course, error) {
 // GetCourseByID retrieves a specific course by ID
 func GetCourseByID(db *sql.DB, id int) (*Course, error) {
 	query := `
-		SELECT id, title, description, category, level, duration, instructor,
-		       rating, students, image, intro_material, lessons, pre_test,
+		SELECT id, title, description, category, level, duration,
+		       ` + InstructorNamesSQL("courses.id", "courses.instructor") + ` AS instructor,
+		       rating, students, image, intro_material,
+		       ` + LessonsJSONSQL("courses.id") + ` AS lessons,
+		       ` + ModulesJSONSQL("courses.id") + ` AS modules, pre_test,
 		       post_test, post_work, final_project, has_post_work, has_final_project,
-		       certificate_delay, step_weights, created_at, updated_at
+		       certificate_delay, step_weights, status, publish_at, unpublish_at, is_template,
+		       created_at, updated_at
 		FROM courses
 		WHERE id = $1
 	`
@@ -113,9 +131,10 @@ func GetCourseByID(db *sql.DB, id int) (*Course, error) {
 		&course.ID, &course.Title, &course.Description, &course.Category,
 		&course.Level, &course.Duration, &course.Instructor, &course.Rating,
 		&course.Students, &course.Image, &course.IntroMaterial, &course.Lessons,
-		&course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
+		&course.Modules, &course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
 		&course.HasPostWork, &course.HasFinalProject, &course.CertificateDelay,
-		&course.StepWeights, &course.CreatedAt, &course.UpdatedAt,
+		&course.StepWeights, &course.Status, &course.PublishAt, &course.UnpublishAt, &course.IsTemplate,
+		&course.CreatedAt, &course.UpdatedAt,
 	)
 
 	if err != nil {
@@ -125,18 +144,21 @@ func GetCourseByID(db *sql.DB, id int) (*Course, error) {
 	return &course, nil
 }
 
-// GetCoursesWithEnrollment retrieves courses with enrollment status for a user
+// GetCoursesWithEnrollment retrieves the visible courses, plus the courses the user
+// is already enrolled in that were unpublished or archived, with enrollment status
 func GetCoursesWithEnrollment(db *sql.DB, userID int) ([]CourseWithEnrollment, error) {
 	query := `
 		SELECT c.id, c.title, c.description, c.category, c.level, c.duration,
-		       c.instructor, c.rating, c.students, c.image, c.intro_material,
-		       c.lessons, c.pre_test, c.post_test, c.post_work, c.final_project,
+		       ` + InstructorNamesSQL("c.id", "c.instructor") + ` AS instructor,
+		       c.rating, c.students, c.image, c.intro_material,
+		       ` + LessonsJSONSQL("c.id") + ` AS lessons, c.pre_test, c.post_test, c.post_work, c.final_project,
 		       c.has_post_work, c.has_final_project, c.certificate_delay, c.step_weights,
-		       c.created_at, c.updated_at,
+		       c.status, c.publish_at, c.unpublish_at, c.is_template, c.created_at, c.updated_at,
 		       CASE WHEN ce.id IS NOT NULL THEN true ELSE false END as is_enrolled,
 		       COALESCE(ce.progress, 0) as progress
 		FROM courses c
 		LEFT JOIN course_enrollments ce ON c.id = ce.course_id AND ce.user_id = $1
+		WHERE ` + CourseVisibleSQL("c") + ` OR (ce.id IS NOT NULL AND c.status IN ('published', 'archived') AND NOT c.is_template)
 		ORDER BY c.created_at DESC
 	`
 
@@ -155,7 +177,8 @@ func GetCoursesWithEnrollment(db *sql.DB, userID int) ([]CourseWithEnrollment, e
 			&course.Students, &course.Image, &course.IntroMaterial, &course.Lessons,
 			&course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
 			&course.HasPostWork, &course.HasFinalProject, &course.CertificateDelay,
-			&course.StepWeights, &course.CreatedAt, &course.UpdatedAt, &course.IsEnrolled, &course.Progress,
+			&course.StepWeights, &course.Status, &course.PublishAt, &course.UnpublishAt, &course.IsTemplate,
+			&course.CreatedAt, &course.UpdatedAt, &course.IsEnrolled, &course.Progress,
 		)
 		if err != nil {
 			return nil, err
@@ -169,8 +192,8 @@ func GetCoursesWithEnrollment(db *sql.DB, userID int) ([]CourseWithEnrollment, e
 // EnrollUserInCourse enrolls a user in a course
 func EnrollUserInCourse(db *sql.DB, userID, courseID int) error {
 	query := `
-		INSERT INTO course_enrollments (user_id, course_id)
-		VALUES ($1, $2)
+		INSERT INTO course_enrollments (user_id, course_id, revision_id)
+		VALUES ($1, $2, ` + CurrentRevisionSQL("$2") + `)
 		ON CONFLICT (user_id, course_id) DO NOTHING
 	`
 
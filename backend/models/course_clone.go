@@ -0,0 +1,282 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CloneCourseRequest configures a course clone
+type CloneCourseRequest struct {
+	// Title of the new course; defaults to the source title with a "(Copy)" suffix
+	Title string `json:"title"`
+	// AsTemplate makes the new course a template instead of a regular course
+	AsTemplate bool `json:"asTemplate"`
+}
+
+// CloneCourse deep-copies a course into a new draft: its content and
+// configuration, modules, lessons, SCORM packages, question bank, active
+// quizzes and stage locks. copyScorm copies the extracted files of a SCORM
+// package and returns where the copy is stored. Enrollments, progress,
+// revisions and instructor assignments are not copied. It returns
+// sql.ErrNoRows when the source course does not exist.
+func CloneCourse(db *sql.DB, sourceID int, req CloneCourseRequest, authorID int, copyScorm func(storagePath string) (string, error)) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var courseID int
+	err = tx.QueryRow(`
+		INSERT INTO courses (
+			title, description, category, level, duration, instructor, image,
+			intro_material, pre_test, post_test, post_work, final_project,
+			has_post_work, has_final_project, certificate_delay, step_weights,
+			status, is_template
+		)
+		SELECT COALESCE(NULLIF($2, ''), title || ' (Copy)'), description, category, level, duration, instructor, image,
+		       intro_material, pre_test, post_test, post_work, final_project,
+		       has_post_work, has_final_project, certificate_delay, step_weights,
+		       'draft', $3
+		FROM courses
+		WHERE id = $1
+		RETURNING id
+	`, sourceID, req.Title, req.AsTemplate).Scan(&courseID)
+	if err != nil {
+		return 0, err
+	}
+
+	modules, err := cloneRows(tx, `
+		SELECT id, NULL, NULL FROM course_modules WHERE course_id = $1 ORDER BY position, id
+	`, `
+		INSERT INTO course_modules (course_id, position, title, description)
+		SELECT $2, position, title, description FROM course_modules WHERE id = $1
+		RETURNING id
+	`, sourceID, courseID, nil)
+	if err != nil {
+		return 0, fmt.Errorf("clone modules: %v", err)
+	}
+
+	lessons, err := cloneRows(tx, `
+		SELECT id, module_id, NULL FROM lessons WHERE course_id = $1 ORDER BY position, id
+	`, `
+		INSERT INTO lessons (course_id, module_id, position, title, type, content, metadata)
+		SELECT $2, $3, position, title, type, content, metadata FROM lessons WHERE id = $1
+		RETURNING id
+	`, sourceID, courseID, func(moduleID, _ sql.NullInt64) []interface{} {
+		return []interface{}{mapID(moduleID, modules)}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("clone lessons: %v", err)
+	}
+
+	if err := cloneScormPackages(tx, sourceID, courseID, lessons, authorID, copyScorm); err != nil {
+		return 0, fmt.Errorf("clone SCORM packages: %v", err)
+	}
+
+	// Draw rules select by topic and difficulty, so the bank is copied as is
+	_, err = tx.Exec(`
+		INSERT INTO questions (course_id, topic, difficulty, question, created_by)
+		SELECT $2, topic, difficulty, question, $3 FROM questions WHERE course_id = $1 ORDER BY id
+	`, sourceID, courseID, authorID)
+	if err != nil {
+		return 0, fmt.Errorf("clone question bank: %v", err)
+	}
+
+	_, err = cloneRows(tx, `
+		SELECT id, module_id, lesson_id FROM quizzes WHERE course_id = $1 AND is_active = TRUE ORDER BY id
+	`, `
+		INSERT INTO quizzes (course_id, module_id, lesson_id, title, description, questions, time_limit,
+		                     max_attempts, passing_score, quiz_type, is_active, draw_rules, shuffle_options,
+		                     negative_marking, score_rounding)
+		SELECT $2, $3, $4, title, description, questions, time_limit,
+		       max_attempts, passing_score, quiz_type, is_active, draw_rules, shuffle_options,
+		       negative_marking, score_rounding
+		FROM quizzes WHERE id = $1
+		RETURNING id
+	`, sourceID, courseID, func(moduleID, lessonID sql.NullInt64) []interface{} {
+		return []interface{}{mapID(moduleID, modules), mapID(lessonID, lessons)}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("clone quizzes: %v", err)
+	}
+
+	if err := cloneStageLocks(tx, sourceID, courseID, modules); err != nil {
+		return 0, fmt.Errorf("clone stage locks: %v", err)
+	}
+
+	note := fmt.Sprintf("Cloned from course %d", sourceID)
+	if _, err := CreateCourseRevision(tx, courseID, authorID, note); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return courseID, nil
+}
+
+// cloneRows copies the rows listed by selectQuery with insertQuery and returns
+// the old to new ID mapping. selectQuery gets the source course and returns each
+// row's ID with two optional referenced IDs; insertQuery gets the source row ID,
+// the new course ID and the values refs derives from the referenced IDs.
+func cloneRows(tx *sql.Tx, selectQuery, insertQuery string, sourceID, courseID int, refs func(a, b sql.NullInt64) []interface{}) (map[int]int, error) {
+	type sourceRow struct {
+		id   int
+		a, b sql.NullInt64
+	}
+
+	rows, err := tx.Query(selectQuery, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	var sources []sourceRow
+	for rows.Next() {
+		var row sourceRow
+		if err := rows.Scan(&row.id, &row.a, &row.b); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sources = append(sources, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	mapping := make(map[int]int, len(sources))
+	for _, row := range sources {
+		args := []interface{}{row.id, courseID}
+		if refs != nil {
+			args = append(args, refs(row.a, row.b)...)
+		}
+		var newID int
+		if err := tx.QueryRow(insertQuery, args...).Scan(&newID); err != nil {
+			return nil, err
+		}
+		mapping[row.id] = newID
+	}
+	return mapping, nil
+}
+
+// mapID returns the cloned ID of a referenced row, or nil when there is no reference
+func mapID(id sql.NullInt64, mapping map[int]int) interface{} {
+	if !id.Valid {
+		return nil
+	}
+	if newID, ok := mapping[int(id.Int64)]; ok {
+		return newID
+	}
+	return nil
+}
+
+// cloneScormPackages copies the SCORM packages of a course's lessons to the
+// cloned lessons and points the lessons' metadata at the copies
+func cloneScormPackages(tx *sql.Tx, sourceID, courseID int, lessons map[int]int, authorID int, copyScorm func(string) (string, error)) error {
+	rows, err := tx.Query(`SELECT `+scormPackageColumns+` FROM scorm_packages WHERE course_id = $1 ORDER BY id`, sourceID)
+	if err != nil {
+		return err
+	}
+	var packages []*ScormPackage
+	for rows.Next() {
+		pkg, err := scanScormPackage(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		packages = append(packages, pkg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var uploadedBy interface{}
+	if authorID > 0 {
+		uploadedBy = authorID
+	}
+	for _, pkg := range packages {
+		lessonID, ok := lessons[pkg.LessonID]
+		if !ok {
+			continue
+		}
+		storagePath, err := copyScorm(pkg.StoragePath)
+		if err != nil {
+			return err
+		}
+
+		var packageID int
+		err = tx.QueryRow(`
+			INSERT INTO scorm_packages (course_id, lesson_id, version, identifier, title, launch_path, storage_path,
+			                            mastery_score, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, courseID, lessonID, pkg.Version, pkg.Identifier, pkg.Title, pkg.LaunchPath, storagePath,
+			pkg.MasteryScore, uploadedBy).Scan(&packageID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE lessons SET metadata = metadata || jsonb_build_object('scormPackageId', $1::integer)
+			WHERE id = $2
+		`, packageID, lessonID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneStageLocks copies a course's stage locks, pointing module locks at the cloned modules
+func cloneStageLocks(tx *sql.Tx, sourceID, courseID int, modules map[int]int) error {
+	rows, err := tx.Query(`
+		SELECT stage_name, is_locked, lock_message, module_id
+		FROM course_stage_locks
+		WHERE course_id = $1
+	`, sourceID)
+	if err != nil {
+		return err
+	}
+
+	type stageLock struct {
+		stageName   string
+		isLocked    bool
+		lockMessage string
+		moduleID    sql.NullInt64
+	}
+	var locks []stageLock
+	for rows.Next() {
+		var lock stageLock
+		if err := rows.Scan(&lock.stageName, &lock.isLocked, &lock.lockMessage, &lock.moduleID); err != nil {
+			rows.Close()
+			return err
+		}
+		locks = append(locks, lock)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, lock := range locks {
+		var moduleID interface{}
+		if lock.moduleID.Valid {
+			newID, ok := modules[int(lock.moduleID.Int64)]
+			if !ok {
+				continue
+			}
+			moduleID = newID
+			lock.stageName = ModuleStageName(newID)
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO course_stage_locks (course_id, stage_name, is_locked, lock_message, module_id)
+			VALUES ($1, $2, $3, $4, $5)
+		`, courseID, lock.stageName, lock.isLocked, lock.lockMessage, moduleID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// InstructorCourse is a course as listed for one of its instructors
+type InstructorCourse struct {
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Category   string    `json:"category"`
+	Level      string    `json:"level"`
+	Students   int       `json:"students"`
+	Image      string    `json:"image"`
+	AssignedAt time.Time `json:"assignedAt"`
+}
+
+// InstructorNamesSQL returns an expression with the comma-separated full names of
+// the users assigned to the course in courseIDColumn, falling back to the legacy
+// free-text fallbackColumn when nobody is assigned
+func InstructorNamesSQL(courseIDColumn, fallbackColumn string) string {
+	return `COALESCE((
+			SELECT string_agg(iu.full_name, ', ' ORDER BY ci.created_at, iu.id)
+			FROM course_instructors ci
+			JOIN users iu ON iu.id = ci.user_id
+			WHERE ci.course_id = ` + courseIDColumn + `
+		), ` + fallbackColumn + `)`
+}
+
+// GetCourseInstructorNames returns the display names of a course's instructors
+func GetCourseInstructorNames(db *sql.DB, courseID int) (string, error) {
+	var names string
+	query := `SELECT COALESCE(` + InstructorNamesSQL("c.id", "c.instructor") + `, '') FROM courses c WHERE c.id = $1`
+	err := db.QueryRow(query, courseID).Scan(&names)
+	return names, err
+}
+
+// GetInstructorCourses retrieves the courses a user is assigned to
+func GetInstructorCourses(db *sql.DB, userID int) ([]InstructorCourse, error) {
+	query := `
+		SELECT c.id, c.title, c.category, c.level, c.students, COALESCE(c.image, ''), ci.created_at
+		FROM course_instructors ci
+		JOIN courses c ON c.id = ci.course_id
+		WHERE ci.user_id = $1
+		ORDER BY c.title
+	`
+
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var courses []InstructorCourse
+	for rows.Next() {
+		var course InstructorCourse
+		err := rows.Scan(&course.ID, &course.Title, &course.Category, &course.Level,
+			&course.Students, &course.Image, &course.AssignedAt)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, course)
+	}
+
+	return courses, rows.Err()
+}
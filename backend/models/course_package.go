@@ -0,0 +1,626 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Course package format written by ExportCoursePackage. Packages with a newer
+// version than CoursePackageVersion are rejected on import.
+const (
+	CoursePackageFormat  = "lms-course-package"
+	CoursePackageVersion = 1
+)
+
+// ErrInvalidCoursePackage is returned when an imported package cannot be recreated
+var ErrInvalidCoursePackage = errors.New("invalid course package")
+
+// ErrScormNotPackaged is returned when exporting a course with SCORM lessons;
+// packages do not carry SCORM packages, so such courses are cloned instead
+var ErrScormNotPackaged = errors.New("courses with SCORM lessons cannot be exported")
+
+// fileReferencePattern matches links to uploaded files in course content, e.g.
+// /api/protected/uploads/file/42
+var fileReferencePattern = regexp.MustCompile(`uploads/file/(\d+)`)
+
+// CoursePackage is a portable copy of a course. The manifest describes the
+// package and the uploaded files it carries; IDs in the content are those of the
+// exporting instance and only link the package's parts together.
+type CoursePackage struct {
+	Manifest CoursePackageManifest `json:"manifest"`
+	Content  CoursePackageContent  `json:"content"`
+}
+
+// CoursePackageManifest identifies a package and lists its files
+type CoursePackageManifest struct {
+	Format         string              `json:"format"`
+	Version        int                 `json:"version"`
+	ExportedAt     time.Time           `json:"exportedAt"`
+	SourceCourseID int                 `json:"sourceCourseId"`
+	Title          string              `json:"title"`
+	Files          []CoursePackageFile `json:"files"`
+}
+
+// CoursePackageFile is an uploaded file referenced by the course content. In a
+// zip package the file is stored at Path; a JSON bundle carries it in Data.
+type CoursePackageFile struct {
+	ID           int    `json:"id"`
+	Path         string `json:"path"`
+	OriginalName string `json:"originalName"`
+	MimeType     string `json:"mimeType"`
+	FileType     string `json:"fileType"`
+	Size         int64  `json:"size"`
+	Data         []byte `json:"data,omitempty"`
+	// FilePath is where the exporting instance keeps the file
+	FilePath string `json:"-"`
+}
+
+// CoursePackageContent holds the course and the rows that belong to it
+type CoursePackageContent struct {
+	Course       PackagedCourse      `json:"course"`
+	Modules      []PackagedModule    `json:"modules"`
+	Lessons      []PackagedLesson    `json:"lessons"`
+	QuestionBank []PackagedQuestion  `json:"questionBank"`
+	Quizzes      []PackagedQuiz      `json:"quizzes"`
+	StageLocks   []PackagedStageLock `json:"stageLocks"`
+}
+
+// PackagedCourse is the course row of a package
+type PackagedCourse struct {
+	Title            string           `json:"title"`
+	Description      string           `json:"description"`
+	Category         string           `json:"category"`
+	Level            string           `json:"level"`
+	Duration         string           `json:"duration"`
+	Instructor       string           `json:"instructor"`
+	Image            string           `json:"image"`
+	IntroMaterial    *json.RawMessage `json:"introMaterial"`
+	PreTest          *json.RawMessage `json:"preTest"`
+	PostTest         *json.RawMessage `json:"postTest"`
+	PostWork         *json.RawMessage `json:"postWork"`
+	FinalProject     *json.RawMessage `json:"finalProject"`
+	HasPostWork      bool             `json:"hasPostWork"`
+	HasFinalProject  bool             `json:"hasFinalProject"`
+	CertificateDelay int              `json:"certificateDelay"`
+	StepWeights      *json.RawMessage `json:"stepWeights"`
+	IsTemplate       bool             `json:"isTemplate"`
+}
+
+// PackagedModule is a module of a package, in course order
+type PackagedModule struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// PackagedLesson is a lesson of a package, in course order
+type PackagedLesson struct {
+	ID       int             `json:"id"`
+	ModuleID *int            `json:"moduleId"`
+	Title    string          `json:"title"`
+	Type     string          `json:"type"`
+	Content  json.RawMessage `json:"content"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// PackagedQuestion is a question of the package's question bank
+type PackagedQuestion struct {
+	Topic      string       `json:"topic"`
+	Difficulty string       `json:"difficulty"`
+	Question   QuizQuestion `json:"question"`
+}
+
+// PackagedQuiz is an active quiz of a package
+type PackagedQuiz struct {
+	ModuleID       *int            `json:"moduleId"`
+	LessonID       *int            `json:"lessonId"`
+	Title          string          `json:"title"`
+	Description    string          `json:"description"`
+	Questions      json.RawMessage `json:"questions"`
+	TimeLimit      int             `json:"timeLimit"`
+	MaxAttempts    int             `json:"maxAttempts"`
+	PassingScore   int             `json:"passingScore"`
+	QuizType       string          `json:"quizType"`
+	DrawRules      []QuizDrawRule  `json:"drawRules,omitempty"`
+	ShuffleOptions bool            `json:"shuffleOptions"`
+	Scoring        QuizScoring     `json:"scoring"`
+}
+
+// PackagedStageLock is a stage lock of a package; module locks set ModuleID
+type PackagedStageLock struct {
+	StageName   string `json:"stageName"`
+	ModuleID    *int   `json:"moduleId"`
+	IsLocked    bool   `json:"isLocked"`
+	LockMessage string `json:"lockMessage"`
+}
+
+// ExportCoursePackage reads a course into a package. The manifest lists the
+// uploaded files the content links to, without their data. It returns
+// sql.ErrNoRows when the course does not exist.
+func ExportCoursePackage(db *sql.DB, courseID int) (*CoursePackage, error) {
+	pkg := &CoursePackage{
+		Manifest: CoursePackageManifest{
+			Format:         CoursePackageFormat,
+			Version:        CoursePackageVersion,
+			ExportedAt:     time.Now().UTC(),
+			SourceCourseID: courseID,
+		},
+	}
+	content := &pkg.Content
+
+	course := &content.Course
+	err := db.QueryRow(`
+		SELECT title, COALESCE(description, ''), COALESCE(category, ''), COALESCE(level, ''), COALESCE(duration, ''),
+		       COALESCE(instructor, ''), COALESCE(image, ''), intro_material, pre_test, post_test, post_work, final_project,
+		       has_post_work, has_final_project, certificate_delay, step_weights, is_template
+		FROM courses
+		WHERE id = $1
+	`, courseID).Scan(&course.Title, &course.Description, &course.Category, &course.Level, &course.Duration,
+		&course.Instructor, &course.Image, &course.IntroMaterial, &course.PreTest, &course.PostTest, &course.PostWork,
+		&course.FinalProject, &course.HasPostWork, &course.HasFinalProject, &course.CertificateDelay,
+		&course.StepWeights, &course.IsTemplate)
+	if err != nil {
+		return nil, err
+	}
+	pkg.Manifest.Title = course.Title
+
+	modules, err := GetCourseModules(db, courseID)
+	if err != nil {
+		return nil, err
+	}
+	content.Modules = make([]PackagedModule, 0, len(modules))
+	for _, module := range modules {
+		content.Modules = append(content.Modules, PackagedModule{ID: module.ID, Title: module.Title, Description: module.Description})
+	}
+
+	lessons, err := GetCourseLessons(db, courseID)
+	if err != nil {
+		return nil, err
+	}
+	content.Lessons = make([]PackagedLesson, 0, len(lessons))
+	for _, lesson := range lessons {
+		if lesson.Type == "scorm" {
+			return nil, fmt.Errorf("%w: lesson %q is a SCORM lesson; clone the course or upload its package again after import",
+				ErrScormNotPackaged, lesson.Title)
+		}
+		content.Lessons = append(content.Lessons, PackagedLesson{
+			ID:       lesson.ID,
+			ModuleID: lesson.ModuleID,
+			Title:    lesson.Title,
+			Type:     lesson.Type,
+			Content:  lesson.Content,
+			Metadata: lesson.Metadata,
+		})
+	}
+
+	if content.QuestionBank, err = exportQuestionBank(db, courseID); err != nil {
+		return nil, err
+	}
+	if content.Quizzes, err = exportQuizzes(db, courseID); err != nil {
+		return nil, err
+	}
+	if content.StageLocks, err = exportStageLocks(db, courseID); err != nil {
+		return nil, err
+	}
+	if pkg.Manifest.Files, err = exportFiles(db, content); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// exportQuestionBank reads the question bank of a course
+func exportQuestionBank(db *sql.DB, courseID int) ([]PackagedQuestion, error) {
+	rows, err := db.Query(`SELECT `+bankQuestionColumns+` FROM questions WHERE course_id = $1 ORDER BY id`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []PackagedQuestion{}
+	for rows.Next() {
+		question, err := scanBankQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		question.Question.ID = 0
+		questions = append(questions, PackagedQuestion{
+			Topic:      question.Topic,
+			Difficulty: question.Difficulty,
+			Question:   question.Question,
+		})
+	}
+	return questions, rows.Err()
+}
+
+// exportQuizzes reads the active quizzes of a course
+func exportQuizzes(db *sql.DB, courseID int) ([]PackagedQuiz, error) {
+	rows, err := db.Query(`
+		SELECT module_id, lesson_id, title, COALESCE(description, ''), questions, COALESCE(time_limit, 0),
+		       COALESCE(max_attempts, 1), COALESCE(passing_score, 70), COALESCE(quiz_type, 'quiz'),
+		       draw_rules, shuffle_options, negative_marking, score_rounding
+		FROM quizzes
+		WHERE course_id = $1 AND is_active = TRUE
+		ORDER BY id
+	`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quizzes := []PackagedQuiz{}
+	for rows.Next() {
+		var quiz PackagedQuiz
+		var moduleID, lessonID sql.NullInt64
+		var questions, drawRules []byte
+		err := rows.Scan(&moduleID, &lessonID, &quiz.Title, &quiz.Description, &questions, &quiz.TimeLimit,
+			&quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &drawRules, &quiz.ShuffleOptions,
+			&quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding)
+		if err != nil {
+			return nil, err
+		}
+		quiz.DrawRules = parseDrawRules(drawRules)
+		quiz.ModuleID = nullIntPtr(moduleID)
+		quiz.LessonID = nullIntPtr(lessonID)
+		quiz.Questions = json.RawMessage(questions)
+		quizzes = append(quizzes, quiz)
+	}
+	return quizzes, rows.Err()
+}
+
+// exportStageLocks reads the stage locks of a course
+func exportStageLocks(db *sql.DB, courseID int) ([]PackagedStageLock, error) {
+	rows, err := db.Query(`
+		SELECT stage_name, module_id, is_locked, COALESCE(lock_message, '')
+		FROM course_stage_locks
+		WHERE course_id = $1
+		ORDER BY stage_name
+	`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := []PackagedStageLock{}
+	for rows.Next() {
+		var lock PackagedStageLock
+		var moduleID sql.NullInt64
+		if err := rows.Scan(&lock.StageName, &moduleID, &lock.IsLocked, &lock.LockMessage); err != nil {
+			return nil, err
+		}
+		lock.ModuleID = nullIntPtr(moduleID)
+		locks = append(locks, lock)
+	}
+	return locks, rows.Err()
+}
+
+// exportFiles lists the uploaded files linked from the package content. Links
+// to files that no longer exist are left out.
+func exportFiles(db *sql.DB, content *CoursePackageContent) ([]CoursePackageFile, error) {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []CoursePackageFile{}
+	seen := make(map[int]bool)
+	for _, match := range fileReferencePattern.FindAllSubmatch(raw, -1) {
+		id, err := strconv.Atoi(string(match[1]))
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		upload, err := GetFileUpload(db, id)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, CoursePackageFile{
+			ID:           upload.ID,
+			Path:         fmt.Sprintf("files/%d/%s", upload.ID, upload.FileName),
+			OriginalName: upload.OriginalName,
+			MimeType:     upload.MimeType,
+			FileType:     upload.FileType,
+			Size:         upload.FileSize,
+			FilePath:     upload.FilePath,
+		})
+	}
+	return files, nil
+}
+
+// Validate checks that a package can be imported: a known format and version, a
+// title, well-formed lessons and references that stay inside the package
+func (pkg *CoursePackage) Validate() error {
+	if pkg.Manifest.Format != CoursePackageFormat {
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidCoursePackage, pkg.Manifest.Format)
+	}
+	if pkg.Manifest.Version < 1 || pkg.Manifest.Version > CoursePackageVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidCoursePackage, pkg.Manifest.Version)
+	}
+
+	content := &pkg.Content
+	if content.Course.Title == "" {
+		return fmt.Errorf("%w: course title is required", ErrInvalidCoursePackage)
+	}
+
+	modules := make(map[int]bool)
+	for _, module := range content.Modules {
+		if modules[module.ID] {
+			return fmt.Errorf("%w: duplicate module %d", ErrInvalidCoursePackage, module.ID)
+		}
+		modules[module.ID] = true
+	}
+	lessons := make(map[int]bool)
+	for i, lesson := range content.Lessons {
+		if lessons[lesson.ID] {
+			return fmt.Errorf("%w: duplicate lesson %d", ErrInvalidCoursePackage, lesson.ID)
+		}
+		lessons[lesson.ID] = true
+		if lesson.Type == "scorm" {
+			return fmt.Errorf("%w: lesson %d is a SCORM lesson, which packages cannot carry", ErrInvalidCoursePackage, i+1)
+		}
+		if lesson.ModuleID != nil && !modules[*lesson.ModuleID] {
+			return fmt.Errorf("%w: lesson %d refers to unknown module %d", ErrInvalidCoursePackage, i+1, *lesson.ModuleID)
+		}
+		if err := validateLessonJSON(&lesson.Content, &lesson.Metadata); err != nil {
+			return fmt.Errorf("%w: lesson %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+	}
+	for i := range content.QuestionBank {
+		question := &content.QuestionBank[i]
+		bank := BankQuestion{Topic: question.Topic, Difficulty: question.Difficulty, Question: question.Question}
+		if err := validateBankQuestion(&bank); err != nil {
+			return fmt.Errorf("%w: bank question %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+		question.Topic, question.Difficulty = bank.Topic, bank.Difficulty
+	}
+	bank := packageBankCategories(content.QuestionBank)
+	for i, quiz := range content.Quizzes {
+		if err := checkDrawRules(quiz.DrawRules); err != nil {
+			return fmt.Errorf("%w: quiz %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+		if err := checkBankFillsRules(quiz.DrawRules, bank); err != nil {
+			return fmt.Errorf("%w: quiz %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+		if err := content.Quizzes[i].Scoring.Validate(); err != nil {
+			return fmt.Errorf("%w: quiz %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+		if quiz.ModuleID != nil && !modules[*quiz.ModuleID] {
+			return fmt.Errorf("%w: quiz %d refers to unknown module %d", ErrInvalidCoursePackage, i+1, *quiz.ModuleID)
+		}
+		if !json.Valid(quiz.Questions) {
+			return fmt.Errorf("%w: quiz %d has invalid questions", ErrInvalidCoursePackage, i+1)
+		}
+		if err := ValidateQuizQuestions(quiz.Questions); err != nil {
+			return fmt.Errorf("%w: quiz %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+	}
+	for _, lock := range content.StageLocks {
+		if lock.ModuleID != nil && !modules[*lock.ModuleID] {
+			return fmt.Errorf("%w: stage lock refers to unknown module %d", ErrInvalidCoursePackage, *lock.ModuleID)
+		}
+	}
+
+	files := make(map[int]bool)
+	paths := make(map[string]bool)
+	for _, file := range pkg.Manifest.Files {
+		if files[file.ID] {
+			return fmt.Errorf("%w: duplicate file %d", ErrInvalidCoursePackage, file.ID)
+		}
+		files[file.ID] = true
+		if file.Path == "" {
+			continue
+		}
+		if paths[file.Path] {
+			return fmt.Errorf("%w: duplicate file path %s", ErrInvalidCoursePackage, file.Path)
+		}
+		paths[file.Path] = true
+	}
+	return nil
+}
+
+// ImportedFile is a package file saved to the uploads directory of this instance
+type ImportedFile struct {
+	PackageID    int
+	FileName     string
+	OriginalName string
+	FilePath     string
+	Size         int64
+	MimeType     string
+	FileType     string
+}
+
+// ImportCoursePackage recreates a validated package as a new draft course with
+// new IDs. The saved files are recorded as uploads of the importing user and the
+// content's links to them are rewritten to the new file IDs.
+func ImportCoursePackage(db *sql.DB, pkg *CoursePackage, files []ImportedFile, authorID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	fileIDs := make(map[int]int, len(files))
+	for _, file := range files {
+		var id int
+		err := tx.QueryRow(`
+			INSERT INTO file_uploads (user_id, file_name, original_name, file_path, file_size, mime_type, file_type, uploaded_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+			RETURNING id
+		`, authorID, file.FileName, file.OriginalName, file.FilePath, file.Size, file.MimeType, file.FileType).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("import file %d: %v", file.PackageID, err)
+		}
+		fileIDs[file.PackageID] = id
+	}
+
+	content, err := rewriteFileReferences(pkg.Content, fileIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	course := content.Course
+	var courseID int
+	err = tx.QueryRow(`
+		INSERT INTO courses (
+			title, description, category, level, duration, instructor, image,
+			intro_material, pre_test, post_test, post_work, final_project,
+			has_post_work, has_final_project, certificate_delay, step_weights,
+			status, is_template
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, 'draft', $17)
+		RETURNING id
+	`, course.Title, course.Description, course.Category, course.Level, course.Duration, course.Instructor, course.Image,
+		rawJSONParam(course.IntroMaterial), rawJSONParam(course.PreTest), rawJSONParam(course.PostTest),
+		rawJSONParam(course.PostWork), rawJSONParam(course.FinalProject), course.HasPostWork, course.HasFinalProject,
+		course.CertificateDelay, rawJSONParam(course.StepWeights), course.IsTemplate).Scan(&courseID)
+	if err != nil {
+		return 0, err
+	}
+
+	modules := make(map[int]int, len(content.Modules))
+	for i, module := range content.Modules {
+		var id int
+		err := tx.QueryRow(`
+			INSERT INTO course_modules (course_id, position, title, description)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, courseID, i+1, module.Title, module.Description).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("import modules: %v", err)
+		}
+		modules[module.ID] = id
+	}
+
+	lessons := make(map[int]int, len(content.Lessons))
+	for i, lesson := range content.Lessons {
+		title, lessonType, lessonContent, metadata, err := lessonFields(LessonRequest{
+			Title:    &lesson.Title,
+			Type:     &lesson.Type,
+			Content:  &lesson.Content,
+			Metadata: &lesson.Metadata,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("%w: lesson %d: %v", ErrInvalidCoursePackage, i+1, err)
+		}
+
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO lessons (course_id, module_id, position, title, type, content, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, courseID, mapPackageID(lesson.ModuleID, modules), i+1, title, lessonType,
+			string(lessonContent), string(metadata)).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("import lessons: %v", err)
+		}
+		lessons[lesson.ID] = id
+	}
+
+	for _, question := range content.QuestionBank {
+		questionJSON, err := bankQuestionJSON(question.Question)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO questions (course_id, topic, difficulty, question, created_by)
+			VALUES ($1, $2, $3, $4, $5)
+		`, courseID, question.Topic, question.Difficulty, questionJSON, authorID)
+		if err != nil {
+			return 0, fmt.Errorf("import question bank: %v", err)
+		}
+	}
+
+	for _, quiz := range content.Quizzes {
+		drawRules, err := drawRulesParam(quiz.DrawRules)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO quizzes (course_id, module_id, lesson_id, title, description, questions, time_limit,
+			                     max_attempts, passing_score, quiz_type, is_active, draw_rules, shuffle_options,
+			                     negative_marking, score_rounding)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, TRUE, $11, $12, $13, $14)
+		`, courseID, mapPackageID(quiz.ModuleID, modules), mapPackageID(quiz.LessonID, lessons), quiz.Title,
+			quiz.Description, string(quiz.Questions), quiz.TimeLimit, quiz.MaxAttempts, quiz.PassingScore, quiz.QuizType,
+			drawRules, quiz.ShuffleOptions, quiz.Scoring.NegativeMarking, quiz.Scoring.Rounding)
+		if err != nil {
+			return 0, fmt.Errorf("import quizzes: %v", err)
+		}
+	}
+
+	for _, lock := range content.StageLocks {
+		stageName := lock.StageName
+		var moduleID interface{}
+		if lock.ModuleID != nil {
+			newID := modules[*lock.ModuleID]
+			moduleID = newID
+			stageName = ModuleStageName(newID)
+		}
+		_, err := tx.Exec(`
+			INSERT INTO course_stage_locks (course_id, stage_name, is_locked, lock_message, module_id)
+			VALUES ($1, $2, $3, $4, $5)
+		`, courseID, stageName, lock.IsLocked, lock.LockMessage, moduleID)
+		if err != nil {
+			return 0, fmt.Errorf("import stage locks: %v", err)
+		}
+	}
+
+	note := fmt.Sprintf("Imported from course %d of a course package", pkg.Manifest.SourceCourseID)
+	if _, err := CreateCourseRevision(tx, courseID, authorID, note); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return courseID, nil
+}
+
+// rewriteFileReferences points links to package files at their imported copies.
+// Links to files the package does not carry lose their ID: on this instance it
+// would name an unrelated upload.
+func rewriteFileReferences(content CoursePackageContent, fileIDs map[int]int) (CoursePackageContent, error) {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return content, err
+	}
+	raw = fileReferencePattern.ReplaceAllFunc(raw, func(match []byte) []byte {
+		id, _ := strconv.Atoi(string(fileReferencePattern.FindSubmatch(match)[1]))
+		if newID, ok := fileIDs[id]; ok {
+			return []byte("uploads/file/" + strconv.Itoa(newID))
+		}
+		return []byte("uploads/file/")
+	})
+
+	var rewritten CoursePackageContent
+	if err := json.Unmarshal(raw, &rewritten); err != nil {
+		return content, err
+	}
+	return rewritten, nil
+}
+
+// mapPackageID returns the new ID of a row referenced by package ID, or nil
+func mapPackageID(id *int, mapping map[int]int) interface{} {
+	if id == nil {
+		return nil
+	}
+	if newID, ok := mapping[*id]; ok {
+		return newID
+	}
+	return nil
+}
+
+// rawJSONParam passes optional JSON to a JSONB column, storing SQL NULL when absent
+func rawJSONParam(value *json.RawMessage) interface{} {
+	if value == nil || string(*value) == "null" {
+		return nil
+	}
+	return string(*value)
+}
@@ -0,0 +1,374 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CourseRevision is an immutable snapshot of a course's content
+type CourseRevision struct {
+	ID             int             `json:"id"`
+	CourseID       int             `json:"courseId"`
+	RevisionNumber int             `json:"revisionNumber"`
+	Content        json.RawMessage `json:"content,omitempty"`
+	AuthorID       *int            `json:"authorId"`
+	AuthorName     string          `json:"authorName"`
+	Note           string          `json:"note"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// CourseSnapshot is the content stored in a revision
+type CourseSnapshot struct {
+	Title         string           `json:"title"`
+	Description   string           `json:"description"`
+	Category      string           `json:"category"`
+	Level         string           `json:"level"`
+	Duration      string           `json:"duration"`
+	Image         string           `json:"image"`
+	IntroMaterial *json.RawMessage `json:"introMaterial"`
+	Lessons       *json.RawMessage `json:"lessons"`
+	PreTest       *json.RawMessage `json:"preTest"`
+	PostTest      *json.RawMessage `json:"postTest"`
+	PostWork      *json.RawMessage `json:"postWork"`
+	FinalProject  *json.RawMessage `json:"finalProject"`
+}
+
+// RevisionChange is one difference between two revisions. Lesson changes carry
+// the lesson ID and title; other changes refer to a whole field.
+type RevisionChange struct {
+	Field    string          `json:"field"`
+	Change   string          `json:"change"` // added, removed, changed or moved
+	LessonID *int            `json:"lessonId,omitempty"`
+	Title    string          `json:"title,omitempty"`
+	From     json.RawMessage `json:"from,omitempty"`
+	To       json.RawMessage `json:"to,omitempty"`
+}
+
+// courseSnapshotSQL builds the content of a revision from the course row c
+var courseSnapshotSQL = `jsonb_build_object(
+		'title', c.title,
+		'description', c.description,
+		'category', c.category,
+		'level', c.level,
+		'duration', c.duration,
+		'image', c.image,
+		'introMaterial', c.intro_material,
+		'lessons', ` + LessonsJSONSQL("c.id") + `,
+		'preTest', c.pre_test,
+		'postTest', c.post_test,
+		'postWork', c.post_work,
+		'finalProject', c.final_project
+	)`
+
+// CurrentRevisionSQL returns an expression with the ID of the latest revision of
+// the course in courseIDColumn, the revision new enrollments are pinned to
+func CurrentRevisionSQL(courseIDColumn string) string {
+	return `(SELECT id FROM course_revisions WHERE course_id = ` + courseIDColumn + ` ORDER BY revision_number DESC LIMIT 1)`
+}
+
+// CreateCourseRevision snapshots the current content of a course as its next
+// revision. Call it in the transaction that saved the content, after locking or
+// updating the course row so revision numbers are assigned one at a time.
+func CreateCourseRevision(exec sqlExecutor, courseID, authorID int, note string) (*CourseRevision, error) {
+	var author interface{}
+	if authorID > 0 {
+		author = authorID
+	}
+
+	revision := CourseRevision{CourseID: courseID, Note: note}
+	err := exec.QueryRow(`
+		INSERT INTO course_revisions (course_id, revision_number, content, author_id, note)
+		SELECT c.id,
+		       COALESCE((SELECT MAX(revision_number) FROM course_revisions WHERE course_id = c.id), 0) + 1,
+		       `+courseSnapshotSQL+`, $2, $3
+		FROM courses c
+		WHERE c.id = $1
+		RETURNING id, revision_number, author_id, created_at
+	`, courseID, author, note).Scan(&revision.ID, &revision.RevisionNumber, &revision.AuthorID, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetCourseRevisions lists a course's revisions, newest first, without their content
+func GetCourseRevisions(db *sql.DB, courseID int) ([]CourseRevision, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.course_id, r.revision_number, r.author_id, COALESCE(u.full_name, ''), r.note, r.created_at
+		FROM course_revisions r
+		LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.course_id = $1
+		ORDER BY r.revision_number DESC
+	`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []CourseRevision{}
+	for rows.Next() {
+		var revision CourseRevision
+		err := rows.Scan(&revision.ID, &revision.CourseID, &revision.RevisionNumber, &revision.AuthorID,
+			&revision.AuthorName, &revision.Note, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetCourseRevision returns a revision of a course with its content, or
+// sql.ErrNoRows when the course has no such revision
+func GetCourseRevision(db *sql.DB, courseID, revisionID int) (*CourseRevision, error) {
+	var revision CourseRevision
+	var content []byte
+	err := db.QueryRow(`
+		SELECT r.id, r.course_id, r.revision_number, r.content, r.author_id, COALESCE(u.full_name, ''), r.note, r.created_at
+		FROM course_revisions r
+		LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.id = $1 AND r.course_id = $2
+	`, revisionID, courseID).Scan(&revision.ID, &revision.CourseID, &revision.RevisionNumber, &content,
+		&revision.AuthorID, &revision.AuthorName, &revision.Note, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	revision.Content = json.RawMessage(content)
+	return &revision, nil
+}
+
+// GetPreviousRevisionID returns the ID of the revision before revisionNumber, or
+// sql.ErrNoRows for a course's first revision
+func GetPreviousRevisionID(db *sql.DB, courseID, revisionNumber int) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		SELECT id FROM course_revisions
+		WHERE course_id = $1 AND revision_number < $2
+		ORDER BY revision_number DESC
+		LIMIT 1
+	`, courseID, revisionNumber).Scan(&id)
+	return id, err
+}
+
+// GetEnrollmentRevision returns the revision a learner's enrollment is pinned to,
+// or sql.ErrNoRows when the user is not enrolled or the enrollment is not pinned
+func GetEnrollmentRevision(db *sql.DB, userID, courseID int) (*CourseRevision, error) {
+	var revisionID int
+	err := db.QueryRow(`
+		SELECT revision_id FROM course_enrollments
+		WHERE user_id = $1 AND course_id = $2 AND revision_id IS NOT NULL
+	`, userID, courseID).Scan(&revisionID)
+	if err != nil {
+		return nil, err
+	}
+	return GetCourseRevision(db, courseID, revisionID)
+}
+
+// ApplyTo replaces the content fields of course with the snapshot
+func (s *CourseSnapshot) ApplyTo(course *Course) {
+	course.Title = s.Title
+	course.Description = s.Description
+	course.Category = s.Category
+	course.Level = s.Level
+	course.Duration = s.Duration
+	course.Image = s.Image
+	course.IntroMaterial = s.IntroMaterial
+	course.Lessons = s.Lessons
+	course.PreTest = s.PreTest
+	course.PostTest = s.PostTest
+	course.PostWork = s.PostWork
+	course.FinalProject = s.FinalProject
+}
+
+// RestoreCourseRevision writes a revision's content back to the course and records
+// the result as a new revision. Lessons that still exist keep their IDs and
+// progress; lessons of the revision that were deleted since are recreated, and
+// module assignments to modules that no longer exist are dropped.
+func RestoreCourseRevision(db *sql.DB, courseID int, revision *CourseRevision, authorID int) (*CourseRevision, error) {
+	var snapshot CourseSnapshot
+	if err := json.Unmarshal(revision.Content, &snapshot); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE courses SET
+			title = $1, description = $2, category = $3, level = $4, duration = $5, image = $6,
+			intro_material = $7, pre_test = $8, post_test = $9, post_work = $10, final_project = $11,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $12
+	`, snapshot.Title, snapshot.Description, snapshot.Category, snapshot.Level, snapshot.Duration, snapshot.Image,
+		snapshot.IntroMaterial, snapshot.PreTest, snapshot.PostTest, snapshot.PostWork, snapshot.FinalProject, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot.Lessons != nil {
+		lessons, err := dropMissingModules(tx, courseID, *snapshot.Lessons)
+		if err != nil {
+			return nil, err
+		}
+		if err := SyncCourseLessons(tx, courseID, lessons); err != nil {
+			return nil, err
+		}
+	}
+
+	restored, err := CreateCourseRevision(tx, courseID, authorID, fmt.Sprintf("Restored revision %d", revision.RevisionNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// dropMissingModules clears the moduleId of lessons whose module has been deleted
+func dropMissingModules(tx *sql.Tx, courseID int, lessons json.RawMessage) (json.RawMessage, error) {
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(lessons, &entries); err != nil {
+		return nil, err
+	}
+
+	modules, err := courseRowIDs(tx, "course_modules", courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		var moduleID int
+		if json.Unmarshal(entry["moduleId"], &moduleID) == nil && moduleID != 0 && !modules[moduleID] {
+			entry["moduleId"] = json.RawMessage("null")
+		}
+	}
+	return json.Marshal(entries)
+}
+
+// DiffCourseRevisions lists the changes from one revision's content to another's.
+// Lessons are compared by ID so edits, additions, removals and moves are reported
+// per lesson.
+func DiffCourseRevisions(from, to json.RawMessage) ([]RevisionChange, error) {
+	var before, after map[string]json.RawMessage
+	if err := json.Unmarshal(from, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &after); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	changes := []RevisionChange{}
+	for _, field := range names {
+		if field == "lessons" {
+			lessonChanges, err := diffLessons(before[field], after[field])
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, lessonChanges...)
+			continue
+		}
+		if !jsonEqual(before[field], after[field]) {
+			changes = append(changes, RevisionChange{Field: field, Change: "changed", From: before[field], To: after[field]})
+		}
+	}
+	return changes, nil
+}
+
+// diffLessons compares two lesson arrays by lesson ID
+func diffLessons(from, to json.RawMessage) ([]RevisionChange, error) {
+	type lessonEntry struct {
+		index int
+		title string
+		raw   json.RawMessage
+	}
+	index := func(raw json.RawMessage) (map[int]lessonEntry, []int, error) {
+		var items []json.RawMessage
+		if len(raw) > 0 && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, nil, err
+			}
+		}
+		entries := make(map[int]lessonEntry)
+		var order []int
+		for i, item := range items {
+			var lesson struct {
+				ID    int    `json:"id"`
+				Title string `json:"title"`
+			}
+			if err := json.Unmarshal(item, &lesson); err != nil {
+				return nil, nil, err
+			}
+			entries[lesson.ID] = lessonEntry{index: i, title: lesson.Title, raw: item}
+			order = append(order, lesson.ID)
+		}
+		return entries, order, nil
+	}
+
+	before, beforeOrder, err := index(from)
+	if err != nil {
+		return nil, err
+	}
+	after, afterOrder, err := index(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []RevisionChange{}
+	for _, id := range beforeOrder {
+		if _, ok := after[id]; !ok {
+			lessonID := id
+			changes = append(changes, RevisionChange{Field: "lessons", Change: "removed", LessonID: &lessonID, Title: before[id].title, From: before[id].raw})
+		}
+	}
+	for _, id := range afterOrder {
+		lessonID := id
+		old, ok := before[id]
+		switch {
+		case !ok:
+			changes = append(changes, RevisionChange{Field: "lessons", Change: "added", LessonID: &lessonID, Title: after[id].title, To: after[id].raw})
+		case !jsonEqual(old.raw, after[id].raw):
+			changes = append(changes, RevisionChange{Field: "lessons", Change: "changed", LessonID: &lessonID, Title: after[id].title, From: old.raw, To: after[id].raw})
+		case old.index != after[id].index:
+			changes = append(changes, RevisionChange{Field: "lessons", Change: "moved", LessonID: &lessonID, Title: after[id].title,
+				From: json.RawMessage(fmt.Sprint(old.index + 1)), To: json.RawMessage(fmt.Sprint(after[id].index + 1))})
+		}
+	}
+	return changes, nil
+}
+
+// jsonEqual compares two JSON values ignoring formatting and key order
+func jsonEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
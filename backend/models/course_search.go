@@ -0,0 +1,231 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Page sizes of a course search
+const (
+	DefaultCourseSearchLimit = 20
+	MaxCourseSearchLimit     = 50
+)
+
+// ErrInvalidSearchCursor is returned for a cursor SearchCourses did not issue
+var ErrInvalidSearchCursor = errors.New("invalid search cursor")
+
+// CourseSearchParams holds a search query, its filters and the page to return.
+// Category, level and duration filters match any of the listed values,
+// ignoring case.
+type CourseSearchParams struct {
+	Query      string
+	Categories []string
+	Levels     []string
+	Durations  []string
+	MinRating  *float64
+	Limit      int
+	Cursor     string
+}
+
+// CourseSearchResult is a visible course matching a search, with its rank and
+// the matched words highlighted with <mark> in its title and a text snippet
+type CourseSearchResult struct {
+	Course
+	Rank      float64                `json:"rank"`
+	Highlight *CourseSearchHighlight `json:"highlight,omitempty"`
+}
+
+// CourseSearchHighlight is the HTML-escaped title and snippet of a result
+type CourseSearchHighlight struct {
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+// searchTermPattern matches the words of a search query
+var searchTermPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// maxSearchTerms caps the words of a query that are searched for
+const maxSearchTerms = 10
+
+// searchHeadlineOptions marks matches and picks up to two fragments of text
+const searchHeadlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=" ... "`
+
+// courseSearchTSQuery turns a query into a tsquery matching courses that
+// contain every word, each as a prefix so partially typed words match
+func courseSearchTSQuery(query string) string {
+	terms := searchTermPattern.FindAllString(strings.ToLower(query), maxSearchTerms)
+	for i := range terms {
+		terms[i] += ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchCourses runs a full-text search over the visible courses, best matches
+// first, and returns one page of results with the cursor of the next page ("" on
+// the last page). Without a query every course matching the filters is
+// returned, newest first.
+func SearchCourses(db *sql.DB, params CourseSearchParams) ([]CourseSearchResult, string, error) {
+	limit := params.Limit
+	if limit <= 0 || limit > MaxCourseSearchLimit {
+		limit = DefaultCourseSearchLimit
+	}
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{CourseVisibleSQL("c")}
+	rank, match := "0::real", ""
+	tsQuery := courseSearchTSQuery(params.Query)
+	if tsQuery != "" {
+		match = "to_tsquery('simple', " + arg(tsQuery) + ")"
+		rank = "ts_rank_cd(c.search_vector, " + match + ")"
+		conditions = append(conditions, "c.search_vector @@ "+match)
+	} else if strings.TrimSpace(params.Query) != "" {
+		// Nothing searchable in the query, e.g. only punctuation
+		return []CourseSearchResult{}, "", nil
+	}
+	filters := []struct {
+		column string
+		values []string
+	}{{"category", params.Categories}, {"level", params.Levels}, {"duration", params.Durations}}
+	for _, filter := range filters {
+		if len(filter.values) == 0 {
+			continue
+		}
+		lowered := make([]string, len(filter.values))
+		for i, value := range filter.values {
+			lowered[i] = strings.ToLower(value)
+		}
+		conditions = append(conditions, fmt.Sprintf("LOWER(c.%s) = ANY(%s)", filter.column, arg(pq.Array(lowered))))
+	}
+	if params.MinRating != nil {
+		conditions = append(conditions, "c.rating >= "+arg(*params.MinRating))
+	}
+
+	after := "TRUE"
+	if params.Cursor != "" {
+		cursorRank, cursorID, err := decodeSearchCursor(params.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = fmt.Sprintf("(rank, id) < (%s::real, %s)", arg(cursorRank), arg(cursorID))
+	}
+
+	title, snippet := "''", "''"
+	if tsQuery != "" {
+		title = "ts_headline('simple', c.title, " + match + ", 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>')"
+		snippet = `ts_headline('simple', concat_ws(' ', c.description, (
+				SELECT string_agg(lesson_search_text(l.title, l.content), ' ' ORDER BY l.position, l.id)
+				FROM lessons l WHERE l.course_id = c.id
+			)), ` + match + `, '` + searchHeadlineOptions + `')`
+	}
+
+	searchQuery := `
+		WITH matches AS (
+			SELECT c.id, ` + rank + ` AS rank
+			FROM courses c
+			WHERE ` + strings.Join(conditions, " AND ") + `
+		), page AS (
+			SELECT id, rank FROM matches
+			WHERE ` + after + `
+			ORDER BY rank DESC, id DESC
+			LIMIT ` + strconv.Itoa(limit+1) + `
+		)
+		SELECT c.id, c.title, c.description, c.category, c.level, c.duration,
+		       ` + InstructorNamesSQL("c.id", "c.instructor") + ` AS instructor,
+		       c.rating, c.students, c.image, c.intro_material,
+		       ` + LessonsJSONSQL("c.id") + ` AS lessons, c.pre_test,
+		       c.post_test, c.post_work, c.final_project, c.has_post_work, c.has_final_project,
+		       c.certificate_delay, c.step_weights, c.status, c.publish_at, c.unpublish_at, c.is_template,
+		       c.created_at, c.updated_at, page.rank, ` + title + `, ` + snippet + `
+		FROM page
+		JOIN courses c ON c.id = page.id
+		ORDER BY page.rank DESC, page.id DESC
+	`
+
+	rows, err := db.Query(searchQuery, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	results := []CourseSearchResult{}
+	for rows.Next() {
+		var result CourseSearchResult
+		var highlight CourseSearchHighlight
+		course := &result.Course
+		err := rows.Scan(
+			&course.ID, &course.Title, &course.Description, &course.Category,
+			&course.Level, &course.Duration, &course.Instructor, &course.Rating,
+			&course.Students, &course.Image, &course.IntroMaterial, &course.Lessons,
+			&course.PreTest, &course.PostTest, &course.PostWork, &course.FinalProject,
+			&course.HasPostWork, &course.HasFinalProject, &course.CertificateDelay,
+			&course.StepWeights, &course.Status, &course.PublishAt, &course.UnpublishAt, &course.IsTemplate,
+			&course.CreatedAt, &course.UpdatedAt, &result.Rank, &highlight.Title, &highlight.Snippet,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		if tsQuery != "" {
+			highlight.Title = escapeHeadline(highlight.Title)
+			highlight.Snippet = escapeHeadline(highlight.Snippet)
+			result.Highlight = &highlight
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(results) > limit {
+		results = results[:limit]
+		last := results[limit-1]
+		next = encodeSearchCursor(last.Rank, last.ID)
+	}
+	return results, next, nil
+}
+
+// escapeHeadline escapes the HTML of a ts_headline result except its <mark> tags
+func escapeHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(escaped, "&lt;/mark&gt;", "</mark>")
+}
+
+// A search cursor is the rank and id of the last result of a page
+func encodeSearchCursor(rank float64, id int) string {
+	value := strconv.FormatFloat(rank, 'g', -1, 64) + ":" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeSearchCursor(cursor string) (float64, int, error) {
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidSearchCursor
+	}
+	rankValue, idValue, found := strings.Cut(string(value), ":")
+	if !found {
+		return 0, 0, ErrInvalidSearchCursor
+	}
+	rank, err := strconv.ParseFloat(rankValue, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidSearchCursor
+	}
+	id, err := strconv.Atoi(idValue)
+	if err != nil {
+		return 0, 0, ErrInvalidSearchCursor
+	}
+	return rank, id, nil
+}
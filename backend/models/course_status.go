@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Course statuses of the draft/publish workflow
+const (
+	CourseStatusDraft     = "draft"
+	CourseStatusReview    = "review"
+	CourseStatusPublished = "published"
+	CourseStatusArchived  = "archived"
+)
+
+// ErrInvalidCourseStatus is returned for an unknown status or an empty publish window
+var ErrInvalidCourseStatus = errors.New("status must be one of draft, review, published or archived and unpublishAt must be after publishAt")
+
+// CourseStatusRequest changes the status and publishing schedule of a course
+type CourseStatusRequest struct {
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publishAt"`
+	UnpublishAt *time.Time `json:"unpublishAt"`
+}
+
+// Validate checks the status and that the publishing window is not empty
+func (req CourseStatusRequest) Validate() error {
+	switch req.Status {
+	case CourseStatusDraft, CourseStatusReview, CourseStatusPublished, CourseStatusArchived:
+	default:
+		return ErrInvalidCourseStatus
+	}
+	if req.PublishAt != nil && req.UnpublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+		return ErrInvalidCourseStatus
+	}
+	return nil
+}
+
+// RequiresPublishPermission reports whether moving a course to the status makes
+// it visible to learners or takes it away from them
+func (req CourseStatusRequest) RequiresPublishPermission() bool {
+	return req.Status == CourseStatusPublished || req.Status == CourseStatusArchived
+}
+
+// CourseVisibleSQL returns a condition that holds when the course of table alias
+// is a published non-template course inside its publishing window, i.e. listed
+// and open to enrollment
+func CourseVisibleSQL(alias string) string {
+	return `(` + alias + `.status = 'published' AND NOT ` + alias + `.is_template
+		AND (` + alias + `.publish_at IS NULL OR ` + alias + `.publish_at <= CURRENT_TIMESTAMP)
+		AND (` + alias + `.unpublish_at IS NULL OR ` + alias + `.unpublish_at > CURRENT_TIMESTAMP))`
+}
+
+// IsVisible reports whether the course is a published non-template course inside
+// its publishing window at now
+func (c *Course) IsVisible(now time.Time) bool {
+	return c.Status == CourseStatusPublished && !c.IsTemplate &&
+		(c.PublishAt == nil || !c.PublishAt.After(now)) &&
+		(c.UnpublishAt == nil || c.UnpublishAt.After(now))
+}
+
+// IsReleased reports whether the course has been visible to learners at some
+// point before now. Released courses stay readable by ID after they are archived
+// or unpublished so enrolled learners keep access to their material.
+func (c *Course) IsReleased(now time.Time) bool {
+	if c.IsTemplate || (c.Status != CourseStatusPublished && c.Status != CourseStatusArchived) {
+		return false
+	}
+	return c.PublishAt == nil || !c.PublishAt.After(now)
+}
+
+// UpdateCourseStatus sets the status and publishing schedule of a course
+func UpdateCourseStatus(db *sql.DB, courseID int, req CourseStatusRequest) error {
+	result, err := db.Exec(`
+		UPDATE courses SET status = $1, publish_at = $2, unpublish_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`, req.Status, req.PublishAt, req.UnpublishAt, courseID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrEmailVerificationTokenInvalid is returned when a verification token is unknown, used or expired
+var ErrEmailVerificationTokenInvalid = errors.New("invalid or expired email verification token")
+
+// CreateEmailVerificationToken issues a single-use verification token and returns the raw token.
+// Any earlier unused tokens of the user are invalidated.
+func CreateEmailVerificationToken(db *sql.DB, userID int, ttl time.Duration) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO email_verification_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, hashToken(token), time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyEmailWithToken consumes a verification token and marks the user's email as verified
+func VerifyEmailWithToken(db *sql.DB, token string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id, userID int
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, expires_at, used_at
+		FROM email_verification_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashToken(token)).Scan(&id, &userID, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrEmailVerificationTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return 0, ErrEmailVerificationTokenInvalid
+	}
+
+	_, err = tx.Exec(`UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// MarkEmailVerified marks a user's email as verified without a token
+func MarkEmailVerified(db *sql.DB, userID int) error {
+	_, err := db.Exec(`UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = $1 AND email_verified_at IS NULL`, userID)
+	return err
+}
+
+// ResetEmailVerification marks a user's email as unverified, e.g. after it changes
+func ResetEmailVerification(db *sql.DB, userID int) error {
+	_, err := db.Exec(`UPDATE users SET email_verified_at = NULL WHERE id = $1`, userID)
+	return err
+}
@@ -151,28 +151,45 @@ func GetGradesByCourse(db *sql.DB, courseID int) ([]GradeWithDetails, error) {
 	return grades, nil
 }
 
-// GetAllGrades gets all grades (for admin)
-func GetAllGrades(db *sql.DB) ([]GradeWithDetails, error) {
-	query := `
-		SELECT 
+// GradeListSpec are the sort and filter fields of the admin grade list
+var GradeListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"id":           {Column: "g.id", Type: ListNumber},
+		"userId":       {Column: "g.user_id", Type: ListNumber},
+		"courseId":     {Column: "g.course_id", Type: ListNumber},
+		"submissionId": {Column: "g.submission_id", Type: ListNumber},
+		"grade":        {Column: "g.grade", Type: ListNumber},
+		"gradedAt":     {Column: "g.graded_at", Type: ListTime},
+		"createdAt":    {Column: "g.created_at", Type: ListTime},
+		"updatedAt":    {Column: "g.updated_at", Type: ListTime},
+		"userName":     {Column: "u.full_name", Type: ListText},
+		"courseTitle":  {Column: "c.title", Type: ListText},
+	},
+	SearchColumns: []string{"u.full_name", "c.title", "g.feedback"},
+	DefaultSort:   "-gradedAt",
+	IDColumn:      "g.id",
+}
+
+// GetAllGrades gets a page of grades (for admin).
+// A non-zero instructorID limits the result to that instructor's courses.
+func GetAllGrades(db *sql.DB, instructorID int, list *ListQuery) ([]GradeWithDetails, *ListPage, error) {
+	columns := `
 			g.id, g.user_id, g.course_id, g.submission_id, g.grade, g.feedback, 
 			g.graded_at, g.created_at, g.updated_at,
 			u.full_name as user_name,
-			c.title as course_title
-		FROM grades g
+			c.title as course_title`
+	from := `grades g
 		JOIN users u ON g.user_id = u.id
-		JOIN courses c ON g.course_id = c.id
-		ORDER BY g.graded_at DESC
-		LIMIT 100
-	`
+		JOIN courses c ON g.course_id = c.id`
+	where := []string{InstructorCourseFilter("g.course_id", "$1")}
 
-	rows, err := db.Query(query)
+	rows, page, err := list.Query(db, columns, from, where, instructorID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var grades []GradeWithDetails
+	grades := []GradeWithDetails{}
 	for rows.Next() {
 		var grade GradeWithDetails
 		err := rows.Scan(
@@ -189,12 +206,12 @@ func GetAllGrades(db *sql.DB) ([]GradeWithDetails, error) {
 			&grade.CourseTitle,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		grades = append(grades, grade)
 	}
 
-	return grades, nil
+	return grades, page, rows.Err()
 }
 
 // GetGradeBySubmission gets grade for a specific submission
@@ -0,0 +1,430 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidLessons is returned when a course's lessons payload cannot be stored
+var ErrInvalidLessons = errors.New("invalid lessons")
+
+// ErrLessonOrderMismatch is returned when a reorder request does not list every lesson of the course exactly once
+var ErrLessonOrderMismatch = errors.New("lesson order must list every lesson of the course exactly once")
+
+// Lesson is one lesson of a course. Content holds the ordered content blocks
+// (text, video, pdf, ...); Metadata keeps any other lesson settings.
+type Lesson struct {
+	ID        int             `json:"id"`
+	CourseID  int             `json:"courseId"`
+	ModuleID  *int            `json:"moduleId"`
+	Position  int             `json:"position"`
+	Title     string          `json:"title"`
+	Type      string          `json:"type"`
+	Content   json.RawMessage `json:"content"`
+	Metadata  json.RawMessage `json:"metadata"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// LessonRequest creates or updates a lesson; nil fields are left unchanged on update
+type LessonRequest struct {
+	Title    *string          `json:"title"`
+	Type     *string          `json:"type"`
+	Content  *json.RawMessage `json:"content"`
+	Metadata *json.RawMessage `json:"metadata"`
+	// ModuleID places the lesson in a module of the course; 0 removes it from its module
+	ModuleID *int `json:"moduleId"`
+	// Position is 1-based; lessons at or after it move down. Only used on create.
+	Position *int `json:"position"`
+}
+
+// LessonOrderRequest lists the lesson IDs of a course in their new order
+type LessonOrderRequest struct {
+	LessonIDs []int `json:"lessonIds"`
+}
+
+// sqlExecutor is satisfied by both *sql.DB and *sql.Tx
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// LessonsJSONSQL returns an expression building a course's lessons as the JSON
+// array the API has always served: each lesson's metadata merged with its id,
+// title, type and content blocks, in lesson order
+func LessonsJSONSQL(courseIDColumn string) string {
+	return `(SELECT COALESCE(jsonb_agg(
+			l.metadata || jsonb_build_object('id', l.id, 'moduleId', l.module_id, 'title', l.title, 'type', l.type, 'content', l.content)
+			ORDER BY l.position, l.id), '[]'::jsonb)
+		FROM lessons l WHERE l.course_id = ` + courseIDColumn + `)`
+}
+
+// GetCourseLessonsJSON returns a course's lessons in the shape of LessonsJSONSQL
+func GetCourseLessonsJSON(db *sql.DB, courseID int) (*json.RawMessage, error) {
+	var lessons json.RawMessage
+	if err := db.QueryRow(`SELECT `+LessonsJSONSQL("$1"), courseID).Scan(&lessons); err != nil {
+		return nil, err
+	}
+	return &lessons, nil
+}
+
+const lessonColumns = `id, course_id, module_id, position, title, type, content, metadata, created_at, updated_at`
+
+func scanLesson(row interface{ Scan(...interface{}) error }) (*Lesson, error) {
+	var lesson Lesson
+	var moduleID sql.NullInt64
+	var content, metadata []byte
+	err := row.Scan(&lesson.ID, &lesson.CourseID, &moduleID, &lesson.Position, &lesson.Title, &lesson.Type,
+		&content, &metadata, &lesson.CreatedAt, &lesson.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if moduleID.Valid {
+		id := int(moduleID.Int64)
+		lesson.ModuleID = &id
+	}
+	lesson.Content = json.RawMessage(content)
+	lesson.Metadata = json.RawMessage(metadata)
+	return &lesson, nil
+}
+
+// GetCourseLessons returns a course's lessons in order
+func GetCourseLessons(db *sql.DB, courseID int) ([]Lesson, error) {
+	rows, err := db.Query(`SELECT `+lessonColumns+` FROM lessons WHERE course_id = $1 ORDER BY position, id`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lessons := []Lesson{}
+	for rows.Next() {
+		lesson, err := scanLesson(rows)
+		if err != nil {
+			return nil, err
+		}
+		lessons = append(lessons, *lesson)
+	}
+
+	return lessons, rows.Err()
+}
+
+// GetLesson returns a lesson of a course, or sql.ErrNoRows when the course has no such lesson
+func GetLesson(db *sql.DB, courseID, lessonID int) (*Lesson, error) {
+	return scanLesson(db.QueryRow(`SELECT `+lessonColumns+` FROM lessons WHERE id = $1 AND course_id = $2`, lessonID, courseID))
+}
+
+// EnrollmentHasLesson reports whether lessonID is a lesson of the course
+// revision a user's enrollment is pinned to, so lessons deleted from the
+// course since stay available to learners still on that revision. Without a
+// pinned revision the course's current lessons are checked.
+func EnrollmentHasLesson(db *sql.DB, userID, courseID, lessonID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT COALESCE(
+			(SELECT r.content->'lessons' @> jsonb_build_array(jsonb_build_object('id', $3::int))
+			 FROM course_enrollments e
+			 JOIN course_revisions r ON r.id = e.revision_id
+			 WHERE e.user_id = $1 AND e.course_id = $2),
+			EXISTS(SELECT 1 FROM lessons WHERE id = $3 AND course_id = $2))
+	`, userID, courseID, lessonID).Scan(&exists)
+	return exists, err
+}
+
+// CreateLesson adds a lesson to a course in tx, at the end unless a position is
+// given. It returns sql.ErrNoRows when the course does not exist.
+func CreateLesson(tx *sql.Tx, courseID int, req LessonRequest) (*Lesson, error) {
+	title, lessonType, content, metadata, err := lessonFields(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockCourse(tx, courseID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM lessons WHERE course_id = $1`, courseID).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	moduleID, err := lessonModuleID(tx, courseID, req.ModuleID)
+	if err != nil {
+		return nil, err
+	}
+
+	position := count + 1
+	if req.Position != nil && *req.Position >= 1 && *req.Position <= count {
+		position = *req.Position
+		_, err := tx.Exec(`UPDATE lessons SET position = position + 1 WHERE course_id = $1 AND position >= $2`, courseID, position)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return scanLesson(tx.QueryRow(`
+		INSERT INTO lessons (course_id, module_id, position, title, type, content, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+lessonColumns, courseID, moduleID, position, title, lessonType, string(content), string(metadata)))
+}
+
+// UpdateLesson changes the provided fields of a lesson in tx
+func UpdateLesson(tx *sql.Tx, courseID, lessonID int, req LessonRequest) (*Lesson, error) {
+	if err := validateLessonJSON(req.Content, req.Metadata); err != nil {
+		return nil, err
+	}
+
+	if err := lockCourse(tx, courseID); err != nil {
+		return nil, err
+	}
+
+	moduleID, err := lessonModuleID(tx, courseID, req.ModuleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var content, metadata *string
+	if req.Content != nil {
+		value := string(*req.Content)
+		content = &value
+	}
+	if req.Metadata != nil {
+		value := string(*req.Metadata)
+		metadata = &value
+	}
+
+	return scanLesson(tx.QueryRow(`
+		UPDATE lessons SET
+			title = COALESCE($1, title),
+			type = COALESCE(NULLIF($2, ''), type),
+			content = COALESCE($3::jsonb, content),
+			metadata = COALESCE($4::jsonb, metadata),
+			module_id = CASE WHEN $5 THEN $6 ELSE module_id END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7 AND course_id = $8
+		RETURNING `+lessonColumns, req.Title, req.Type, content, metadata, req.ModuleID != nil, moduleID, lessonID, courseID))
+}
+
+// DeleteLesson removes a lesson in tx and closes the gap in the course's order.
+// Progress on the lesson is deleted with it.
+func DeleteLesson(tx *sql.Tx, courseID, lessonID int) error {
+	if err := lockCourse(tx, courseID); err != nil {
+		return err
+	}
+
+	var position int
+	err := tx.QueryRow(`DELETE FROM lessons WHERE id = $1 AND course_id = $2 RETURNING position`, lessonID, courseID).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE lessons SET position = position - 1 WHERE course_id = $1 AND position > $2`, courseID, position)
+	return err
+}
+
+// ReorderLessons sets the order of a course's lessons in tx
+func ReorderLessons(tx *sql.Tx, courseID int, lessonIDs []int) error {
+	if err := lockCourse(tx, courseID); err != nil {
+		return err
+	}
+
+	existing, err := courseRowIDs(tx, "lessons", courseID)
+	if err != nil {
+		return err
+	}
+
+	if !isPermutation(lessonIDs, existing) {
+		return ErrLessonOrderMismatch
+	}
+
+	for i, id := range lessonIDs {
+		_, err := tx.Exec(`UPDATE lessons SET position = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, i+1, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncCourseLessons makes a course's lessons match a legacy lessons JSON array,
+// as sent by course create and update requests. Entries whose id is a lesson of
+// the course update it in place, other entries become new lessons and lessons
+// missing from the array are deleted. The array order becomes the lesson order.
+// Entries without a moduleId key keep their module.
+func SyncCourseLessons(exec sqlExecutor, courseID int, raw json.RawMessage) error {
+	var entries []map[string]json.RawMessage
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return fmt.Errorf("%w: lessons must be an array of objects", ErrInvalidLessons)
+		}
+	}
+
+	existing, err := courseRowIDs(exec, "lessons", courseID)
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[int]bool)
+	for i, entry := range entries {
+		var req LessonRequest
+		var id int
+		for key, value := range entry {
+			value := value
+			switch key {
+			case "id":
+				// Client-generated IDs (strings, timestamps) simply don't match
+				json.Unmarshal(value, &id)
+			case "title":
+				req.Title = new(string)
+				json.Unmarshal(value, req.Title)
+			case "type":
+				req.Type = new(string)
+				json.Unmarshal(value, req.Type)
+			case "moduleId":
+				req.ModuleID = new(int)
+				json.Unmarshal(value, req.ModuleID)
+			case "content":
+				// Older lessons stored plain text instead of content blocks
+				var text string
+				if json.Unmarshal(value, &text) == nil {
+					value, _ = json.Marshal([]map[string]string{{"type": "text", "content": text}})
+				}
+				req.Content = &value
+			}
+		}
+		delete(entry, "id")
+		delete(entry, "title")
+		delete(entry, "type")
+		delete(entry, "moduleId")
+		delete(entry, "content")
+		delete(entry, "position")
+		extra, _ := json.Marshal(entry)
+		req.Metadata = (*json.RawMessage)(&extra)
+
+		title, lessonType, content, metadata, err := lessonFields(req)
+		if err != nil {
+			return fmt.Errorf("lesson %d: %w", i+1, err)
+		}
+		moduleID, err := lessonModuleID(exec, courseID, req.ModuleID)
+		if err == ErrModuleNotInCourse {
+			return fmt.Errorf("%w: lesson %d: %v", ErrInvalidLessons, i+1, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		if existing[id] && !kept[id] {
+			_, err = exec.Exec(`
+				UPDATE lessons SET position = $1, title = $2, type = $3, content = $4, metadata = $5,
+					module_id = CASE WHEN $6 THEN $7 ELSE module_id END, updated_at = CURRENT_TIMESTAMP
+				WHERE id = $8
+			`, i+1, title, lessonType, string(content), string(metadata), req.ModuleID != nil, moduleID, id)
+		} else {
+			err = exec.QueryRow(`
+				INSERT INTO lessons (course_id, module_id, position, title, type, content, metadata)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING id
+			`, courseID, moduleID, i+1, title, lessonType, string(content), string(metadata)).Scan(&id)
+		}
+		if err != nil {
+			return err
+		}
+		kept[id] = true
+	}
+
+	for id := range existing {
+		if !kept[id] {
+			if _, err := exec.Exec(`DELETE FROM lessons WHERE id = $1`, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lockCourse serializes lesson changes and the revisions recording them per
+// course. It returns
+// sql.ErrNoRows when the course does not exist.
+func lockCourse(tx *sql.Tx, courseID int) error {
+	var id int
+	return tx.QueryRow(`SELECT id FROM courses WHERE id = $1 FOR UPDATE`, courseID).Scan(&id)
+}
+
+// courseRowIDs returns the IDs of a course's rows in table (lessons, course_modules) as a set
+func courseRowIDs(exec sqlExecutor, table string, courseID int) (map[int]bool, error) {
+	rows, err := exec.Query(`SELECT id FROM `+table+` WHERE course_id = $1`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// lessonModuleID checks a requested module and returns the value to store:
+// nil for no module, or the module ID when it belongs to the course
+func lessonModuleID(exec sqlExecutor, courseID int, moduleID *int) (interface{}, error) {
+	if moduleID == nil || *moduleID == 0 {
+		return nil, nil
+	}
+	ok, err := ModuleBelongsToCourse(exec, courseID, *moduleID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrModuleNotInCourse
+	}
+	return *moduleID, nil
+}
+
+// lessonFields applies defaults to a lesson request and checks its JSON fields
+func lessonFields(req LessonRequest) (string, string, json.RawMessage, json.RawMessage, error) {
+	if err := validateLessonJSON(req.Content, req.Metadata); err != nil {
+		return "", "", nil, nil, err
+	}
+
+	title, lessonType := "", "reading"
+	content, metadata := json.RawMessage(`[]`), json.RawMessage(`{}`)
+	if req.Title != nil {
+		title = *req.Title
+	}
+	if req.Type != nil && *req.Type != "" {
+		lessonType = *req.Type
+	}
+	if req.Content != nil && string(*req.Content) != "null" {
+		content = *req.Content
+	}
+	if req.Metadata != nil && string(*req.Metadata) != "null" {
+		metadata = *req.Metadata
+	}
+	return title, lessonType, content, metadata, nil
+}
+
+// validateLessonJSON checks that content is an array of blocks and metadata an object
+func validateLessonJSON(content, metadata *json.RawMessage) error {
+	if content != nil && string(*content) != "null" {
+		var blocks []map[string]interface{}
+		if err := json.Unmarshal(*content, &blocks); err != nil {
+			return fmt.Errorf("%w: content must be an array of content blocks", ErrInvalidLessons)
+		}
+	}
+	if metadata != nil && string(*metadata) != "null" {
+		var object map[string]interface{}
+		if err := json.Unmarshal(*metadata, &object); err != nil {
+			return fmt.Errorf("%w: metadata must be an object", ErrInvalidLessons)
+		}
+	}
+	return nil
+}
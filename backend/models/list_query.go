@@ -0,0 +1,458 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes of list endpoints
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ErrInvalidListQuery is returned for list parameters a list does not support
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// ListFieldType decides how a field's filter values are parsed and which
+// operators it supports
+type ListFieldType int
+
+const (
+	ListText ListFieldType = iota
+	ListNumber
+	ListBool
+	ListTime
+)
+
+// ListField is a field a list can be sorted and filtered by
+type ListField struct {
+	Column string // SQL expression
+	Type   ListFieldType
+}
+
+// ListSpec describes the fields of a list. Field names are the JSON keys of
+// the listed items so clients sort and filter by what they see.
+type ListSpec struct {
+	Fields map[string]ListField
+	// SearchColumns are matched, case-insensitively, by the q parameter
+	SearchColumns []string
+	// DefaultSort is used without a sort parameter, e.g. "-createdAt"
+	DefaultSort string
+	// IDColumn breaks ties between equal sort values so pages never overlap
+	IDColumn string
+}
+
+// ListFilter is one filter expression, e.g. createdAt[gte]=2024-01-01
+type ListFilter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ListQuery is a parsed page request of a list: limit and cursor, sort fields
+// and filters
+type ListQuery struct {
+	spec    ListSpec
+	Limit   int
+	Search  string
+	Sort    []string
+	Filters []ListFilter
+	// after are the sort keys of the last item of the previous page, nil
+	// on the first page
+	after []*string
+}
+
+// listSortKey is a column pages are ordered by
+type listSortKey struct {
+	Column   string
+	Desc     bool
+	Nullable bool // NULLs sort last
+}
+
+// listCursor is the content of a cursor: the sort it was made for and the
+// sort keys, as text, of the last item of a page
+type listCursor struct {
+	Sort string    `json:"s"`
+	Keys []*string `json:"k"`
+}
+
+// ListPage describes the page of a list that was returned
+type ListPage struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// listComparisons maps filter operators to SQL comparison operators
+var listComparisons = map[string]string{
+	"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">=",
+}
+
+// listFilterPattern matches a filter parameter: a field name with an optional
+// [operator]
+var listFilterPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*)(?:\[([a-z]+)\])?$`)
+
+// listReservedParams are the parameters that are not filters
+var listReservedParams = map[string]bool{"limit": true, "cursor": true, "sort": true, "q": true}
+
+// ParseListQuery reads limit, cursor, sort, q and filter parameters for a
+// list. Filters are written field=value or field[op]=value with op one of eq,
+// ne, lt, lte, gt, gte, contains (text), in (comma-separated values) and null
+// (true or false). Parameters that name no field of the list are ignored.
+func ParseListQuery(values url.Values, spec ListSpec) (*ListQuery, error) {
+	q := &ListQuery{spec: spec, Limit: DefaultListLimit, Search: strings.TrimSpace(values.Get("q"))}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxListLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListQuery, MaxListLimit)
+		}
+		q.Limit = limit
+	}
+	if q.Search != "" && len(spec.SearchColumns) == 0 {
+		return nil, fmt.Errorf("%w: this list does not support q", ErrInvalidListQuery)
+	}
+
+	sort := values.Get("sort")
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if _, ok := spec.Fields[strings.TrimPrefix(field, "-")]; !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %s", ErrInvalidListQuery, field)
+		}
+		q.Sort = append(q.Sort, field)
+	}
+	if value := values.Get("cursor"); value != "" {
+		after, err := q.decodeCursor(value)
+		if err != nil {
+			return nil, err
+		}
+		q.after = after
+	}
+
+	for key, params := range values {
+		if listReservedParams[key] {
+			continue
+		}
+		match := listFilterPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		field, ok := spec.Fields[match[1]]
+		if !ok {
+			continue
+		}
+		op := match[2]
+		if op == "" {
+			op = "eq"
+		}
+		for _, value := range params {
+			if err := validateListFilter(field, op, value); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidListQuery, key, err)
+			}
+			q.Filters = append(q.Filters, ListFilter{Field: match[1], Op: op, Value: value})
+		}
+	}
+	return q, nil
+}
+
+// validateListFilter checks that a field supports op and value parses as the
+// field's type
+func validateListFilter(field ListField, op, value string) error {
+	switch {
+	case op == "null":
+		_, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("null takes true or false")
+		}
+		return nil
+	case op == "contains":
+		if field.Type != ListText {
+			return errors.New("contains only applies to text fields")
+		}
+		return nil
+	case op == "in":
+		for _, item := range strings.Split(value, ",") {
+			if _, err := parseListValue(field.Type, strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case listComparisons[op] != "":
+		if field.Type == ListBool && op != "eq" && op != "ne" {
+			return fmt.Errorf("%s does not apply to true/false fields", op)
+		}
+		_, err := parseListValue(field.Type, value)
+		return err
+	}
+	return fmt.Errorf("unknown operator %s", op)
+}
+
+// parseListValue converts a filter value to the field's type
+func parseListValue(fieldType ListFieldType, value string) (interface{}, error) {
+	switch fieldType {
+	case ListNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return number, nil
+	case ListBool:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not true or false", value)
+		}
+		return boolean, nil
+	case ListTime:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a date (YYYY-MM-DD) or RFC 3339 time", value)
+	}
+	return value, nil
+}
+
+// Query runs the list. from is the FROM clause with its joins, where are the
+// list's own conditions with their arguments args ($1, $2, ...). It returns
+// the rows of the requested page, selecting columns, and the page metadata.
+// Pages are keyset pages: a page starts after the sort keys of the cursor, so
+// items added or removed meanwhile neither repeat nor skip items.
+func (q *ListQuery) Query(db *sql.DB, columns, from string, where []string, args ...interface{}) (*sql.Rows, *ListPage, error) {
+	conditions := append([]string{}, where...)
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.Search != "" {
+		pattern := arg("%" + escapeLike(q.Search) + "%")
+		var matches []string
+		for _, column := range q.spec.SearchColumns {
+			matches = append(matches, column+" ILIKE "+pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	for _, filter := range q.Filters {
+		field := q.spec.Fields[filter.Field]
+		switch filter.Op {
+		case "null":
+			isNull, _ := strconv.ParseBool(filter.Value)
+			if isNull {
+				conditions = append(conditions, field.Column+" IS NULL")
+			} else {
+				conditions = append(conditions, field.Column+" IS NOT NULL")
+			}
+		case "contains":
+			conditions = append(conditions, field.Column+" ILIKE "+arg("%"+escapeLike(filter.Value)+"%"))
+		case "in":
+			var placeholders []string
+			for _, item := range strings.Split(filter.Value, ",") {
+				value, _ := parseListValue(field.Type, strings.TrimSpace(item))
+				placeholders = append(placeholders, arg(value))
+			}
+			conditions = append(conditions, field.Column+" IN ("+strings.Join(placeholders, ", ")+")")
+		default:
+			value, _ := parseListValue(field.Type, filter.Value)
+			conditions = append(conditions, field.Column+" "+listComparisons[filter.Op]+" "+arg(value))
+		}
+	}
+
+	whereSQL := ""
+	if len(conditions) > 0 {
+		whereSQL = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// The total counts the whole list, not what is left after the cursor
+	page := &ListPage{Limit: q.Limit}
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+from+whereSQL, args...).Scan(&page.Total); err != nil {
+		return nil, nil, err
+	}
+
+	if q.after != nil {
+		conditions = append(conditions, q.afterCondition(arg))
+		whereSQL = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	next, err := q.nextCursor(db, from+whereSQL, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	page.NextCursor = next
+
+	rows, err := db.Query("SELECT "+columns+" FROM "+from+whereSQL+q.orderBy()+
+		fmt.Sprintf(" LIMIT %d", q.Limit), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, page, nil
+}
+
+// sortKeys returns the columns pages are ordered by: the sort fields, then
+// the ID column in the direction of the first sort field
+func (q *ListQuery) sortKeys() []listSortKey {
+	var keys []listSortKey
+	idDesc := true
+	for i, field := range q.Sort {
+		desc := strings.HasPrefix(field, "-")
+		if i == 0 {
+			idDesc = desc
+		}
+		keys = append(keys, listSortKey{
+			Column:   q.spec.Fields[strings.TrimPrefix(field, "-")].Column,
+			Desc:     desc,
+			Nullable: true,
+		})
+	}
+	if q.spec.IDColumn != "" {
+		keys = append(keys, listSortKey{Column: q.spec.IDColumn, Desc: idDesc})
+	}
+	return keys
+}
+
+// orderBy returns the ORDER BY clause of the sort fields, then the ID column
+func (q *ListQuery) orderBy() string {
+	var order []string
+	for _, key := range q.sortKeys() {
+		clause := key.Column + " ASC"
+		if key.Desc {
+			clause = key.Column + " DESC"
+		}
+		if key.Nullable {
+			clause += " NULLS LAST"
+		}
+		order = append(order, clause)
+	}
+	if len(order) == 0 {
+		return ""
+	}
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// afterCondition returns the condition selecting the items that sort after
+// the cursor's keys. Keys are compared as text parameters, which Postgres
+// reads as the type of the column they are compared with.
+func (q *ListQuery) afterCondition(arg func(interface{}) string) string {
+	keys := q.sortKeys()
+	var alternatives []string
+	for i, key := range keys {
+		if q.after[i] == nil {
+			// NULLs sort last, so only ties on later keys come after
+			continue
+		}
+
+		// Equal on the keys before, after on this one
+		var terms []string
+		for j := 0; j < i; j++ {
+			if q.after[j] == nil {
+				terms = append(terms, keys[j].Column+" IS NULL")
+			} else {
+				terms = append(terms, keys[j].Column+" = "+arg(*q.after[j]))
+			}
+		}
+		comparison := " > "
+		if key.Desc {
+			comparison = " < "
+		}
+		after := key.Column + comparison + arg(*q.after[i])
+		if key.Nullable {
+			after = "(" + after + " OR " + key.Column + " IS NULL)"
+		}
+		alternatives = append(alternatives, "("+strings.Join(append(terms, after), " AND ")+")")
+	}
+	if len(alternatives) == 0 {
+		return "FALSE"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// nextCursor returns the cursor of the page after this one, empty when this
+// is the last page. fromWhere is the FROM and WHERE clause of the page.
+func (q *ListQuery) nextCursor(db *sql.DB, fromWhere string, args []interface{}) (string, error) {
+	keys := q.sortKeys()
+	if len(keys) == 0 {
+		return "", nil
+	}
+	var selected []string
+	for _, key := range keys {
+		selected = append(selected, "("+key.Column+")::text")
+	}
+
+	// The last item of this page, and whether any item follows it
+	rows, err := db.Query("SELECT "+strings.Join(selected, ", ")+" FROM "+fromWhere+q.orderBy()+
+		fmt.Sprintf(" LIMIT 2 OFFSET %d", q.Limit-1), args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var last []*string
+	count := 0
+	for rows.Next() {
+		count++
+		if count > 1 {
+			continue
+		}
+		values := make([]sql.NullString, len(keys))
+		targets := make([]interface{}, len(keys))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return "", err
+		}
+		last = make([]*string, len(keys))
+		for i, value := range values {
+			if value.Valid {
+				text := value.String
+				last[i] = &text
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if count < 2 {
+		return "", nil
+	}
+	return q.encodeCursor(last)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// A list cursor holds the sort keys of the last item of a page. Clients treat
+// it as opaque; it is only valid with the sort it was made for.
+func (q *ListQuery) encodeCursor(keys []*string) (string, error) {
+	content, err := json.Marshal(listCursor{Sort: strings.Join(q.Sort, ","), Keys: keys})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+func (q *ListQuery) decodeCursor(value string) ([]*string, error) {
+	var cursor listCursor
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil && json.Unmarshal(content, &cursor) == nil &&
+		cursor.Sort == strings.Join(q.Sort, ",") && len(cursor.Keys) == len(q.sortKeys()) {
+		return cursor.Keys, nil
+	}
+	return nil, fmt.Errorf("%w: cursor must be the nextCursor of a previous page with the same sort", ErrInvalidListQuery)
+}
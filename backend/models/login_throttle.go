@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"lms-backend/security"
+)
+
+// UsernameThrottleKey returns the throttle key for a login username
+func UsernameThrottleKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// IPThrottleKey returns the throttle key for a client address
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// GetLoginLockedUntil returns the latest lockout expiry among keys, or nil when none is locked
+func GetLoginLockedUntil(db *sql.DB, keys ...string) (*time.Time, error) {
+	var lockedUntil *time.Time
+	for _, key := range keys {
+		var until sql.NullTime
+		err := db.QueryRow(`SELECT locked_until FROM login_throttles WHERE throttle_key = $1`, key).Scan(&until)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if until.Valid && until.Time.After(time.Now()) && (lockedUntil == nil || until.Time.After(*lockedUntil)) {
+			t := until.Time
+			lockedUntil = &t
+		}
+	}
+	return lockedUntil, nil
+}
+
+// RecordLoginFailure increments the failure counter for key and applies the
+// lockout policy. It returns the new lockout expiry, if any.
+func RecordLoginFailure(db *sql.DB, key string, policy security.LockoutPolicy) (*time.Time, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO login_throttles (throttle_key) VALUES ($1) ON CONFLICT (throttle_key) DO NOTHING`, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var failedCount int
+	var lastFailedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT failed_count, last_failed_at
+		FROM login_throttles
+		WHERE throttle_key = $1
+		FOR UPDATE
+	`, key).Scan(&failedCount, &lastFailedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if lastFailedAt.Valid && now.Sub(lastFailedAt.Time) > policy.Window {
+		failedCount = 0
+	}
+	failedCount++
+
+	var lockedUntil *time.Time
+	if d := policy.LockDuration(failedCount); d > 0 {
+		t := now.Add(d)
+		lockedUntil = &t
+	}
+
+	_, err = tx.Exec(`
+		UPDATE login_throttles
+		SET failed_count = $1, locked_until = $2, last_failed_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE throttle_key = $4
+	`, failedCount, lockedUntil, now, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return lockedUntil, nil
+}
+
+// ClearLoginFailures removes the failure counters and lockouts of the given keys
+func ClearLoginFailures(db *sql.DB, keys ...string) error {
+	for _, key := range keys {
+		if _, err := db.Exec(`DELETE FROM login_throttles WHERE throttle_key = $1`, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// moduleStagePrefix prefixes the stage name of a module lock in course_stage_locks
+const moduleStagePrefix = "module:"
+
+// ErrModuleOrderMismatch is returned when a reorder request does not list every module of the course exactly once
+var ErrModuleOrderMismatch = errors.New("module order must list every module of the course exactly once")
+
+// ErrModuleNotInCourse is returned when a lesson or quiz is assigned to a module of another course
+var ErrModuleNotInCourse = errors.New("module does not belong to the course")
+
+// CourseModule is a section of a course grouping lessons and quizzes
+type CourseModule struct {
+	ID          int       `json:"id"`
+	CourseID    int       `json:"courseId"`
+	Position    int       `json:"position"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	LessonIDs   []int64   `json:"lessonIds"`
+	QuizIDs     []int64   `json:"quizIds"`
+	IsLocked    bool      `json:"isLocked"`
+	LockMessage string    `json:"lockMessage"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// ModuleRequest creates or updates a module; nil fields are left unchanged on update
+type ModuleRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	// Position is 1-based; modules at or after it move down. Only used on create.
+	Position *int `json:"position"`
+}
+
+// ModuleOrderRequest lists the module IDs of a course in their new order
+type ModuleOrderRequest struct {
+	ModuleIDs []int `json:"moduleIds"`
+}
+
+// ModuleStageName returns the course_stage_locks stage name of a module
+func ModuleStageName(moduleID int) string {
+	return moduleStagePrefix + strconv.Itoa(moduleID)
+}
+
+// ParseModuleStageName returns the module ID of a module stage name
+func ParseModuleStageName(stageName string) (int, bool) {
+	if !strings.HasPrefix(stageName, moduleStagePrefix) {
+		return 0, false
+	}
+	moduleID, err := strconv.Atoi(strings.TrimPrefix(stageName, moduleStagePrefix))
+	if err != nil || moduleID <= 0 {
+		return 0, false
+	}
+	return moduleID, true
+}
+
+// ModulesJSONSQL returns an expression building a course's modules in order as a
+// JSON array, each with its ordered lesson IDs, quiz IDs and lock state
+func ModulesJSONSQL(courseIDColumn string) string {
+	return `(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+			'id', m.id,
+			'title', m.title,
+			'description', m.description,
+			'position', m.position,
+			'lessonIds', COALESCE((SELECT jsonb_agg(l.id ORDER BY l.position, l.id) FROM lessons l WHERE l.module_id = m.id), '[]'::jsonb),
+			'quizIds', COALESCE((SELECT jsonb_agg(q.id ORDER BY q.id) FROM quizzes q WHERE q.module_id = m.id AND q.is_active = TRUE), '[]'::jsonb),
+			'isLocked', COALESCE(sl.is_locked, FALSE),
+			'lockMessage', COALESCE(sl.lock_message, '')
+		) ORDER BY m.position, m.id), '[]'::jsonb)
+		FROM course_modules m
+		LEFT JOIN course_stage_locks sl ON sl.module_id = m.id
+		WHERE m.course_id = ` + courseIDColumn + `)`
+}
+
+const moduleSelect = `
+	SELECT m.id, m.course_id, m.position, m.title, m.description,
+	       ARRAY(SELECT l.id FROM lessons l WHERE l.module_id = m.id ORDER BY l.position, l.id),
+	       ARRAY(SELECT q.id FROM quizzes q WHERE q.module_id = m.id AND q.is_active = TRUE ORDER BY q.id),
+	       COALESCE(sl.is_locked, FALSE), COALESCE(sl.lock_message, ''),
+	       m.created_at, m.updated_at
+	FROM course_modules m
+	LEFT JOIN course_stage_locks sl ON sl.module_id = m.id
+`
+
+func scanModule(row interface{ Scan(...interface{}) error }) (*CourseModule, error) {
+	var module CourseModule
+	err := row.Scan(&module.ID, &module.CourseID, &module.Position, &module.Title, &module.Description,
+		pq.Array(&module.LessonIDs), pq.Array(&module.QuizIDs), &module.IsLocked, &module.LockMessage,
+		&module.CreatedAt, &module.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if module.LessonIDs == nil {
+		module.LessonIDs = []int64{}
+	}
+	if module.QuizIDs == nil {
+		module.QuizIDs = []int64{}
+	}
+	return &module, nil
+}
+
+// GetCourseModules returns a course's modules in order
+func GetCourseModules(db *sql.DB, courseID int) ([]CourseModule, error) {
+	rows, err := db.Query(moduleSelect+` WHERE m.course_id = $1 ORDER BY m.position, m.id`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	modules := []CourseModule{}
+	for rows.Next() {
+		module, err := scanModule(rows)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, *module)
+	}
+
+	return modules, rows.Err()
+}
+
+// GetModule returns a module of a course, or sql.ErrNoRows when the course has no such module
+func GetModule(db *sql.DB, courseID, moduleID int) (*CourseModule, error) {
+	return scanModule(db.QueryRow(moduleSelect+` WHERE m.id = $1 AND m.course_id = $2`, moduleID, courseID))
+}
+
+// CreateModule adds a module to a course, at the end unless a position is given.
+// It returns sql.ErrNoRows when the course does not exist.
+func CreateModule(db *sql.DB, courseID int, req ModuleRequest) (*CourseModule, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockCourse(tx, courseID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM course_modules WHERE course_id = $1`, courseID).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	position := count + 1
+	if req.Position != nil && *req.Position >= 1 && *req.Position <= count {
+		position = *req.Position
+		_, err := tx.Exec(`UPDATE course_modules SET position = position + 1 WHERE course_id = $1 AND position >= $2`, courseID, position)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	title, description := "", ""
+	if req.Title != nil {
+		title = *req.Title
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	var moduleID int
+	err = tx.QueryRow(`
+		INSERT INTO course_modules (course_id, position, title, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, courseID, position, title, description).Scan(&moduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetModule(db, courseID, moduleID)
+}
+
+// UpdateModule changes the provided fields of a module
+func UpdateModule(db *sql.DB, courseID, moduleID int, req ModuleRequest) (*CourseModule, error) {
+	result, err := db.Exec(`
+		UPDATE course_modules SET
+			title = COALESCE($1, title),
+			description = COALESCE($2, description),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND course_id = $4
+	`, req.Title, req.Description, moduleID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return GetModule(db, courseID, moduleID)
+}
+
+// DeleteModule removes a module and closes the gap in the course's order. Its
+// lessons and quizzes stay in the course without a module.
+func DeleteModule(db *sql.DB, courseID, moduleID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow(`DELETE FROM course_modules WHERE id = $1 AND course_id = $2 RETURNING position`, moduleID, courseID).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE course_modules SET position = position - 1 WHERE course_id = $1 AND position > $2`, courseID, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReorderModules sets the order of a course's modules
+func ReorderModules(db *sql.DB, courseID int, moduleIDs []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCourse(tx, courseID); err != nil {
+		return err
+	}
+
+	existing, err := courseRowIDs(tx, "course_modules", courseID)
+	if err != nil {
+		return err
+	}
+
+	if !isPermutation(moduleIDs, existing) {
+		return ErrModuleOrderMismatch
+	}
+
+	for i, id := range moduleIDs {
+		_, err := tx.Exec(`UPDATE course_modules SET position = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, i+1, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ModuleBelongsToCourse reports whether moduleID is a module of courseID
+func ModuleBelongsToCourse(exec sqlExecutor, courseID, moduleID int) (bool, error) {
+	var exists bool
+	err := exec.QueryRow(`SELECT EXISTS(SELECT 1 FROM course_modules WHERE id = $1 AND course_id = $2)`, moduleID, courseID).Scan(&exists)
+	return exists, err
+}
+
+// UpdateModuleProgress recomputes a user's per-module progress in course_progress
+// from completed lessons and passed quizzes
+func UpdateModuleProgress(db *sql.DB, userID, courseID int) error {
+	_, err := db.Exec(`
+		WITH module_stats AS (
+			SELECT m.id,
+			       (SELECT COUNT(*) FROM lessons l WHERE l.module_id = m.id) AS total_lessons,
+			       (SELECT COUNT(*) FROM lessons l
+			        JOIN lesson_progress lp ON lp.lesson_id = l.id AND lp.user_id = $1
+			        WHERE l.module_id = m.id AND lp.completed = TRUE) AS lessons_completed,
+			       (SELECT COUNT(*) FROM quizzes q WHERE q.module_id = m.id AND q.is_active = TRUE) AS total_quizzes,
+			       (SELECT COUNT(*) FROM quizzes q
+			        WHERE q.module_id = m.id AND q.is_active = TRUE
+			          AND EXISTS (SELECT 1 FROM quiz_attempts qa
+			                      WHERE qa.quiz_id = q.id AND qa.user_id = $1 AND qa.passed = TRUE)) AS quizzes_passed
+			FROM course_modules m
+			WHERE m.course_id = $2
+		)
+		INSERT INTO course_progress (user_id, course_id, module_progress, updated_at)
+		SELECT $1, $2, COALESCE(jsonb_object_agg(id::text, jsonb_build_object(
+			'lessonsCompleted', lessons_completed,
+			'totalLessons', total_lessons,
+			'quizzesPassed', quizzes_passed,
+			'totalQuizzes', total_quizzes,
+			'progress', CASE WHEN total_lessons + total_quizzes > 0
+			                 THEN (lessons_completed + quizzes_passed) * 100 / (total_lessons + total_quizzes)
+			                 ELSE 0 END
+		)), '{}'::jsonb), CURRENT_TIMESTAMP
+		FROM module_stats
+		ON CONFLICT (user_id, course_id)
+		DO UPDATE SET module_progress = EXCLUDED.module_progress, updated_at = CURRENT_TIMESTAMP
+	`, userID, courseID)
+	return err
+}
+
+// UpdateModuleProgressForAttempt recomputes module progress after a quiz attempt
+// on a quiz that belongs to a module
+func UpdateModuleProgressForAttempt(db *sql.DB, attemptID int) error {
+	var userID, courseID int
+	err := db.QueryRow(`
+		SELECT qa.user_id, q.course_id
+		FROM quiz_attempts qa
+		JOIN quizzes q ON q.id = qa.quiz_id
+		WHERE qa.id = $1 AND q.module_id IS NOT NULL
+	`, attemptID).Scan(&userID, &courseID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return UpdateModuleProgress(db, userID, courseID)
+}
+
+// isPermutation reports whether ids lists every key of existing exactly once
+func isPermutation(ids []int, existing map[int]bool) bool {
+	if len(ids) != len(existing) {
+		return false
+	}
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if !existing[id] || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
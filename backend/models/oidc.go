@@ -0,0 +1,155 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrOIDCStateInvalid is returned when an SSO callback carries an unknown, used or expired state
+var ErrOIDCStateInvalid = errors.New("invalid or expired login state")
+
+// OIDCUserParams describes a user provisioned on first single sign-on
+type OIDCUserParams struct {
+	Username string
+	Email    string
+	FullName string
+	Role     string
+	Issuer   string
+	Subject  string
+}
+
+// CreateOIDCLoginState stores the nonce and PKCE verifier of a login in progress under its state
+func CreateOIDCLoginState(db *sql.DB, state, nonce, codeVerifier string, ttl time.Duration) error {
+	// Opportunistically drop abandoned logins
+	if _, err := db.Exec(`DELETE FROM oidc_login_states WHERE expires_at < $1`, time.Now()); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, hashToken(state), nonce, codeVerifier, time.Now().Add(ttl))
+	return err
+}
+
+// ConsumeOIDCLoginState deletes a login state and returns its nonce and PKCE verifier.
+// Each state can be used once.
+func ConsumeOIDCLoginState(db *sql.DB, state string) (string, string, error) {
+	var nonce, codeVerifier string
+	err := db.QueryRow(`
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND expires_at > $2
+		RETURNING nonce, code_verifier
+	`, hashToken(state), time.Now()).Scan(&nonce, &codeVerifier)
+	if err == sql.ErrNoRows {
+		return "", "", ErrOIDCStateInvalid
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return nonce, codeVerifier, nil
+}
+
+// GetUserIDByOIDCIdentity returns the user linked to an identity provider subject
+func GetUserIDByOIDCIdentity(db *sql.DB, issuer, subject string) (int, error) {
+	var userID int
+	err := db.QueryRow(`SELECT id FROM users WHERE oidc_issuer = $1 AND oidc_subject = $2`, issuer, subject).Scan(&userID)
+	return userID, err
+}
+
+// LinkOIDCIdentity links an existing user to an identity provider subject
+func LinkOIDCIdentity(db *sql.DB, userID int, issuer, subject string) error {
+	_, err := db.Exec(`
+		UPDATE users
+		SET oidc_issuer = $1, oidc_subject = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`, issuer, subject, userID)
+	return err
+}
+
+// CreateOIDCUser provisions a user for a first single sign-on. The account gets an
+// unusable random password, so it can only sign in through the identity provider
+// until a password reset.
+func CreateOIDCUser(db *sql.DB, params OIDCUserParams) (*User, error) {
+	secret, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := availableUsername(db, params.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID int
+	err = db.QueryRow(`
+		INSERT INTO users (username, email, password_hash, full_name, role, email_verified_at, oidc_issuer, oidc_subject)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, $6, $7)
+		RETURNING id
+	`, username, params.Email, string(passwordHash), params.FullName, params.Role, params.Issuer, params.Subject).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetUserByID(db, userID)
+}
+
+// UpdateUserRole changes a user's role
+func UpdateUserRole(db *sql.DB, userID int, role string) error {
+	_, err := db.Exec(`UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, role, userID)
+	return err
+}
+
+// availableUsername returns base, or base with a numeric suffix, that no user has taken yet
+func availableUsername(db *sql.DB, base string) (string, error) {
+	base = sanitizeUsername(base)
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			suffix := fmt.Sprintf("%d", i)
+			if len(candidate)+len(suffix) > 50 {
+				candidate = candidate[:50-len(suffix)]
+			}
+			candidate += suffix
+		}
+
+		var exists bool
+		err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`, candidate).Scan(&exists)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no available username for %q", base)
+}
+
+// sanitizeUsername keeps letters, digits, dots, dashes and underscores
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+
+	username := b.String()
+	if len(username) > 50 {
+		username = username[:50]
+	}
+	if len(username) < 3 {
+		username = "user" + username
+	}
+	return username
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -32,57 +33,12 @@ type CourseProgress struct {
 	QuizzesCompleted int      `json:"quizzesCompleted"`
 	TotalQuizzes    int       `json:"totalQuizzes"`
 	TimeSpent       int       `json:"timeSpent"` // total time in seconds
+	ModuleProgress  json.RawMessage `json:"moduleProgress"` // per-module progress keyed by module ID
 	StartedAt       time.Time `json:"startedAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
 	CompletedAt     *time.Time `json:"completedAt,omitempty"`
 }
 
-// CreateLessonProgressTable creates the lesson_progress table
-func CreateLessonProgressTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS lesson_progress (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-		course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
-		lesson_id INTEGER NOT NULL,
-		completed BOOLEAN DEFAULT FALSE,
-		progress INTEGER DEFAULT 0 CHECK (progress >= 0 AND progress <= 100),
-		time_spent INTEGER DEFAULT 0,
-		started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		completed_at TIMESTAMP,
-		UNIQUE(user_id, course_id, lesson_id)
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
-// CreateCourseProgressTable creates the course_progress table
-func CreateCourseProgressTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS course_progress (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-		course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
-		current_step VARCHAR(50) DEFAULT 'intro',
-		completed_steps TEXT DEFAULT '[]',
-		overall_progress INTEGER DEFAULT 0 CHECK (overall_progress >= 0 AND overall_progress <= 100),
-		lessons_completed INTEGER DEFAULT 0,
-		total_lessons INTEGER DEFAULT 0,
-		quizzes_completed INTEGER DEFAULT 0,
-		total_quizzes INTEGER DEFAULT 0,
-		time_spent INTEGER DEFAULT 0,
-		started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		completed_at TIMESTAMP,
-		UNIQUE(user_id, course_id)
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
 // UpdateLessonProgress updates or creates lesson progress
 func UpdateLessonProgress(db *sql.DB, userID, courseID, lessonID int, progress int, timeSpent int, completed bool) error {
 	var completedAt *time.Time
@@ -133,7 +89,7 @@ func GetLessonProgress(db *sql.DB, userID, courseID, lessonID int) (*LessonProgr
 func GetCourseProgress(db *sql.DB, userID, courseID int) (*CourseProgress, error) {
 	query := `
 	SELECT id, user_id, course_id, current_step, completed_steps, overall_progress, lessons_completed, total_lessons, 
-	       quizzes_completed, total_quizzes, time_spent, started_at, updated_at, completed_at
+	       quizzes_completed, total_quizzes, time_spent, module_progress, started_at, updated_at, completed_at
 	FROM course_progress
 	WHERE user_id = $1 AND course_id = $2
 	`
@@ -141,7 +97,8 @@ func GetCourseProgress(db *sql.DB, userID, courseID int) (*CourseProgress, error
 
 	var cp CourseProgress
 	var completedAt sql.NullTime
-	err := row.Scan(&cp.ID, &cp.UserID, &cp.CourseID, &cp.CurrentStep, &cp.CompletedSteps, &cp.OverallProgress, &cp.LessonsCompleted, &cp.TotalLessons, &cp.QuizzesCompleted, &cp.TotalQuizzes, &cp.TimeSpent, &cp.StartedAt, &cp.UpdatedAt, &completedAt)
+	var moduleProgress []byte
+	err := row.Scan(&cp.ID, &cp.UserID, &cp.CourseID, &cp.CurrentStep, &cp.CompletedSteps, &cp.OverallProgress, &cp.LessonsCompleted, &cp.TotalLessons, &cp.QuizzesCompleted, &cp.TotalQuizzes, &cp.TimeSpent, &moduleProgress, &cp.StartedAt, &cp.UpdatedAt, &completedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -149,6 +106,7 @@ func GetCourseProgress(db *sql.DB, userID, courseID int) (*CourseProgress, error
 	if completedAt.Valid {
 		cp.CompletedAt = &completedAt.Time
 	}
+	cp.ModuleProgress = json.RawMessage(moduleProgress)
 
 	return &cp, nil
 }
@@ -266,7 +224,7 @@ func UpdateCurrentStepAndCompletedSteps(db *sql.DB, userID, courseID int, curren
 func GetUserCourseProgressList(db *sql.DB, userID int) ([]CourseProgress, error) {
 	query := `
 	SELECT cp.id, cp.user_id, cp.course_id, cp.current_step, cp.completed_steps, cp.overall_progress, cp.lessons_completed, 
-	       cp.total_lessons, cp.quizzes_completed, cp.total_quizzes, cp.time_spent, 
+	       cp.total_lessons, cp.quizzes_completed, cp.total_quizzes, cp.time_spent, cp.module_progress,
 	       cp.started_at, cp.updated_at, cp.completed_at
 	FROM course_progress cp
 	WHERE cp.user_id = $1
@@ -282,7 +240,8 @@ func GetUserCourseProgressList(db *sql.DB, userID int) ([]CourseProgress, error)
 	for rows.Next() {
 		var cp CourseProgress
 		var completedAt sql.NullTime
-		err := rows.Scan(&cp.ID, &cp.UserID, &cp.CourseID, &cp.CurrentStep, &cp.CompletedSteps, &cp.OverallProgress, &cp.LessonsCompleted, &cp.TotalLessons, &cp.QuizzesCompleted, &cp.TotalQuizzes, &cp.TimeSpent, &cp.StartedAt, &cp.UpdatedAt, &completedAt)
+		var moduleProgress []byte
+		err := rows.Scan(&cp.ID, &cp.UserID, &cp.CourseID, &cp.CurrentStep, &cp.CompletedSteps, &cp.OverallProgress, &cp.LessonsCompleted, &cp.TotalLessons, &cp.QuizzesCompleted, &cp.TotalQuizzes, &cp.TimeSpent, &moduleProgress, &cp.StartedAt, &cp.UpdatedAt, &completedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -290,6 +249,7 @@ func GetUserCourseProgressList(db *sql.DB, userID int) ([]CourseProgress, error)
 		if completedAt.Valid {
 			cp.CompletedAt = &completedAt.Time
 		}
+		cp.ModuleProgress = json.RawMessage(moduleProgress)
 
 		progressList = append(progressList, cp)
 	}
@@ -0,0 +1,347 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Question difficulties of the question bank
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// ErrInvalidDrawRules is returned for draw rules a course's bank cannot satisfy
+var ErrInvalidDrawRules = errors.New("invalid draw rules")
+
+// BankQuestion is a reusable question of a course's question bank. The ID of
+// its Question is always the bank question's ID, so answers to it are keyed
+// the same in every quiz it is drawn into.
+type BankQuestion struct {
+	ID         int          `json:"id"`
+	CourseID   int          `json:"courseId"`
+	Topic      string       `json:"topic"`
+	Difficulty string       `json:"difficulty"`
+	Question   QuizQuestion `json:"question"`
+	CreatedBy  *int         `json:"createdBy,omitempty"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	UpdatedAt  time.Time    `json:"updatedAt"`
+}
+
+// QuizDrawRule draws Count random questions of a topic and difficulty from
+// the bank of the quiz's course; an empty topic or difficulty matches any
+type QuizDrawRule struct {
+	Topic      string `json:"topic,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Count      int    `json:"count"`
+}
+
+// BankQuestionListSpec are the sort and filter fields of the question bank list
+var BankQuestionListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"id":         {Column: "id", Type: ListNumber},
+		"topic":      {Column: "topic", Type: ListText},
+		"difficulty": {Column: "difficulty", Type: ListText},
+		"type":       {Column: "COALESCE(NULLIF(question->>'type', ''), 'single_choice')", Type: ListText},
+		"createdBy":  {Column: "created_by", Type: ListNumber},
+		"createdAt":  {Column: "created_at", Type: ListTime},
+		"updatedAt":  {Column: "updated_at", Type: ListTime},
+	},
+	SearchColumns: []string{"question->>'question'", "topic"},
+	DefaultSort:   "topic,difficulty",
+	IDColumn:      "id",
+}
+
+const bankQuestionColumns = `id, course_id, topic, difficulty, question, created_by, created_at, updated_at`
+
+func scanBankQuestion(row interface{ Scan(...interface{}) error }) (*BankQuestion, error) {
+	var question BankQuestion
+	var content []byte
+	var createdBy sql.NullInt64
+	err := row.Scan(&question.ID, &question.CourseID, &question.Topic, &question.Difficulty, &content,
+		&createdBy, &question.CreatedAt, &question.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &question.Question); err != nil {
+		return nil, fmt.Errorf("failed to parse question %d: %v", question.ID, err)
+	}
+	question.Question.ID = question.ID
+	question.CreatedBy = nullIntPtr(createdBy)
+	return &question, nil
+}
+
+// validDifficulty reports whether difficulty is one of the bank's difficulties
+func validDifficulty(difficulty string) bool {
+	return difficulty == DifficultyEasy || difficulty == DifficultyMedium || difficulty == DifficultyHard
+}
+
+// validateBankQuestion normalizes and checks a question before it is stored
+func validateBankQuestion(question *BankQuestion) error {
+	question.Topic = strings.TrimSpace(question.Topic)
+	if question.Difficulty == "" {
+		question.Difficulty = DifficultyMedium
+	}
+	if !validDifficulty(question.Difficulty) {
+		return fmt.Errorf("%w: difficulty must be easy, medium or hard", ErrInvalidQuestion)
+	}
+	if len(question.Topic) > 100 {
+		return fmt.Errorf("%w: topic is longer than 100 characters", ErrInvalidQuestion)
+	}
+	return ValidateQuizQuestion(question.Question)
+}
+
+// bankQuestionJSON returns the stored form of a question, without its ID
+func bankQuestionJSON(question QuizQuestion) (string, error) {
+	question.ID = 0
+	content, err := json.Marshal(question)
+	return string(content), err
+}
+
+// GetBankQuestions returns a page of the question bank of a course
+func GetBankQuestions(db *sql.DB, courseID int, list *ListQuery) ([]BankQuestion, *ListPage, error) {
+	rows, page, err := list.Query(db, bankQuestionColumns, "questions", []string{"course_id = $1"}, courseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	questions := []BankQuestion{}
+	for rows.Next() {
+		question, err := scanBankQuestion(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		questions = append(questions, *question)
+	}
+	return questions, page, rows.Err()
+}
+
+// GetBankQuestion returns a question of a course's bank, or sql.ErrNoRows
+func GetBankQuestion(db *sql.DB, courseID, questionID int) (*BankQuestion, error) {
+	return scanBankQuestion(db.QueryRow(`SELECT `+bankQuestionColumns+` FROM questions WHERE id = $1 AND course_id = $2`,
+		questionID, courseID))
+}
+
+// CreateBankQuestion validates and stores a new bank question
+func CreateBankQuestion(db *sql.DB, question *BankQuestion) error {
+	if err := validateBankQuestion(question); err != nil {
+		return err
+	}
+	content, err := bankQuestionJSON(question.Question)
+	if err != nil {
+		return err
+	}
+	err = db.QueryRow(`
+		INSERT INTO questions (course_id, topic, difficulty, question, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, question.CourseID, question.Topic, question.Difficulty, content, question.CreatedBy).Scan(
+		&question.ID, &question.CreatedAt, &question.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	question.Question.ID = question.ID
+	return nil
+}
+
+// UpdateBankQuestion validates and replaces the topic, difficulty and content
+// of a question of the course's bank, returning sql.ErrNoRows when the course
+// has no such question and ErrInvalidDrawRules when moving it to another topic
+// or difficulty leaves a quiz's draw rules unfilled. Attempts already started
+// keep the version they drew.
+func UpdateBankQuestion(db *sql.DB, question *BankQuestion) error {
+	if err := validateBankQuestion(question); err != nil {
+		return err
+	}
+	content, err := bankQuestionJSON(question.Question)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCourse(tx, question.CourseID); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`
+		UPDATE questions
+		SET topic = $1, difficulty = $2, question = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND course_id = $5
+		RETURNING created_by, created_at, updated_at
+	`, question.Topic, question.Difficulty, content, question.ID, question.CourseID).Scan(
+		&question.CreatedBy, &question.CreatedAt, &question.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := checkCourseDrawRules(tx, question.CourseID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	question.Question.ID = question.ID
+	return nil
+}
+
+// DeleteBankQuestion removes a question from a course's bank, returning
+// sql.ErrNoRows when the course has no such question and ErrInvalidDrawRules
+// when a quiz's draw rules need the question
+func DeleteBankQuestion(db *sql.DB, courseID, questionID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCourse(tx, courseID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM questions WHERE id = $1 AND course_id = $2`, questionID, courseID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	if err := checkCourseDrawRules(tx, courseID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ValidateDrawRules checks that every rule draws at least one question of a
+// known difficulty and that the course's bank holds enough questions for the
+// rules together
+func ValidateDrawRules(db sqlExecutor, courseID int, rules []QuizDrawRule) error {
+	if err := checkDrawRules(rules); err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	categories, err := courseBankCategories(db, courseID)
+	if err != nil {
+		return err
+	}
+	return checkBankFillsRules(rules, categories)
+}
+
+// checkDrawRules checks that every rule draws at least one question of a
+// known difficulty
+func checkDrawRules(rules []QuizDrawRule) error {
+	for i, rule := range rules {
+		if rule.Count < 1 {
+			return fmt.Errorf("%w: rule %d: count must be at least 1", ErrInvalidDrawRules, i+1)
+		}
+		if rule.Difficulty != "" && !validDifficulty(rule.Difficulty) {
+			return fmt.Errorf("%w: rule %d: difficulty must be easy, medium or hard", ErrInvalidDrawRules, i+1)
+		}
+	}
+	return nil
+}
+
+// drawRulesParam returns the stored form of draw rules, NULL for none
+func drawRulesParam(rules []QuizDrawRule) (interface{}, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	content, err := json.Marshal(rules)
+	return string(content), err
+}
+
+// parseDrawRules reads the stored draw rules of a quiz
+func parseDrawRules(content []byte) []QuizDrawRule {
+	var rules []QuizDrawRule
+	if len(content) > 0 {
+		json.Unmarshal(content, &rules)
+	}
+	return rules
+}
+
+// SetQuizAssembly sets how attempts at a quiz get their questions: drawn from
+// the bank by rules, and with shuffled options. Nil arguments keep the current
+// setting; an empty rules slice makes the quiz use its own questions again.
+func SetQuizAssembly(db sqlExecutor, quizID int, rules *[]QuizDrawRule, shuffleOptions *bool) error {
+	if rules != nil {
+		var courseID int
+		if err := db.QueryRow(`SELECT course_id FROM quizzes WHERE id = $1`, quizID).Scan(&courseID); err != nil {
+			return err
+		}
+		if err := ValidateDrawRules(db, courseID, *rules); err != nil {
+			return err
+		}
+		value, err := drawRulesParam(*rules)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(`UPDATE quizzes SET draw_rules = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, value, quizID); err != nil {
+			return err
+		}
+	}
+	if shuffleOptions != nil {
+		if _, err := db.Exec(`UPDATE quizzes SET shuffle_options = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, *shuffleOptions, quizID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AssembleAttemptQuestions returns the questions of a new attempt at a quiz,
+// which the attempt keeps so later edits to the quiz or bank do not change
+// what it is graded against. A quiz with draw rules gets its questions drawn
+// from the bank in random order, other quizzes their own questions; with
+// shuffle_options the options of choice questions are shuffled too. The items
+// of ordering questions are always shuffled.
+func AssembleAttemptQuestions(db sqlExecutor, quizID int) ([]QuizQuestion, error) {
+	var courseID int
+	var questions, rulesContent []byte
+	var shuffleOptions bool
+	err := db.QueryRow(`SELECT course_id, questions, draw_rules, shuffle_options FROM quizzes WHERE id = $1`, quizID).Scan(
+		&courseID, &questions, &rulesContent, &shuffleOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := parseDrawRules(rulesContent)
+	var set []QuizQuestion
+	if len(rules) > 0 {
+		if set, err = drawQuestions(db, courseID, rules); err != nil {
+			return nil, err
+		}
+		rand.Shuffle(len(set), func(i, j int) { set[i], set[j] = set[j], set[i] })
+	} else if set, err = ParseQuizQuestions(questions); err != nil {
+		return nil, err
+	}
+
+	for i, question := range set {
+		if shuffler, ok := question.scorer().(OptionShuffler); ok && shuffleOptions {
+			set[i] = shuffler.ShuffleOptions(set[i])
+		}
+		if preparer, ok := question.scorer().(AttemptPreparer); ok {
+			set[i] = preparer.PrepareAttempt(set[i])
+		}
+	}
+	return set, nil
+}
+
+// AttemptQuestionsParam returns the stored form of an attempt's question set.
+// Attempts started before question sets were always stored have none and use
+// the quiz's questions.
+func AttemptQuestionsParam(set []QuizQuestion) (interface{}, error) {
+	if set == nil {
+		set = []QuizQuestion{}
+	}
+	content, err := json.Marshal(set)
+	return string(content), err
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/lib/pq"
+)
+
+// bankCategory counts the questions of a bank with one topic and difficulty
+type bankCategory struct {
+	Topic      string
+	Difficulty string
+	Count      int
+}
+
+// matches reports whether a rule draws from the questions of a category
+func (rule QuizDrawRule) matches(category bankCategory) bool {
+	return (rule.Topic == "" || rule.Topic == category.Topic) &&
+		(rule.Difficulty == "" || rule.Difficulty == category.Difficulty)
+}
+
+// courseBankCategories counts the questions of a course's bank per topic and difficulty
+func courseBankCategories(db sqlExecutor, courseID int) ([]bankCategory, error) {
+	rows, err := db.Query(`
+		SELECT topic, difficulty, COUNT(*) FROM questions
+		WHERE course_id = $1
+		GROUP BY topic, difficulty
+		ORDER BY topic, difficulty
+	`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []bankCategory
+	for rows.Next() {
+		var category bankCategory
+		if err := rows.Scan(&category.Topic, &category.Difficulty, &category.Count); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// packageBankCategories counts the questions of a package's bank per topic and difficulty
+func packageBankCategories(bank []PackagedQuestion) []bankCategory {
+	index := make(map[[2]string]int)
+	var categories []bankCategory
+	for _, question := range bank {
+		key := [2]string{question.Topic, question.Difficulty}
+		i, ok := index[key]
+		if !ok {
+			i = len(categories)
+			index[key] = i
+			categories = append(categories, bankCategory{Topic: question.Topic, Difficulty: question.Difficulty})
+		}
+		categories[i].Count++
+	}
+	return categories
+}
+
+// fillableDraws returns how many of the questions the rules draw a bank can
+// fill without drawing a question twice. Rules whose topics and difficulties
+// overlap compete for the same questions, so the rules are matched against
+// the bank together rather than one by one.
+func fillableDraws(rules []QuizDrawRule, categories []bankCategory) int {
+	// Maximum flow from the rules, each needing its count, through the
+	// categories they match, each holding its count of questions
+	source, sink := 0, len(rules)+len(categories)+1
+	capacity := make([][]int, sink+1)
+	for i := range capacity {
+		capacity[i] = make([]int, sink+1)
+	}
+	for r, rule := range rules {
+		capacity[source][1+r] = rule.Count
+		for c, category := range categories {
+			if rule.matches(category) {
+				capacity[1+r][1+len(rules)+c] = category.Count
+			}
+		}
+	}
+	for c, category := range categories {
+		capacity[1+len(rules)+c][sink] = category.Count
+	}
+
+	flow := make([][]int, sink+1)
+	for i := range flow {
+		flow[i] = make([]int, sink+1)
+	}
+	total := 0
+	for {
+		// Shortest augmenting path over the residual capacities
+		parent := make([]int, sink+1)
+		for i := range parent {
+			parent[i] = -1
+		}
+		parent[source] = source
+		queue := []int{source}
+		for len(queue) > 0 && parent[sink] == -1 {
+			node := queue[0]
+			queue = queue[1:]
+			for next := range capacity {
+				if parent[next] == -1 && capacity[node][next]-flow[node][next] > 0 {
+					parent[next] = node
+					queue = append(queue, next)
+				}
+			}
+		}
+		if parent[sink] == -1 {
+			break
+		}
+
+		amount := -1
+		for node := sink; node != source; node = parent[node] {
+			residual := capacity[parent[node]][node] - flow[parent[node]][node]
+			if amount == -1 || residual < amount {
+				amount = residual
+			}
+		}
+		for node := sink; node != source; node = parent[node] {
+			flow[parent[node]][node] += amount
+			flow[node][parent[node]] -= amount
+		}
+		total += amount
+	}
+	return total
+}
+
+// checkBankFillsRules checks that a bank holds enough questions to fill all
+// rules together, without drawing a question twice
+func checkBankFillsRules(rules []QuizDrawRule, categories []bankCategory) error {
+	needed := 0
+	for _, rule := range rules {
+		needed += rule.Count
+	}
+	if filled := fillableDraws(rules, categories); filled < needed {
+		return fmt.Errorf("%w: the rules draw %d questions but the bank can fill only %d of them",
+			ErrInvalidDrawRules, needed, filled)
+	}
+	return nil
+}
+
+// checkCourseDrawRules checks that the bank of a course still fills the draw
+// rules of each of its active quizzes
+func checkCourseDrawRules(db sqlExecutor, courseID int) error {
+	rows, err := db.Query(`
+		SELECT title, draw_rules FROM quizzes
+		WHERE course_id = $1 AND is_active = TRUE AND draw_rules IS NOT NULL
+		ORDER BY id
+	`, courseID)
+	if err != nil {
+		return err
+	}
+	type quizRules struct {
+		title string
+		rules []QuizDrawRule
+	}
+	var quizzes []quizRules
+	for rows.Next() {
+		var quiz quizRules
+		var content []byte
+		if err := rows.Scan(&quiz.title, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		quiz.rules = parseDrawRules(content)
+		quizzes = append(quizzes, quiz)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(quizzes) == 0 {
+		return nil
+	}
+
+	categories, err := courseBankCategories(db, courseID)
+	if err != nil {
+		return err
+	}
+	for _, quiz := range quizzes {
+		if err := checkBankFillsRules(quiz.rules, categories); err != nil {
+			return fmt.Errorf("quiz %q: %w", quiz.title, err)
+		}
+	}
+	return nil
+}
+
+// drawQuestions draws the questions of the rules at random, never drawing a
+// question twice. It fails with ErrInvalidDrawRules when the bank can no
+// longer fill every rule.
+func drawQuestions(db sqlExecutor, courseID int, rules []QuizDrawRule) ([]QuizQuestion, error) {
+	categories, err := courseBankCategories(db, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBankFillsRules(rules, categories); err != nil {
+		return nil, err
+	}
+
+	// Pick the category of each drawn question at random, weighted by the
+	// questions left in it, skipping picks that would leave a later draw
+	// unfilled; the questions are then drawn from the picked categories
+	left := append([]bankCategory(nil), categories...)
+	picks := make([][]int, len(rules))
+	for r, rule := range rules {
+		picks[r] = make([]int, len(categories))
+		for n := 1; n <= rule.Count; n++ {
+			remaining := append([]QuizDrawRule{{Topic: rule.Topic, Difficulty: rule.Difficulty, Count: rule.Count - n}}, rules[r+1:]...)
+			c, err := pickCategory(rule, left, remaining)
+			if err != nil {
+				return nil, err
+			}
+			left[c].Count--
+			picks[r][c]++
+		}
+	}
+
+	var set []QuizQuestion
+	drawn := []int64{}
+	for r := range rules {
+		for c, category := range categories {
+			count := picks[r][c]
+			if count == 0 {
+				continue
+			}
+			questions, err := drawCategory(db, courseID, category, count, drawn)
+			if err != nil {
+				return nil, err
+			}
+			for _, question := range questions {
+				set = append(set, question.Question)
+				drawn = append(drawn, int64(question.ID))
+			}
+		}
+	}
+	return set, nil
+}
+
+// pickCategory picks a category matching rule at random, weighted by the
+// questions left in it, such that the remaining draws can still be filled
+func pickCategory(rule QuizDrawRule, left []bankCategory, remaining []QuizDrawRule) (int, error) {
+	needed := 0
+	for _, draw := range remaining {
+		needed += draw.Count
+	}
+	excluded := make(map[int]bool)
+	for {
+		total := 0
+		for c, category := range left {
+			if !excluded[c] && rule.matches(category) {
+				total += category.Count
+			}
+		}
+		if total == 0 {
+			return 0, fmt.Errorf("%w: the bank can no longer fill the rules", ErrInvalidDrawRules)
+		}
+
+		pick := rand.Intn(total)
+		for c, category := range left {
+			if excluded[c] || !rule.matches(category) {
+				continue
+			}
+			if pick >= category.Count {
+				pick -= category.Count
+				continue
+			}
+			left[c].Count--
+			ok := fillableDraws(remaining, left) >= needed
+			left[c].Count++
+			if ok {
+				return c, nil
+			}
+			excluded[c] = true
+			break
+		}
+	}
+}
+
+// drawCategory draws count random questions of a category that are not in drawn
+func drawCategory(db sqlExecutor, courseID int, category bankCategory, count int, drawn []int64) ([]*BankQuestion, error) {
+	rows, err := db.Query(`
+		SELECT `+bankQuestionColumns+` FROM questions
+		WHERE course_id = $1 AND topic = $2 AND difficulty = $3 AND NOT (id = ANY($4))
+		ORDER BY random()
+		LIMIT $5
+	`, courseID, category.Topic, category.Difficulty, pq.Array(drawn), count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []*BankQuestion
+	for rows.Next() {
+		question, err := scanBankQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
@@ -11,6 +11,7 @@ type Quiz struct {
 	ID          int             `json:"id"`
 	CourseID    int             `json:"courseId"`
 	LessonID    *int            `json:"lessonId,omitempty"`
+	ModuleID    *int            `json:"moduleId,omitempty"`
 	Title       string          `json:"title"`
 	Description string          `json:"description"`
 	Questions   json.RawMessage `json:"questions"`
@@ -18,6 +19,10 @@ type Quiz struct {
 	MaxAttempts int             `json:"maxAttempts"`
 	PassingScore int            `json:"passingScore"` // percentage
 	QuizType    string          `json:"quizType"` // pretest, posttest, lesson
+	// DrawRules assemble each attempt from the course's question bank instead of Questions
+	DrawRules      []QuizDrawRule `json:"drawRules,omitempty"`
+	ShuffleOptions bool           `json:"shuffleOptions"`
+	Scoring        QuizScoring    `json:"scoring"`
 	IsActive    bool            `json:"isActive"`
 	CreatedAt   time.Time       `json:"createdAt"`
 	UpdatedAt   time.Time       `json:"updatedAt"`
@@ -37,6 +42,19 @@ type QuizAttempt struct {
 	StartedAt   time.Time       `json:"startedAt"`
 	SubmittedAt *time.Time      `json:"submittedAt,omitempty"`
 	CreatedAt   time.Time       `json:"createdAt"`
+	// Questions are the questions drawn for the attempt, as shown to the
+	// learner; empty when the attempt shows the quiz's own questions
+	Questions []map[string]interface{} `json:"questions,omitempty"`
+	// ExpiresAt is the deadline of an attempt at a timed quiz and
+	// RemainingSeconds the time left on it while it is open
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	RemainingSeconds *int       `json:"remainingSeconds,omitempty"`
+	// AutoSubmitted attempts were closed by the server at their deadline
+	AutoSubmitted bool `json:"autoSubmitted"`
+	// Points earned out of MaxPoints; unset on attempts graded before
+	// questions were weighted
+	Points    *float64 `json:"points,omitempty"`
+	MaxPoints *float64 `json:"maxPoints,omitempty"`
 }
 
 // QuizSubmission represents a quiz submission request
@@ -52,74 +70,36 @@ type QuizResult struct {
 	Score         int     `json:"score"`
 	Passed        bool    `json:"passed"`
 	TimeSpent     int     `json:"timeSpent"`
+	Points        float64 `json:"points"`
+	MaxPoints     float64 `json:"maxPoints"`
 	CorrectAnswers int    `json:"correctAnswers"`
 	TotalQuestions int    `json:"totalQuestions"`
 	AttemptNumber int     `json:"attemptNumber"`
 	CanRetake     bool    `json:"canRetake"`
 }
 
-// CreateQuizTable creates the quizzes table
-func CreateQuizTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS quizzes (
-		id SERIAL PRIMARY KEY,
-		course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
-		title VARCHAR(255) NOT NULL,
-		description TEXT,
-		questions JSONB NOT NULL,
-		time_limit INTEGER DEFAULT 30,
-		max_attempts INTEGER DEFAULT 3,
-		passing_score INTEGER DEFAULT 70,
-		quiz_type VARCHAR(50) DEFAULT 'lesson',
-		is_active BOOLEAN DEFAULT TRUE,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
-// CreateQuizAttemptTable creates the quiz_attempts table
-func CreateQuizAttemptTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS quiz_attempts (
-		id SERIAL PRIMARY KEY,
-		quiz_id INTEGER REFERENCES quizzes(id) ON DELETE CASCADE,
-		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-		answers JSONB,
-		score INTEGER DEFAULT 0,
-		time_spent INTEGER DEFAULT 0,
-		completed BOOLEAN DEFAULT FALSE,
-		passed BOOLEAN DEFAULT FALSE,
-		attempt_number INTEGER DEFAULT 1,
-		started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		submitted_at TIMESTAMP,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
 // GetQuizByID gets a quiz by ID
 func GetQuizByID(db *sql.DB, quizID int) (*Quiz, error) {
 	query := `
-	SELECT id, course_id, title, description, questions, time_limit, 
-	       max_attempts, passing_score, quiz_type, is_active, created_at, updated_at
+	SELECT id, course_id, module_id, title, description, questions, time_limit, 
+	       max_attempts, passing_score, quiz_type, draw_rules, shuffle_options, negative_marking, score_rounding, is_active, created_at, updated_at
 	FROM quizzes
 	WHERE id = $1 AND is_active = TRUE
 	`
 	row := db.QueryRow(query, quizID)
 
 	var quiz Quiz
-	err := row.Scan(&quiz.ID, &quiz.CourseID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
+	var moduleID sql.NullInt64
+	var drawRules []byte
+	err := row.Scan(&quiz.ID, &quiz.CourseID, &moduleID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &drawRules, &quiz.ShuffleOptions, &quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	quiz.DrawRules = parseDrawRules(drawRules)
 
 	// LessonID is not used in current database schema
 	quiz.LessonID = nil
+	quiz.ModuleID = nullIntPtr(moduleID)
 
 	return &quiz, nil
 }
@@ -127,8 +107,8 @@ func GetQuizByID(db *sql.DB, quizID int) (*Quiz, error) {
 // GetQuizzesByCourse gets all quizzes for a course
 func GetQuizzesByCourse(db *sql.DB, courseID int) ([]Quiz, error) {
 	query := `
-	SELECT id, course_id, title, description, questions, time_limit, 
-	       max_attempts, passing_score, quiz_type, is_active, created_at, updated_at
+	SELECT id, course_id, module_id, title, description, questions, time_limit, 
+	       max_attempts, passing_score, quiz_type, draw_rules, shuffle_options, negative_marking, score_rounding, is_active, created_at, updated_at
 	FROM quizzes
 	WHERE course_id = $1 AND is_active = TRUE
 	ORDER BY created_at ASC
@@ -142,13 +122,17 @@ func GetQuizzesByCourse(db *sql.DB, courseID int) ([]Quiz, error) {
 	var quizzes []Quiz
 	for rows.Next() {
 		var quiz Quiz
-		err := rows.Scan(&quiz.ID, &quiz.CourseID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
+		var moduleID sql.NullInt64
+		var drawRules []byte
+		err := rows.Scan(&quiz.ID, &quiz.CourseID, &moduleID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &drawRules, &quiz.ShuffleOptions, &quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		quiz.DrawRules = parseDrawRules(drawRules)
 
 		// LessonID is not used in current database schema
 		quiz.LessonID = nil
+		quiz.ModuleID = nullIntPtr(moduleID)
 
 		quizzes = append(quizzes, quiz)
 	}
@@ -160,7 +144,7 @@ func GetQuizzesByCourse(db *sql.DB, courseID int) ([]Quiz, error) {
 func GetQuizByTypeAndCourse(db *sql.DB, courseID int, quizType string) (*Quiz, error) {
 	query := `
 	SELECT id, course_id, title, description, questions, time_limit, 
-	       max_attempts, passing_score, quiz_type, is_active, created_at, updated_at
+	       max_attempts, passing_score, quiz_type, draw_rules, shuffle_options, negative_marking, score_rounding, is_active, created_at, updated_at
 	FROM quizzes
 	WHERE course_id = $1 AND quiz_type = $2 AND is_active = TRUE
 	LIMIT 1
@@ -168,10 +152,12 @@ func GetQuizByTypeAndCourse(db *sql.DB, courseID int, quizType string) (*Quiz, e
 	row := db.QueryRow(query, courseID, quizType)
 
 	var quiz Quiz
-	err := row.Scan(&quiz.ID, &quiz.CourseID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
+	var drawRules []byte
+	err := row.Scan(&quiz.ID, &quiz.CourseID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &drawRules, &quiz.ShuffleOptions, &quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	quiz.DrawRules = parseDrawRules(drawRules)
 
 	// LessonID is not used for course-level quizzes (pretest/posttest)
 	quiz.LessonID = nil
@@ -179,88 +165,54 @@ func GetQuizByTypeAndCourse(db *sql.DB, courseID int, quizType string) (*Quiz, e
 	return &quiz, nil
 }
 
-// StartQuizAttempt creates a new quiz attempt
-func StartQuizAttempt(db *sql.DB, userID, quizID int) (*QuizAttempt, error) {
-	// Check how many attempts user has made
-	var attemptCount int
-	err := db.QueryRow("SELECT COUNT(*) FROM quiz_attempts WHERE user_id = $1 AND quiz_id = $2", userID, quizID).Scan(&attemptCount)
-	if err != nil {
-		return nil, err
-	}
-
-	// Check max attempts
-	var maxAttempts int
-	err = db.QueryRow("SELECT max_attempts FROM quizzes WHERE id = $1", quizID).Scan(&maxAttempts)
-	if err != nil {
-		return nil, err
-	}
-
-	if attemptCount >= maxAttempts {
-		return nil, sql.ErrNoRows // or custom error
-	}
-
-	// Create new attempt
-	query := `
-	INSERT INTO quiz_attempts (quiz_id, user_id, attempt_number, started_at, created_at)
-	VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-	RETURNING id, quiz_id, user_id, answers, score, time_spent, completed, passed, attempt_number, started_at, submitted_at, created_at
-	`
-	row := db.QueryRow(query, quizID, userID, attemptCount+1)
-
-	var attempt QuizAttempt
-	var submittedAt sql.NullTime
-	var answers sql.NullString // Handle NULL answers
-	err = row.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &answers, &attempt.Score, &attempt.TimeSpent, &attempt.Completed, &attempt.Passed, &attempt.AttemptNumber, &attempt.StartedAt, &submittedAt, &attempt.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-
-	// Handle NULL answers
-	if answers.Valid {
-		attempt.Answers = json.RawMessage(answers.String)
-	} else {
-		attempt.Answers = json.RawMessage("{}")
-	}
-
-	if submittedAt.Valid {
-		attempt.SubmittedAt = &submittedAt.Time
-	}
-
-	return &attempt, nil
-}
-
-// SubmitQuizAttempt submits a quiz attempt
-func SubmitQuizAttempt(db *sql.DB, attemptID int, answers json.RawMessage, timeSpent int) (*QuizResult, error) {
+// SubmitQuizAttempt submits a quiz attempt. The time spent is measured by the
+// server; an attempt past its deadline is closed with its saved answers
+// instead and ErrAttemptExpired is returned.
+func SubmitQuizAttempt(db *sql.DB, attemptID int, answers json.RawMessage) (*QuizResult, error) {
 	// Get attempt and quiz info
 	var attempt QuizAttempt
 	var quiz Quiz
+	var timeSpent int
+	var expired bool
 	query := `
 	SELECT qa.id, qa.quiz_id, qa.user_id, qa.attempt_number, qa.started_at,
-	       q.questions, q.passing_score, q.max_attempts
+	       COALESCE(qa.question_set, q.questions), q.passing_score, q.max_attempts,
+	       q.negative_marking, q.score_rounding, ` + attemptTimeSpentSQL + `, ` + attemptExpiredSQL + `
 	FROM quiz_attempts qa
 	JOIN quizzes q ON qa.quiz_id = q.id
 	WHERE qa.id = $1 AND qa.completed = FALSE
 	`
 	row := db.QueryRow(query, attemptID)
-	err := row.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &attempt.AttemptNumber, &attempt.StartedAt, &quiz.Questions, &quiz.PassingScore, &quiz.MaxAttempts)
+	err := row.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &attempt.AttemptNumber, &attempt.StartedAt, &quiz.Questions, &quiz.PassingScore, &quiz.MaxAttempts, &quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &timeSpent, &expired)
 	if err != nil {
 		return nil, err
 	}
+	if expired {
+		if _, err := CloseQuizAttempt(db, attemptID); err != nil {
+			return nil, err
+		}
+		return nil, ErrAttemptExpired
+	}
 
-	// Calculate score (simplified - you'd implement proper scoring logic)
-	score, correctAnswers, totalQuestions := calculateQuizScore(quiz.Questions, answers)
+	// Calculate score from the points of the questions
+	grade := calculateQuizScore(quiz.Questions, answers, quiz.Scoring)
+	score := grade.Score
 	passed := score >= quiz.PassingScore
 
 	// Update attempt
 	updateQuery := `
 	UPDATE quiz_attempts 
-	SET answers = $1, score = $2, time_spent = $3, completed = TRUE, passed = $4, submitted_at = CURRENT_TIMESTAMP
-	WHERE id = $5
+	SET answers = $1, score = $2, time_spent = $3, completed = TRUE, passed = $4, submitted_at = CURRENT_TIMESTAMP,
+	    points = $6, max_points = $7
+	WHERE id = $5 AND completed = FALSE
 	`
-	_, err = db.Exec(updateQuery, answers, score, timeSpent, passed, attemptID)
+	updated, err := db.Exec(updateQuery, answers, score, timeSpent, passed, attemptID, grade.Points, grade.MaxPoints)
 	if err != nil {
 		return nil, err
 	}
+	if affected, err := updated.RowsAffected(); err != nil || affected == 0 {
+		return nil, sql.ErrNoRows
+	}
 
 	// Check if can retake
 	var totalAttempts int
@@ -276,8 +228,10 @@ func SubmitQuizAttempt(db *sql.DB, attemptID int, answers json.RawMessage, timeS
 		Score:          score,
 		Passed:         passed,
 		TimeSpent:      timeSpent,
-		CorrectAnswers: correctAnswers,
-		TotalQuestions: totalQuestions,
+		Points:         grade.Points,
+		MaxPoints:      grade.MaxPoints,
+		CorrectAnswers: grade.CorrectCount,
+		TotalQuestions: grade.TotalCount,
 		AttemptNumber:  attempt.AttemptNumber,
 		CanRetake:      canRetake,
 	}, nil
@@ -287,7 +241,8 @@ func SubmitQuizAttempt(db *sql.DB, attemptID int, answers json.RawMessage, timeS
 func GetQuizAttempts(db *sql.DB, userID, quizID int) ([]QuizAttempt, error) {
 	query := `
 	SELECT id, quiz_id, user_id, answers, score, time_spent, completed, passed, 
-	       attempt_number, started_at, submitted_at, created_at
+	       attempt_number, started_at, submitted_at, created_at,
+	       expires_at, ` + attemptRemainingSQL + `, auto_submitted, points, max_points
 	FROM quiz_attempts
 	WHERE user_id = $1 AND quiz_id = $2
 	ORDER BY attempt_number DESC
@@ -301,12 +256,16 @@ func GetQuizAttempts(db *sql.DB, userID, quizID int) ([]QuizAttempt, error) {
 	var attempts []QuizAttempt
 	for rows.Next() {
 		var attempt QuizAttempt
-		var submittedAt sql.NullTime
+		var submittedAt, expiresAt sql.NullTime
+		var remaining sql.NullInt64
+		var points, maxPoints sql.NullFloat64
 		var answers sql.NullString // Handle NULL answers
-		err := rows.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &answers, &attempt.Score, &attempt.TimeSpent, &attempt.Completed, &attempt.Passed, &attempt.AttemptNumber, &attempt.StartedAt, &submittedAt, &attempt.CreatedAt)
+		err := rows.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &answers, &attempt.Score, &attempt.TimeSpent, &attempt.Completed, &attempt.Passed, &attempt.AttemptNumber, &attempt.StartedAt, &submittedAt, &attempt.CreatedAt, &expiresAt, &remaining, &attempt.AutoSubmitted, &points, &maxPoints)
 		if err != nil {
 			return nil, err
 		}
+		attempt.Points, attempt.MaxPoints = nullFloatPtr(points), nullFloatPtr(maxPoints)
+		attempt.ExpiresAt, attempt.RemainingSeconds = attemptClock(expiresAt, remaining)
 
 		// Handle NULL answers
 		if answers.Valid {
@@ -325,57 +284,78 @@ func GetQuizAttempts(db *sql.DB, userID, quizID int) ([]QuizAttempt, error) {
 	return attempts, nil
 }
 
-// calculateQuizScore calculates the score for a quiz submission
-// This is a simplified implementation - you'd implement proper scoring logic based on your quiz format
-func calculateQuizScore(questions, answers json.RawMessage) (score, correctAnswers, totalQuestions int) {
-	// Parse questions and answers
-	var questionsData []map[string]interface{}
+// calculateQuizScore grades a quiz submission, answers being keyed by
+// question ID
+func calculateQuizScore(questions, answers json.RawMessage, scoring QuizScoring) QuizGrade {
+	questionsData, err := ParseQuizQuestions(questions)
+	if err != nil {
+		return QuizGrade{}
+	}
 	var answersData map[string]interface{}
-
-	json.Unmarshal(questions, &questionsData)
 	json.Unmarshal(answers, &answersData)
 
-	totalQuestions = len(questionsData)
-	correctAnswers = 0
-
-	// Simple scoring logic - you'd implement based on your question format
-	for _, question := range questionsData {
-		questionID := question["id"]
-		correctAnswer := question["correctAnswer"]
-		userAnswer := answersData[questionID.(string)]
+	return GradeQuiz(questionsData, answersData, scoring)
+}
 
-		if userAnswer == correctAnswer {
-			correctAnswers++
-		}
+// CreateQuiz creates a new quiz. Its draw rules must be satisfiable by the
+// question bank of its course.
+func CreateQuiz(db *sql.DB, quiz *Quiz) error {
+	if err := quiz.Scoring.Validate(); err != nil {
+		return err
 	}
-
-	if totalQuestions > 0 {
-		score = (correctAnswers * 100) / totalQuestions
+	if err := ValidateDrawRules(db, quiz.CourseID, quiz.DrawRules); err != nil {
+		return err
+	}
+	drawRules, err := drawRulesParam(quiz.DrawRules)
+	if err != nil {
+		return err
 	}
 
-	return score, correctAnswers, totalQuestions
-}
-
-// CreateQuiz creates a new quiz
-func CreateQuiz(db *sql.DB, quiz *Quiz) error {
 	query := `
-	INSERT INTO quizzes (course_id, title, description, questions, time_limit, max_attempts, passing_score, quiz_type, is_active, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	INSERT INTO quizzes (course_id, module_id, title, description, questions, time_limit, max_attempts, passing_score, quiz_type, draw_rules, shuffle_options, negative_marking, score_rounding, is_active, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	RETURNING id, created_at, updated_at
 	`
-	row := db.QueryRow(query, quiz.CourseID, quiz.Title, quiz.Description, quiz.Questions, quiz.TimeLimit, quiz.MaxAttempts, quiz.PassingScore, quiz.QuizType, quiz.IsActive)
+	row := db.QueryRow(query, quiz.CourseID, quiz.ModuleID, quiz.Title, quiz.Description, quiz.Questions, quiz.TimeLimit, quiz.MaxAttempts, quiz.PassingScore, quiz.QuizType, drawRules, quiz.ShuffleOptions, quiz.Scoring.NegativeMarking, quiz.Scoring.Rounding, quiz.IsActive)
 	return row.Scan(&quiz.ID, &quiz.CreatedAt, &quiz.UpdatedAt)
 }
 
-// UpdateQuiz updates an existing quiz
-func UpdateQuiz(db *sql.DB, quiz *Quiz) error {
+// UpdateQuiz updates an existing quiz; nil questions keep the stored ones
+func UpdateQuiz(db sqlExecutor, quiz *Quiz) error {
+	var questions interface{}
+	if len(quiz.Questions) > 0 {
+		questions = quiz.Questions
+	}
 	query := `
 	UPDATE quizzes 
-	SET title = $1, description = $2, questions = $3, time_limit = $4, max_attempts = $5, passing_score = $6, quiz_type = $7, updated_at = CURRENT_TIMESTAMP
+	SET title = $1, description = $2, questions = COALESCE($3, questions), time_limit = $4, max_attempts = $5, passing_score = $6, quiz_type = $7, updated_at = CURRENT_TIMESTAMP
 	WHERE id = $8
-	RETURNING updated_at
+	RETURNING questions, updated_at
 	`
-	return db.QueryRow(query, quiz.Title, quiz.Description, quiz.Questions, quiz.TimeLimit, quiz.MaxAttempts, quiz.PassingScore, quiz.QuizType, quiz.ID).Scan(&quiz.UpdatedAt)
+	return db.QueryRow(query, quiz.Title, quiz.Description, questions, quiz.TimeLimit, quiz.MaxAttempts, quiz.PassingScore, quiz.QuizType, quiz.ID).Scan(&quiz.Questions, &quiz.UpdatedAt)
+}
+
+// SetQuizModule moves a quiz into a module of its course, or out of its module when moduleID is 0
+func SetQuizModule(db sqlExecutor, quizID, moduleID int) error {
+	var courseID int
+	if err := db.QueryRow(`SELECT course_id FROM quizzes WHERE id = $1`, quizID).Scan(&courseID); err != nil {
+		return err
+	}
+
+	var value interface{}
+	if moduleID != 0 {
+		ok, err := ModuleBelongsToCourse(db, courseID, moduleID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrModuleNotInCourse
+		}
+		value = moduleID
+	}
+
+	_, err := db.Exec(`UPDATE quizzes SET module_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, value, quizID)
+	return err
 }
 
 // DeleteQuiz soft deletes a quiz by setting is_active to false
@@ -385,36 +365,64 @@ func DeleteQuiz(db *sql.DB, quizID int) error {
 	return err
 }
 
-// GetAllQuizzes gets all quizzes (admin only)
-func GetAllQuizzes(db *sql.DB) ([]Quiz, error) {
-	query := `
-	SELECT id, course_id, lesson_id, title, description, questions, time_limit, 
-	       max_attempts, passing_score, quiz_type, is_active, created_at, updated_at
-	FROM quizzes
-	ORDER BY created_at DESC
-	`
-	rows, err := db.Query(query)
+// QuizListSpec are the sort and filter fields of the admin quiz list
+var QuizListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"id":           {Column: "id", Type: ListNumber},
+		"courseId":     {Column: "course_id", Type: ListNumber},
+		"lessonId":     {Column: "lesson_id", Type: ListNumber},
+		"moduleId":     {Column: "module_id", Type: ListNumber},
+		"title":        {Column: "title", Type: ListText},
+		"timeLimit":    {Column: "time_limit", Type: ListNumber},
+		"maxAttempts":  {Column: "max_attempts", Type: ListNumber},
+		"passingScore": {Column: "passing_score", Type: ListNumber},
+		"quizType":     {Column: "quiz_type", Type: ListText},
+		"isActive":     {Column: "is_active", Type: ListBool},
+		"createdAt":    {Column: "created_at", Type: ListTime},
+		"updatedAt":    {Column: "updated_at", Type: ListTime},
+	},
+	SearchColumns: []string{"title", "description"},
+	DefaultSort:   "-createdAt",
+	IDColumn:      "id",
+}
+
+// GetAllQuizzes gets a page of quizzes (admin only)
+func GetAllQuizzes(db *sql.DB, list *ListQuery) ([]Quiz, *ListPage, error) {
+	columns := `id, course_id, lesson_id, module_id, title, description, questions, time_limit,
+	       max_attempts, passing_score, quiz_type, draw_rules, shuffle_options, negative_marking, score_rounding, is_active, created_at, updated_at`
+	rows, page, err := list.Query(db, columns, "quizzes", nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	var quizzes []Quiz
 	for rows.Next() {
 		var quiz Quiz
-		var lessonID sql.NullInt64
-		err := rows.Scan(&quiz.ID, &quiz.CourseID, &lessonID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
+		var lessonID, moduleID sql.NullInt64
+		var drawRules []byte
+		err := rows.Scan(&quiz.ID, &quiz.CourseID, &lessonID, &moduleID, &quiz.Title, &quiz.Description, &quiz.Questions, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, &quiz.QuizType, &drawRules, &quiz.ShuffleOptions, &quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		quiz.DrawRules = parseDrawRules(drawRules)
 
 		if lessonID.Valid {
 			lessonIDInt := int(lessonID.Int64)
 			quiz.LessonID = &lessonIDInt
 		}
+		quiz.ModuleID = nullIntPtr(moduleID)
 
 		quizzes = append(quizzes, quiz)
 	}
 
-	return quizzes, nil
-}
\ No newline at end of file
+	return quizzes, page, rows.Err()
+}
+// nullIntPtr converts a nullable integer column to an optional int
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	i := int(value.Int64)
+	return &i
+}
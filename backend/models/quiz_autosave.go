@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrAttemptCompleted is returned when answers are saved to a submitted attempt
+var ErrAttemptCompleted = errors.New("quiz attempt is already completed")
+
+// ErrMaxAttempts is returned when a user starts a quiz they have no attempts
+// left at
+var ErrMaxAttempts = errors.New("maximum attempts reached")
+
+// ErrUnknownAnswer is returned when saved answers refer to a question that is
+// not part of the attempt
+var ErrUnknownAnswer = errors.New("answer to a question not in the attempt")
+
+// SaveQuizAttemptAnswers merges partial answers, keyed by question ID, into
+// the saved answers of an open attempt and returns the time left on it.
+// Answers must be keyed by questions of the attempt. An attempt past its
+// deadline is closed with the answers saved before and ErrAttemptExpired is
+// returned, or the error closing it.
+func SaveQuizAttemptAnswers(db *sql.DB, attemptID int, answers map[string]interface{}) (*int, error) {
+	var questionsJSON []byte
+	err := db.QueryRow(`
+		SELECT COALESCE(qa.question_set, q.questions)
+		FROM quiz_attempts qa
+		JOIN quizzes q ON qa.quiz_id = q.id
+		WHERE qa.id = $1
+	`, attemptID).Scan(&questionsJSON)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := ParseQuizQuestions(questionsJSON)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(questions))
+	for _, question := range questions {
+		known[strconv.Itoa(question.ID)] = true
+	}
+	for key := range answers {
+		if !known[key] {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownAnswer, key)
+		}
+	}
+
+	content, err := json.Marshal(answers)
+	if err != nil {
+		return nil, err
+	}
+
+	var remaining sql.NullInt64
+	err = db.QueryRow(`
+		UPDATE quiz_attempts
+		SET answers = COALESCE(answers, '{}'::jsonb) || $1::jsonb
+		WHERE id = $2 AND completed = FALSE AND NOT `+attemptExpiredSQL+`
+		RETURNING `+attemptRemainingSQL, string(content), attemptID).Scan(&remaining)
+	if err == nil {
+		return nullIntPtr(remaining), nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var completed bool
+	if err := db.QueryRow(`SELECT completed FROM quiz_attempts WHERE id = $1`, attemptID).Scan(&completed); err != nil {
+		return nil, err
+	}
+	if completed {
+		return nil, ErrAttemptCompleted
+	}
+	// The attempt is only reported expired once it is closed; a failure to
+	// close it is returned instead
+	if _, err := CloseQuizAttempt(db, attemptID); err != nil {
+		return nil, fmt.Errorf("failed to close expired attempt %d: %w", attemptID, err)
+	}
+	return nil, ErrAttemptExpired
+}
+
+// lockQuizAttempts serializes starting attempts by a user at a quiz until the
+// transaction ends. It takes an advisory lock rather than locking a row, so
+// writes to the user or quiz do not wait on a quiz start.
+func lockQuizAttempts(tx *sql.Tx, userID, quizID int) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, userID, quizID)
+	return err
+}
+
+// findOpenAttemptEnhanced returns a user's latest open attempt at a quiz that
+// is still within its deadline, with its saved answers, or sql.ErrNoRows
+func findOpenAttemptEnhanced(db sqlExecutor, userID, quizID int) (*QuizAttemptEnhanced, error) {
+	var attempt QuizAttemptEnhanced
+	var answersJSON, questionSet []byte
+	var expiresAt sql.NullTime
+	var remaining sql.NullInt64
+	err := db.QueryRow(`
+		SELECT id, quiz_id, user_id, answers, score, time_spent, completed, passed,
+		       attempt_number, started_at, created_at, expires_at, `+attemptRemainingSQL+`, question_set
+		FROM quiz_attempts
+		WHERE user_id = $1 AND quiz_id = $2 AND completed = FALSE AND NOT `+attemptExpiredSQL+`
+		ORDER BY attempt_number DESC
+		LIMIT 1
+	`, userID, quizID).Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &answersJSON, &attempt.Score,
+		&attempt.TimeSpent, &attempt.Completed, &attempt.Passed, &attempt.AttemptNumber, &attempt.StartedAt,
+		&attempt.CreatedAt, &expiresAt, &remaining, &questionSet)
+	if err != nil {
+		return nil, err
+	}
+	attempt.ExpiresAt, attempt.RemainingSeconds = attemptClock(expiresAt, remaining)
+	attempt.Resumed = true
+
+	attempt.Answers = make(map[string]interface{})
+	if len(answersJSON) > 0 {
+		json.Unmarshal(answersJSON, &attempt.Answers)
+	}
+	if len(questionSet) > 0 {
+		questions, err := ParseQuizQuestions(questionSet)
+		if err != nil {
+			return nil, err
+		}
+		attempt.Questions = PublicQuestionViews(questions)
+	}
+	return &attempt, nil
+}
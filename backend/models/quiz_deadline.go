@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrAttemptExpired is returned when an attempt is submitted after its deadline
+var ErrAttemptExpired = errors.New("quiz attempt time limit exceeded")
+
+// Attempts at a quiz with a time limit expire time_limit minutes after they
+// start. Submissions within the grace period after the deadline are still
+// accepted to allow for network latency; the clock is the database's, which
+// also sets started_at.
+const (
+	// attemptDeadlineSQL is the deadline of a new attempt at quiz $1
+	attemptDeadlineSQL = `(SELECT CASE WHEN time_limit > 0 THEN CURRENT_TIMESTAMP + time_limit * INTERVAL '1 minute' END FROM quizzes WHERE id = $1)`
+	// attemptExpiredSQL reports whether an open attempt is past its deadline
+	attemptExpiredSQL = `COALESCE(CURRENT_TIMESTAMP > expires_at + INTERVAL '30 seconds', FALSE)`
+	// attemptTimeSpentSQL is the time an attempt has taken in seconds, up to its deadline
+	attemptTimeSpentSQL = `GREATEST(0, EXTRACT(EPOCH FROM LEAST(CURRENT_TIMESTAMP, COALESCE(expires_at, CURRENT_TIMESTAMP)) - started_at))::INTEGER`
+	// attemptRemainingSQL is the time left on an open attempt in seconds, NULL without a deadline
+	attemptRemainingSQL = `CASE WHEN NOT completed AND expires_at IS NOT NULL THEN GREATEST(0, CEIL(EXTRACT(EPOCH FROM expires_at - CURRENT_TIMESTAMP)))::INTEGER END`
+)
+
+// attemptClock converts the scanned deadline and remaining time of an attempt
+func attemptClock(expiresAt sql.NullTime, remaining sql.NullInt64) (*time.Time, *int) {
+	var deadline *time.Time
+	if expiresAt.Valid {
+		deadline = &expiresAt.Time
+	}
+	return deadline, nullIntPtr(remaining)
+}
+
+// CloseQuizAttempt grades an open attempt past its deadline with the answers
+// saved on it and marks it auto-submitted. It reports false when the attempt
+// was already completed or is not expired.
+func CloseQuizAttempt(db *sql.DB, attemptID int) (bool, error) {
+	var questionsJSON []byte
+	var answersJSON sql.NullString
+	var passingScore int
+	var scoring QuizScoring
+	err := db.QueryRow(`
+		SELECT COALESCE(qa.question_set, q.questions), qa.answers, q.passing_score, q.negative_marking, q.score_rounding
+		FROM quiz_attempts qa
+		JOIN quizzes q ON qa.quiz_id = q.id
+		WHERE qa.id = $1 AND qa.completed = FALSE AND `+attemptExpiredSQL+`
+	`, attemptID).Scan(&questionsJSON, &answersJSON, &passingScore,
+		&scoring.NegativeMarking, &scoring.Rounding)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	questions, err := ParseQuizQuestions(questionsJSON)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse questions: %v", err)
+	}
+	answers := make(map[string]interface{})
+	if answersJSON.Valid {
+		json.Unmarshal([]byte(answersJSON.String), &answers)
+	}
+
+	grade := GradeQuiz(questions, answers, scoring)
+	result, err := db.Exec(`
+		UPDATE quiz_attempts
+		SET score = $1, passed = $2, time_spent = `+attemptTimeSpentSQL+`, completed = TRUE,
+		    auto_submitted = TRUE, submitted_at = CURRENT_TIMESTAMP, points = $4, max_points = $5
+		WHERE id = $3 AND completed = FALSE
+	`, grade.Score, grade.Score >= passingScore, attemptID, grade.Points, grade.MaxPoints)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CloseExpiredQuizAttempts closes every open attempt past its deadline and
+// returns the IDs of the attempts it closed. An attempt that fails to close is
+// logged and skipped so it does not hold up the others.
+func CloseExpiredQuizAttempts(db *sql.DB) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT id FROM quiz_attempts
+		WHERE completed = FALSE AND expires_at IS NOT NULL AND ` + attemptExpiredSQL + `
+		ORDER BY expires_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var expired []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var closed []int
+	for _, id := range expired {
+		ok, err := CloseQuizAttempt(db, id)
+		if err != nil {
+			log.Printf("[ERROR] Failed to close expired quiz attempt %d: %v", id, err)
+			continue
+		}
+		if ok {
+			closed = append(closed, id)
+		}
+	}
+	return closed, nil
+}
@@ -7,14 +7,36 @@ import (
 	"time"
 )
 
-// QuizQuestion represents a single quiz question
+// QuizQuestion represents a single quiz question. Which answer key fields
+// are used depends on its Type; see quiz_question.go for how each type is
+// answered and graded.
 type QuizQuestion struct {
 	ID            int      `json:"id"`
+	Type          string   `json:"type,omitempty"` // single_choice when empty
 	Question      string   `json:"question"`
-	Options       []string `json:"options"`
-	CorrectAnswer int      `json:"correctAnswer"`
-	Explanation   string   `json:"explanation,omitempty"`
-	Points        int      `json:"points"`
+	Options       []string `json:"options,omitempty"`
+	CorrectAnswer int      `json:"correctAnswer"` // single_choice: index of the correct option
+	// CorrectOptions are the indexes of the correct options of a multiple_choice question
+	CorrectOptions []int `json:"correctOptions,omitempty"`
+	// CorrectBool is the answer of a true_false question
+	CorrectBool *bool `json:"correctBool,omitempty"`
+	// CorrectNumber is the answer of a numeric question, accepted within Tolerance
+	CorrectNumber *float64 `json:"correctNumber,omitempty"`
+	Tolerance     float64  `json:"tolerance,omitempty"`
+	// AcceptedAnswers of a short_text question; /.../ entries are regular expressions
+	AcceptedAnswers []string `json:"acceptedAnswers,omitempty"`
+	CaseSensitive   bool     `json:"caseSensitive,omitempty"`
+	// Pairs of a matching question
+	Pairs []MatchingPair `json:"pairs,omitempty"`
+	// Items of an ordering question, in the correct order
+	Items []string `json:"items,omitempty"`
+	// ShownItems are the items in the order an attempt shows them
+	ShownItems []string `json:"shownItems,omitempty"`
+	// PartialCredit gives multiple_choice, matching and ordering answers
+	// credit for their correct parts
+	PartialCredit bool   `json:"partialCredit,omitempty"`
+	Explanation   string `json:"explanation,omitempty"`
+	Points        int    `json:"points"`
 }
 
 // QuizEnhanced represents an enhanced quiz structure
@@ -28,6 +50,9 @@ type QuizEnhanced struct {
 	MaxAttempts  int            `json:"maxAttempts"`
 	PassingScore int            `json:"passingScore"` // percentage
 	QuizType     string         `json:"quizType"`     // pretest, posttest
+	DrawRules      []QuizDrawRule `json:"drawRules,omitempty"`
+	ShuffleOptions bool           `json:"shuffleOptions"`
+	Scoring        QuizScoring    `json:"scoring"`
 	IsActive     bool           `json:"isActive"`
 	CreatedAt    time.Time      `json:"createdAt"`
 	UpdatedAt    time.Time      `json:"updatedAt"`
@@ -49,19 +74,36 @@ type QuizAttemptEnhanced struct {
 	StartedAt     time.Time              `json:"startedAt"`
 	SubmittedAt   *time.Time             `json:"submittedAt,omitempty"`
 	CreatedAt     time.Time              `json:"createdAt"`
+	// Questions are the questions drawn for the attempt, as shown to the
+	// learner; empty when the attempt shows the quiz's own questions
+	Questions []map[string]interface{} `json:"questions,omitempty"`
+	// ExpiresAt is the deadline of an attempt at a timed quiz and
+	// RemainingSeconds the time left on it while it is open
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	RemainingSeconds *int       `json:"remainingSeconds,omitempty"`
+	// AutoSubmitted attempts were closed by the server at their deadline
+	AutoSubmitted bool `json:"autoSubmitted"`
+	// Points earned out of MaxPoints; unset on attempts graded before
+	// questions were weighted
+	Points    *float64 `json:"points,omitempty"`
+	MaxPoints *float64 `json:"maxPoints,omitempty"`
+	// Resumed is set when starting returned an attempt that was already open
+	Resumed bool `json:"resumed,omitempty"`
 }
 
-// QuizSubmissionEnhanced represents an enhanced quiz submission
+// QuizSubmissionEnhanced represents an enhanced quiz submission. The time
+// spent is measured by the server from the attempt's start.
 type QuizSubmissionEnhanced struct {
 	AttemptID int                    `json:"attemptId"`
 	Answers   map[string]interface{} `json:"answers"`
-	TimeSpent int                    `json:"timeSpent"`
 }
 
 // QuizResultEnhanced represents an enhanced quiz result
 type QuizResultEnhanced struct {
 	AttemptID      int                    `json:"attemptId"`
 	Score          int                    `json:"score"`
+	Points         float64                `json:"points"`
+	MaxPoints      float64                `json:"maxPoints"`
 	CorrectCount   int                    `json:"correctCount"`
 	TotalCount     int                    `json:"totalCount"`
 	Passed         bool                   `json:"passed"`
@@ -69,15 +111,19 @@ type QuizResultEnhanced struct {
 	AttemptNumber  int                    `json:"attemptNumber"`
 	CanRetake      bool                   `json:"canRetake"`
 	Answers        map[string]interface{} `json:"answers"`
-	CorrectAnswers map[string]int         `json:"correctAnswers"`
+	CorrectAnswers map[string]interface{} `json:"correctAnswers"`
 	Explanations   map[string]string      `json:"explanations"`
+	// Credit is the share of each question's credit earned, from 0 to 1
+	Credit map[string]float64 `json:"credit,omitempty"`
+	// AutoSubmitted is set when the server closed the attempt at its deadline
+	AutoSubmitted bool `json:"autoSubmitted"`
 }
 
 // GetQuizEnhancedByTypeAndCourse gets an enhanced quiz by type and course
 func GetQuizEnhancedByTypeAndCourse(db *sql.DB, courseID int, quizType string) (*QuizEnhanced, error) {
 	query := `
 	SELECT id, course_id, title, description, questions, time_limit,
-	       max_attempts, passing_score, quiz_type, is_active, created_at, updated_at
+	       max_attempts, passing_score, quiz_type, draw_rules, shuffle_options, negative_marking, score_rounding, is_active, created_at, updated_at
 	FROM quizzes
 	WHERE course_id = $1 AND quiz_type = $2 AND is_active = TRUE
 	LIMIT 1
@@ -88,12 +134,14 @@ func GetQuizEnhancedByTypeAndCourse(db *sql.DB, courseID int, quizType string) (
 
 	var quiz QuizEnhanced
 	var questionsJSON json.RawMessage
+	var drawRules []byte
 	err := row.Scan(&quiz.ID, &quiz.CourseID, &quiz.Title, &quiz.Description, 
 		&questionsJSON, &quiz.TimeLimit, &quiz.MaxAttempts, &quiz.PassingScore, 
-		&quiz.QuizType, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
+		&quiz.QuizType, &drawRules, &quiz.ShuffleOptions, &quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &quiz.IsActive, &quiz.CreatedAt, &quiz.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	quiz.DrawRules = parseDrawRules(drawRules)
 
 	// Parse questions JSON
 	err = json.Unmarshal(questionsJSON, &quiz.Questions)
@@ -104,68 +152,119 @@ func GetQuizEnhancedByTypeAndCourse(db *sql.DB, courseID int, quizType string) (
 	return &quiz, nil
 }
 
-// StartQuizAttemptEnhanced creates a new enhanced quiz attempt
+// StartQuizAttemptEnhanced creates a new enhanced quiz attempt, or returns
+// the user's attempt that is still open so it can be resumed
 func StartQuizAttemptEnhanced(db *sql.DB, userID, quizID int) (*QuizAttemptEnhanced, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Concurrent starts by the same user wait here, so only the first creates
+	// an attempt and the others resume it
+	if err := lockQuizAttempts(tx, userID, quizID); err != nil {
+		return nil, err
+	}
+
+	// Resume an open attempt rather than using up another one
+	open, err := findOpenAttemptEnhanced(tx, userID, quizID)
+	if err == nil {
+		return open, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
 	// Check how many attempts user has made
 	var attemptCount int
-	err := db.QueryRow("SELECT COUNT(*) FROM quiz_attempts WHERE user_id = $1 AND quiz_id = $2", userID, quizID).Scan(&attemptCount)
+	err = tx.QueryRow("SELECT COUNT(*) FROM quiz_attempts WHERE user_id = $1 AND quiz_id = $2", userID, quizID).Scan(&attemptCount)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check max attempts
 	var maxAttempts int
-	err = db.QueryRow("SELECT max_attempts FROM quizzes WHERE id = $1", quizID).Scan(&maxAttempts)
+	err = tx.QueryRow("SELECT max_attempts FROM quizzes WHERE id = $1", quizID).Scan(&maxAttempts)
 	if err != nil {
 		return nil, err
 	}
 
 	if attemptCount >= maxAttempts {
-		return nil, fmt.Errorf("maximum attempts (%d) reached", maxAttempts)
+		return nil, fmt.Errorf("%w: %d of %d used", ErrMaxAttempts, attemptCount, maxAttempts)
+	}
+
+	// Draw the attempt's questions, kept on the attempt for grading
+	questionSet, err := AssembleAttemptQuestions(tx, quizID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble questions: %w", err)
+	}
+	questionSetParam, err := AttemptQuestionsParam(questionSet)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create new attempt
 	query := `
-	INSERT INTO quiz_attempts (quiz_id, user_id, attempt_number, started_at, created_at)
-	VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-	RETURNING id, quiz_id, user_id, score, time_spent, completed, passed, attempt_number, started_at, created_at
+	INSERT INTO quiz_attempts (quiz_id, user_id, attempt_number, question_set, started_at, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, ` + attemptDeadlineSQL + `, CURRENT_TIMESTAMP)
+	RETURNING id, quiz_id, user_id, score, time_spent, completed, passed, attempt_number, started_at, created_at,
+	          expires_at, ` + attemptRemainingSQL + `, auto_submitted
 	`
-	row := db.QueryRow(query, quizID, userID, attemptCount+1)
+	row := tx.QueryRow(query, quizID, userID, attemptCount+1, questionSetParam)
 
 	var attempt QuizAttemptEnhanced
+	var expiresAt sql.NullTime
+	var remaining sql.NullInt64
 	err = row.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &attempt.Score, 
 		&attempt.TimeSpent, &attempt.Completed, &attempt.Passed, &attempt.AttemptNumber, 
-		&attempt.StartedAt, &attempt.CreatedAt)
+		&attempt.StartedAt, &attempt.CreatedAt, &expiresAt, &remaining, &attempt.AutoSubmitted)
 	if err != nil {
 		return nil, err
 	}
+	attempt.ExpiresAt, attempt.RemainingSeconds = attemptClock(expiresAt, remaining)
 
 	// Initialize empty answers
 	attempt.Answers = make(map[string]interface{})
+	attempt.Questions = PublicQuestionViews(questionSet)
 
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &attempt, nil
 }
 
-// SubmitQuizAttemptEnhanced submits an enhanced quiz attempt
+// SubmitQuizAttemptEnhanced submits an enhanced quiz attempt. An attempt past
+// its deadline is closed with its saved answers instead and ErrAttemptExpired
+// is returned.
 func SubmitQuizAttemptEnhanced(db *sql.DB, submission QuizSubmissionEnhanced) (*QuizResultEnhanced, error) {
 	// Get attempt and quiz info
 	var attempt QuizAttemptEnhanced
 	var quiz QuizEnhanced
 	var questionsJSON json.RawMessage
+	var expired bool
 	
 	query := `
 	SELECT qa.id, qa.quiz_id, qa.user_id, qa.attempt_number, qa.started_at,
-	       q.questions, q.passing_score, q.max_attempts, q.title
+	       COALESCE(qa.question_set, q.questions), q.passing_score, q.max_attempts, q.title,
+	       q.negative_marking, q.score_rounding, ` + attemptTimeSpentSQL + `, ` + attemptExpiredSQL + `
 	FROM quiz_attempts qa
 	JOIN quizzes q ON qa.quiz_id = q.id
 	WHERE qa.id = $1 AND qa.completed = FALSE
 	`
 	row := db.QueryRow(query, submission.AttemptID)
 	err := row.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &attempt.AttemptNumber, 
-		&attempt.StartedAt, &questionsJSON, &quiz.PassingScore, &quiz.MaxAttempts, &quiz.Title)
+		&attempt.StartedAt, &questionsJSON, &quiz.PassingScore, &quiz.MaxAttempts, &quiz.Title,
+		&quiz.Scoring.NegativeMarking, &quiz.Scoring.Rounding, &attempt.TimeSpent, &expired)
 	if err != nil {
 		return nil, fmt.Errorf("attempt not found or already completed: %v", err)
 	}
+	if expired {
+		if _, err := CloseQuizAttempt(db, submission.AttemptID); err != nil {
+			return nil, fmt.Errorf("failed to close expired attempt: %v", err)
+		}
+		return nil, ErrAttemptExpired
+	}
 
 	// Parse questions
 	err = json.Unmarshal(questionsJSON, &quiz.Questions)
@@ -174,29 +273,17 @@ func SubmitQuizAttemptEnhanced(db *sql.DB, submission QuizSubmissionEnhanced) (*
 	}
 
 	// Calculate score
-	correctCount := 0
-	totalCount := len(quiz.Questions)
-	correctAnswers := make(map[string]int)
+	grade := GradeQuiz(quiz.Questions, submission.Answers, quiz.Scoring)
+	correctAnswers := make(map[string]interface{})
 	explanations := make(map[string]string)
 
 	for _, question := range quiz.Questions {
 		questionIDStr := fmt.Sprintf("%d", question.ID)
-		correctAnswers[questionIDStr] = question.CorrectAnswer
+		correctAnswers[questionIDStr] = question.CorrectAnswerKey()
 		explanations[questionIDStr] = question.Explanation
-		
-		if userAnswer, exists := submission.Answers[questionIDStr]; exists {
-			if userAnswerInt, ok := userAnswer.(float64); ok {
-				if int(userAnswerInt) == question.CorrectAnswer {
-					correctCount++
-				}
-			}
-		}
 	}
 
-	score := 0
-	if totalCount > 0 {
-		score = (correctCount * 100) / totalCount
-	}
+	score := grade.Score
 	passed := score >= quiz.PassingScore
 
 	// Convert answers to JSON
@@ -208,13 +295,18 @@ func SubmitQuizAttemptEnhanced(db *sql.DB, submission QuizSubmissionEnhanced) (*
 	// Update attempt
 	updateQuery := `
 	UPDATE quiz_attempts 
-	SET answers = $1, score = $2, time_spent = $3, completed = TRUE, passed = $4, submitted_at = CURRENT_TIMESTAMP
-	WHERE id = $5
+	SET answers = $1, score = $2, time_spent = $3, completed = TRUE, passed = $4, submitted_at = CURRENT_TIMESTAMP,
+	    points = $6, max_points = $7
+	WHERE id = $5 AND completed = FALSE
 	`
-	_, err = db.Exec(updateQuery, answersJSON, score, submission.TimeSpent, passed, submission.AttemptID)
+	updated, err := db.Exec(updateQuery, answersJSON, score, attempt.TimeSpent, passed, submission.AttemptID,
+		grade.Points, grade.MaxPoints)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update attempt: %v", err)
 	}
+	if affected, err := updated.RowsAffected(); err != nil || affected == 0 {
+		return nil, fmt.Errorf("attempt not found or already completed")
+	}
 
 	// Check if can retake
 	var totalAttempts int
@@ -229,15 +321,18 @@ func SubmitQuizAttemptEnhanced(db *sql.DB, submission QuizSubmissionEnhanced) (*
 	return &QuizResultEnhanced{
 		AttemptID:      submission.AttemptID,
 		Score:          score,
-		CorrectCount:   correctCount,
-		TotalCount:     totalCount,
+		Points:         grade.Points,
+		MaxPoints:      grade.MaxPoints,
+		CorrectCount:   grade.CorrectCount,
+		TotalCount:     grade.TotalCount,
 		Passed:         passed,
-		TimeSpent:      submission.TimeSpent,
+		TimeSpent:      attempt.TimeSpent,
 		AttemptNumber:  attempt.AttemptNumber,
 		CanRetake:      canRetake,
 		Answers:        submission.Answers,
 		CorrectAnswers: correctAnswers,
 		Explanations:   explanations,
+		Credit:         grade.Credit,
 	}, nil
 }
 
@@ -245,7 +340,8 @@ func SubmitQuizAttemptEnhanced(db *sql.DB, submission QuizSubmissionEnhanced) (*
 func GetQuizAttemptsEnhanced(db *sql.DB, userID, quizID int) ([]QuizAttemptEnhanced, error) {
 	query := `
 	SELECT id, quiz_id, user_id, answers, score, time_spent, completed, passed, 
-	       attempt_number, started_at, submitted_at, created_at
+	       attempt_number, started_at, submitted_at, created_at,
+	       expires_at, ` + attemptRemainingSQL + `, auto_submitted, points, max_points
 	FROM quiz_attempts
 	WHERE user_id = $1 AND quiz_id = $2
 	ORDER BY attempt_number DESC
@@ -259,15 +355,20 @@ func GetQuizAttemptsEnhanced(db *sql.DB, userID, quizID int) ([]QuizAttemptEnhan
 	var attempts []QuizAttemptEnhanced
 	for rows.Next() {
 		var attempt QuizAttemptEnhanced
-		var submittedAt sql.NullTime
+		var submittedAt, expiresAt sql.NullTime
+		var remaining sql.NullInt64
+		var points, maxPoints sql.NullFloat64
 		var answersJSON sql.NullString
 		
 		err := rows.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &answersJSON, 
 			&attempt.Score, &attempt.TimeSpent, &attempt.Completed, &attempt.Passed, 
-			&attempt.AttemptNumber, &attempt.StartedAt, &submittedAt, &attempt.CreatedAt)
+			&attempt.AttemptNumber, &attempt.StartedAt, &submittedAt, &attempt.CreatedAt,
+			&expiresAt, &remaining, &attempt.AutoSubmitted, &points, &maxPoints)
 		if err != nil {
 			return nil, err
 		}
+		attempt.Points, attempt.MaxPoints = nullFloatPtr(points), nullFloatPtr(maxPoints)
+		attempt.ExpiresAt, attempt.RemainingSeconds = attemptClock(expiresAt, remaining)
 
 		// Parse answers
 		if answersJSON.Valid {
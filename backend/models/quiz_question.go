@@ -0,0 +1,697 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Question types of QuizQuestion.Type. Questions without a type are single
+// choice, the only kind that existed before typed questions.
+const (
+	QuestionSingleChoice   = "single_choice"
+	QuestionMultipleChoice = "multiple_choice"
+	QuestionTrueFalse      = "true_false"
+	QuestionNumeric        = "numeric"
+	QuestionShortText      = "short_text"
+	QuestionMatching       = "matching"
+	QuestionOrdering       = "ordering"
+)
+
+// ErrInvalidQuestion is returned for questions without a usable answer key
+var ErrInvalidQuestion = errors.New("invalid question")
+
+// QuestionScorer validates and grades the questions of one type
+type QuestionScorer interface {
+	// Validate checks that a question has a complete answer key
+	Validate(q QuizQuestion) error
+	// Score returns the credit an answer earns, from 0 (wrong or missing)
+	// to 1 (fully correct)
+	Score(q QuizQuestion, answer interface{}) float64
+	// Key returns the correct answer in the format learners answer in
+	Key(q QuizQuestion) interface{}
+	// Present adds what a learner needs to answer the question to its public
+	// view, without revealing the answer
+	Present(q QuizQuestion, view map[string]interface{})
+}
+
+// OptionShuffler is implemented by the scorers of question types whose
+// options can be shown in another order. ShuffleOptions returns the question
+// with its options shuffled and its answer key following them.
+type OptionShuffler interface {
+	ShuffleOptions(q QuizQuestion) QuizQuestion
+}
+
+// AttemptPreparer is implemented by the scorers of question types that fix
+// what a learner sees once per attempt. PrepareAttempt returns the question
+// as the attempt keeps it.
+type AttemptPreparer interface {
+	PrepareAttempt(q QuizQuestion) QuizQuestion
+}
+
+// questionScorers are the scorers of the known question types
+var questionScorers = map[string]QuestionScorer{
+	QuestionSingleChoice:   singleChoiceScorer{},
+	QuestionMultipleChoice: multipleChoiceScorer{},
+	QuestionTrueFalse:      trueFalseScorer{},
+	QuestionNumeric:        numericScorer{},
+	QuestionShortText:      shortTextScorer{},
+	QuestionMatching:       matchingScorer{},
+	QuestionOrdering:       orderingScorer{},
+}
+
+// RegisterQuestionScorer adds a question type or replaces the scorer of one.
+// It must be called before the server starts handling requests.
+func RegisterQuestionScorer(questionType string, scorer QuestionScorer) {
+	questionScorers[questionType] = scorer
+}
+
+// QuestionType returns the type of a question, single choice when unset
+func (q QuizQuestion) QuestionType() string {
+	if q.Type == "" {
+		return QuestionSingleChoice
+	}
+	return q.Type
+}
+
+// scorer returns the scorer of the question's type, nil for unknown types
+func (q QuizQuestion) scorer() QuestionScorer {
+	return questionScorers[q.QuestionType()]
+}
+
+// PublicView returns the question as shown to learners: its text, type,
+// points and answer choices, without the answer key or explanation
+func (q QuizQuestion) PublicView() map[string]interface{} {
+	view := map[string]interface{}{
+		"id":       q.ID,
+		"type":     q.QuestionType(),
+		"question": q.Question,
+		"points":   q.Weight(),
+	}
+	if scorer := q.scorer(); scorer != nil {
+		scorer.Present(q, view)
+	}
+	return view
+}
+
+// Weight returns the points the question is worth, 1 when unset
+func (q QuizQuestion) Weight() float64 {
+	if q.Points > 0 {
+		return float64(q.Points)
+	}
+	return 1
+}
+
+// CorrectAnswerKey returns the correct answer of the question, nil for
+// unknown types
+func (q QuizQuestion) CorrectAnswerKey() interface{} {
+	if scorer := q.scorer(); scorer != nil {
+		return scorer.Key(q)
+	}
+	return nil
+}
+
+// ParseQuizQuestions decodes the questions JSON of a quiz
+func ParseQuizQuestions(raw json.RawMessage) ([]QuizQuestion, error) {
+	var questions []QuizQuestion
+	if len(raw) == 0 || string(raw) == "null" {
+		return questions, nil
+	}
+	if err := json.Unmarshal(raw, &questions); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuestion, err)
+	}
+	return questions, nil
+}
+
+// PublicQuestionViews returns the questions as shown to learners
+func PublicQuestionViews(questions []QuizQuestion) []map[string]interface{} {
+	views := make([]map[string]interface{}, len(questions))
+	for i, q := range questions {
+		views[i] = q.PublicView()
+	}
+	return views
+}
+
+// PublicQuestionsJSON returns the questions JSON of a quiz as shown to
+// learners, see QuizQuestion.PublicView
+func PublicQuestionsJSON(raw json.RawMessage) (json.RawMessage, error) {
+	questions, err := ParseQuizQuestions(raw)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(PublicQuestionViews(questions))
+}
+
+// ValidateQuizQuestions checks the questions JSON of a quiz: every question
+// needs a unique ID, text, a known type and a complete answer key
+func ValidateQuizQuestions(raw json.RawMessage) error {
+	questions, err := ParseQuizQuestions(raw)
+	if err != nil {
+		return err
+	}
+	seen := make(map[int]bool)
+	for i, q := range questions {
+		if seen[q.ID] {
+			return fmt.Errorf("%w: question %d: duplicate id %d", ErrInvalidQuestion, i+1, q.ID)
+		}
+		seen[q.ID] = true
+		if err := checkQuizQuestion(q); err != nil {
+			return fmt.Errorf("%w: question %d: %v", ErrInvalidQuestion, i+1, err)
+		}
+	}
+	return nil
+}
+
+// ValidateQuizQuestion checks that a question has text, a known type and a
+// complete answer key
+func ValidateQuizQuestion(q QuizQuestion) error {
+	if err := checkQuizQuestion(q); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidQuestion, err)
+	}
+	return nil
+}
+
+// checkQuizQuestion returns what is wrong with a question, if anything
+func checkQuizQuestion(q QuizQuestion) error {
+	if strings.TrimSpace(q.Question) == "" {
+		return errors.New("text is required")
+	}
+	if q.Points < 0 {
+		return errors.New("points cannot be negative")
+	}
+	scorer := q.scorer()
+	if scorer == nil {
+		return fmt.Errorf("unknown type %q", q.Type)
+	}
+	return scorer.Validate(q)
+}
+
+// QuizGrade is the grading of a set of answers against a quiz's questions
+type QuizGrade struct {
+	Score        int                // percentage of the points available
+	Points       float64            // points earned, never below zero
+	MaxPoints    float64            // points available
+	CorrectCount int                // questions answered fully correctly
+	TotalCount   int                // questions in the quiz
+	Credit       map[string]float64 // credit per question ID, 0 to 1
+}
+
+// GradeQuiz scores answers, keyed by question ID, against questions. Each
+// question is worth its points, of which partially correct answers earn the
+// share of credit their scorer gives them; with negative marking, answers
+// earning nothing deduct points instead. Unanswered questions, including
+// blank answers, earn nothing and deduct nothing.
+func GradeQuiz(questions []QuizQuestion, answers map[string]interface{}, scoring QuizScoring) QuizGrade {
+	grade := QuizGrade{TotalCount: len(questions), Credit: make(map[string]float64)}
+	for _, q := range questions {
+		id := strconv.Itoa(q.ID)
+		credit := 0.0
+		answer, answered := answers[id]
+		answered = answered && !answerEmpty(answer)
+		if scorer := q.scorer(); answered && scorer != nil {
+			credit = math.Max(0, math.Min(1, scorer.Score(q, answer)))
+		}
+		grade.Credit[id] = credit
+		grade.MaxPoints += q.Weight()
+		if credit > 0 {
+			grade.Points += credit * q.Weight()
+		} else if answered {
+			grade.Points -= scoring.NegativeMarking * q.Weight()
+		}
+		if credit >= 1 {
+			grade.CorrectCount++
+		}
+	}
+	grade.Points = math.Max(0, grade.Points)
+	if grade.MaxPoints > 0 {
+		grade.Score = scoring.roundScore(grade.Points * 100 / grade.MaxPoints)
+	}
+	grade.Points = math.Round(grade.Points*100) / 100
+	return grade
+}
+
+// partialCredit returns the credit of an answer that is right in share of
+// its parts: the share itself when the question allows partial credit,
+// otherwise all or nothing
+func partialCredit(q QuizQuestion, share float64) float64 {
+	if share >= 1 || q.PartialCredit {
+		return share
+	}
+	return 0
+}
+
+// answerEmpty reports whether an answer leaves the question blank: null, a
+// blank string, or an array or object with nothing but blanks, as autosave
+// sends for questions a learner has not touched
+func answerEmpty(answer interface{}) bool {
+	switch value := answer.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(value) == ""
+	case []interface{}:
+		for _, item := range value {
+			if !answerEmpty(item) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, item := range value {
+			if !answerEmpty(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// answerNumber reads a numeric answer, sent as a JSON number or a string
+func answerNumber(answer interface{}) (float64, bool) {
+	switch value := answer.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// answerStrings reads an answer sent as a JSON array of strings
+func answerStrings(answer interface{}) ([]string, bool) {
+	items, ok := answer.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		value, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// shuffleOptions shuffles the options of a question and returns where each
+// original option index went
+func shuffleOptions(q *QuizQuestion) []int {
+	order := rand.Perm(len(q.Options))
+	options := make([]string, len(q.Options))
+	moved := make([]int, len(q.Options))
+	for to, from := range order {
+		options[to] = q.Options[from]
+		moved[from] = to
+	}
+	q.Options = options
+	return moved
+}
+
+// validOptionIndex reports whether index is an option of the question
+func validOptionIndex(q QuizQuestion, index int) bool {
+	return index >= 0 && index < len(q.Options)
+}
+
+// singleChoiceScorer grades the index of the one correct option
+type singleChoiceScorer struct{}
+
+func (singleChoiceScorer) Validate(q QuizQuestion) error {
+	if len(q.Options) < 2 {
+		return errors.New("needs at least two options")
+	}
+	if !validOptionIndex(q, q.CorrectAnswer) {
+		return errors.New("correctAnswer must be the index of an option")
+	}
+	return nil
+}
+
+func (singleChoiceScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	index, ok := answerNumber(answer)
+	if ok && int(index) == q.CorrectAnswer && index == math.Trunc(index) {
+		return 1
+	}
+	return 0
+}
+
+func (singleChoiceScorer) Key(q QuizQuestion) interface{} {
+	return q.CorrectAnswer
+}
+
+func (singleChoiceScorer) Present(q QuizQuestion, view map[string]interface{}) {
+	view["options"] = q.Options
+}
+
+func (singleChoiceScorer) ShuffleOptions(q QuizQuestion) QuizQuestion {
+	moved := shuffleOptions(&q)
+	if validOptionIndex(q, q.CorrectAnswer) {
+		q.CorrectAnswer = moved[q.CorrectAnswer]
+	}
+	return q
+}
+
+// multipleChoiceScorer grades a set of option indexes. With partial credit
+// each correct option picked earns its share and each wrong one cancels a
+// share.
+type multipleChoiceScorer struct{}
+
+func (multipleChoiceScorer) Validate(q QuizQuestion) error {
+	if len(q.Options) < 2 {
+		return errors.New("needs at least two options")
+	}
+	if len(q.CorrectOptions) == 0 {
+		return errors.New("correctOptions needs at least one option index")
+	}
+	seen := make(map[int]bool)
+	for _, index := range q.CorrectOptions {
+		if !validOptionIndex(q, index) || seen[index] {
+			return errors.New("correctOptions must be distinct option indexes")
+		}
+		seen[index] = true
+	}
+	return nil
+}
+
+func (multipleChoiceScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	items, ok := answer.([]interface{})
+	if !ok {
+		return 0
+	}
+	correct := make(map[int]bool)
+	for _, index := range q.CorrectOptions {
+		correct[index] = true
+	}
+	picked := make(map[int]bool)
+	hits, misses := 0, 0
+	for _, item := range items {
+		value, ok := answerNumber(item)
+		index := int(value)
+		if !ok || picked[index] {
+			continue
+		}
+		picked[index] = true
+		if correct[index] {
+			hits++
+		} else {
+			misses++
+		}
+	}
+	return partialCredit(q, float64(hits-misses)/float64(len(correct)))
+}
+
+func (multipleChoiceScorer) Key(q QuizQuestion) interface{} {
+	return q.CorrectOptions
+}
+
+func (multipleChoiceScorer) Present(q QuizQuestion, view map[string]interface{}) {
+	view["options"] = q.Options
+}
+
+func (multipleChoiceScorer) ShuffleOptions(q QuizQuestion) QuizQuestion {
+	moved := shuffleOptions(&q)
+	correct := make([]int, 0, len(q.CorrectOptions))
+	for _, index := range q.CorrectOptions {
+		if validOptionIndex(q, index) {
+			correct = append(correct, moved[index])
+		}
+	}
+	sort.Ints(correct)
+	q.CorrectOptions = correct
+	return q
+}
+
+// trueFalseScorer grades a true or false answer
+type trueFalseScorer struct{}
+
+func (trueFalseScorer) Validate(q QuizQuestion) error {
+	if q.CorrectBool == nil {
+		return errors.New("correctBool is required")
+	}
+	return nil
+}
+
+func (trueFalseScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	var value bool
+	switch typed := answer.(type) {
+	case bool:
+		value = typed
+	case string:
+		parsed, err := strconv.ParseBool(typed)
+		if err != nil {
+			return 0
+		}
+		value = parsed
+	default:
+		return 0
+	}
+	if value == *q.CorrectBool {
+		return 1
+	}
+	return 0
+}
+
+func (trueFalseScorer) Key(q QuizQuestion) interface{} {
+	return *q.CorrectBool
+}
+
+func (trueFalseScorer) Present(q QuizQuestion, view map[string]interface{}) {}
+
+// numericScorer grades a number within the question's tolerance
+type numericScorer struct{}
+
+func (numericScorer) Validate(q QuizQuestion) error {
+	if q.CorrectNumber == nil {
+		return errors.New("correctNumber is required")
+	}
+	if q.Tolerance < 0 {
+		return errors.New("tolerance cannot be negative")
+	}
+	return nil
+}
+
+func (numericScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	value, ok := answerNumber(answer)
+	// The epsilon accepts answers at the edge of the tolerance that float
+	// arithmetic puts just outside it
+	if ok && math.Abs(value-*q.CorrectNumber) <= q.Tolerance+1e-9 {
+		return 1
+	}
+	return 0
+}
+
+func (numericScorer) Key(q QuizQuestion) interface{} {
+	return *q.CorrectNumber
+}
+
+func (numericScorer) Present(q QuizQuestion, view map[string]interface{}) {}
+
+// shortTextScorer grades a text answer against the accepted answers. An
+// accepted answer written /like this/ is a regular expression that must match
+// the whole answer; others are compared after trimming and collapsing spaces.
+type shortTextScorer struct{}
+
+func (shortTextScorer) Validate(q QuizQuestion) error {
+	if len(q.AcceptedAnswers) == 0 {
+		return errors.New("acceptedAnswers needs at least one answer")
+	}
+	for _, accepted := range q.AcceptedAnswers {
+		if pattern, ok := acceptedPattern(accepted); ok {
+			if _, err := compileAcceptedPattern(q, pattern); err != nil {
+				return fmt.Errorf("invalid pattern %s: %v", accepted, err)
+			}
+		} else if normalizeText(accepted) == "" {
+			return errors.New("acceptedAnswers cannot be empty")
+		}
+	}
+	return nil
+}
+
+func (shortTextScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	text, ok := answer.(string)
+	if !ok {
+		return 0
+	}
+	text = normalizeText(text)
+	if text == "" {
+		return 0
+	}
+	for _, accepted := range q.AcceptedAnswers {
+		if pattern, ok := acceptedPattern(accepted); ok {
+			re, err := compileAcceptedPattern(q, pattern)
+			if err == nil && re.MatchString(text) {
+				return 1
+			}
+			continue
+		}
+		expected := normalizeText(accepted)
+		if text == expected || (!q.CaseSensitive && strings.EqualFold(text, expected)) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (shortTextScorer) Key(q QuizQuestion) interface{} {
+	return q.AcceptedAnswers
+}
+
+func (shortTextScorer) Present(q QuizQuestion, view map[string]interface{}) {}
+
+// acceptedPattern returns the regular expression of an accepted answer
+// written /pattern/
+func acceptedPattern(accepted string) (string, bool) {
+	if len(accepted) > 2 && strings.HasPrefix(accepted, "/") && strings.HasSuffix(accepted, "/") {
+		return accepted[1 : len(accepted)-1], true
+	}
+	return "", false
+}
+
+// compileAcceptedPattern anchors a pattern to the whole answer and makes it
+// case-insensitive unless the question is case sensitive
+func compileAcceptedPattern(q QuizQuestion, pattern string) (*regexp.Regexp, error) {
+	flags := "(?i)"
+	if q.CaseSensitive {
+		flags = ""
+	}
+	return regexp.Compile(flags + `^(?:` + pattern + `)$`)
+}
+
+// normalizeText trims text and collapses its runs of whitespace
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// MatchingPair is a prompt of a matching question and the answer it matches
+type MatchingPair struct {
+	Prompt string `json:"prompt"`
+	Match  string `json:"match"`
+}
+
+// matchingScorer grades the match chosen for each prompt, sent as an array
+// in prompt order. With partial credit each correct match earns its share.
+type matchingScorer struct{}
+
+func (matchingScorer) Validate(q QuizQuestion) error {
+	if len(q.Pairs) < 2 {
+		return errors.New("needs at least two pairs")
+	}
+	prompts := make(map[string]bool)
+	for _, pair := range q.Pairs {
+		if normalizeText(pair.Prompt) == "" || normalizeText(pair.Match) == "" {
+			return errors.New("pairs need a prompt and a match")
+		}
+		if prompts[pair.Prompt] {
+			return fmt.Errorf("duplicate prompt %q", pair.Prompt)
+		}
+		prompts[pair.Prompt] = true
+	}
+	return nil
+}
+
+func (matchingScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	matches, ok := answerStrings(answer)
+	if !ok {
+		return 0
+	}
+	correct := 0
+	for i, pair := range q.Pairs {
+		if i < len(matches) && normalizeText(matches[i]) == normalizeText(pair.Match) {
+			correct++
+		}
+	}
+	return partialCredit(q, float64(correct)/float64(len(q.Pairs)))
+}
+
+func (matchingScorer) Key(q QuizQuestion) interface{} {
+	matches := make([]string, len(q.Pairs))
+	for i, pair := range q.Pairs {
+		matches[i] = pair.Match
+	}
+	return matches
+}
+
+func (matchingScorer) Present(q QuizQuestion, view map[string]interface{}) {
+	prompts := make([]string, len(q.Pairs))
+	var choices []string
+	seen := make(map[string]bool)
+	for i, pair := range q.Pairs {
+		prompts[i] = pair.Prompt
+		if !seen[pair.Match] {
+			seen[pair.Match] = true
+			choices = append(choices, pair.Match)
+		}
+	}
+	// Choices are sorted so their order says nothing about the prompts
+	sort.Strings(choices)
+	view["prompts"] = prompts
+	view["choices"] = choices
+}
+
+// orderingScorer grades items put in order, sent as an array of the items.
+// With partial credit each item in its correct position earns its share.
+type orderingScorer struct{}
+
+func (orderingScorer) Validate(q QuizQuestion) error {
+	if len(q.Items) < 2 {
+		return errors.New("needs at least two items")
+	}
+	seen := make(map[string]bool)
+	for _, item := range q.Items {
+		if normalizeText(item) == "" {
+			return errors.New("items cannot be empty")
+		}
+		if seen[item] {
+			return fmt.Errorf("duplicate item %q", item)
+		}
+		seen[item] = true
+	}
+	return nil
+}
+
+func (orderingScorer) Score(q QuizQuestion, answer interface{}) float64 {
+	order, ok := answerStrings(answer)
+	if !ok {
+		return 0
+	}
+	correct := 0
+	for i, item := range q.Items {
+		if i < len(order) && normalizeText(order[i]) == normalizeText(item) {
+			correct++
+		}
+	}
+	return partialCredit(q, float64(correct)/float64(len(q.Items)))
+}
+
+func (orderingScorer) Key(q QuizQuestion) interface{} {
+	return q.Items
+}
+
+func (orderingScorer) Present(q QuizQuestion, view map[string]interface{}) {
+	if len(q.ShownItems) == len(q.Items) {
+		view["items"] = q.ShownItems
+		return
+	}
+	// Outside an attempt the items are sorted so their order says nothing
+	// about the answer
+	items := append([]string{}, q.Items...)
+	sort.Strings(items)
+	view["items"] = items
+}
+
+// PrepareAttempt shuffles the items once per attempt; any order can come up,
+// including the correct one
+func (orderingScorer) PrepareAttempt(q QuizQuestion) QuizQuestion {
+	q.ShownItems = make([]string, len(q.Items))
+	for to, from := range rand.Perm(len(q.Items)) {
+		q.ShownItems[to] = q.Items[from]
+	}
+	return q
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Rounding modes of a quiz's percentage score
+const (
+	ScoreRoundingFloor = "floor"
+	ScoreRoundingRound = "round"
+	ScoreRoundingCeil  = "ceil"
+)
+
+// ErrInvalidScoring is returned for scoring settings a quiz cannot use
+var ErrInvalidScoring = errors.New("invalid quiz scoring")
+
+// QuizScoring configures how the points a quiz's answers earn become its
+// percentage score
+type QuizScoring struct {
+	// NegativeMarking is the share of a question's points a wrong answer
+	// deducts, from 0 (no negative marking) to 1
+	NegativeMarking float64 `json:"negativeMarking"`
+	// Rounding of the percentage: floor (the default), round or ceil
+	Rounding string `json:"rounding"`
+}
+
+// Validate normalizes and checks scoring settings
+func (s *QuizScoring) Validate() error {
+	if s.Rounding == "" {
+		s.Rounding = ScoreRoundingFloor
+	}
+	if s.Rounding != ScoreRoundingFloor && s.Rounding != ScoreRoundingRound && s.Rounding != ScoreRoundingCeil {
+		return fmt.Errorf("%w: rounding must be floor, round or ceil", ErrInvalidScoring)
+	}
+	if s.NegativeMarking < 0 || s.NegativeMarking > 1 {
+		return fmt.Errorf("%w: negative marking must be between 0 and 1", ErrInvalidScoring)
+	}
+	return nil
+}
+
+// roundScore rounds a percentage to a whole score. The epsilon keeps sums
+// like 0.1+0.2 from rounding across a whole number.
+func (s QuizScoring) roundScore(percentage float64) int {
+	switch s.Rounding {
+	case ScoreRoundingRound:
+		return int(math.Round(percentage + 1e-9))
+	case ScoreRoundingCeil:
+		return int(math.Ceil(percentage - 1e-9))
+	default:
+		return int(math.Floor(percentage + 1e-9))
+	}
+}
+
+// SetQuizScoring sets how a quiz is scored; attempts already graded keep
+// their score. It returns sql.ErrNoRows when the quiz does not exist.
+func SetQuizScoring(db sqlExecutor, quizID int, scoring QuizScoring) error {
+	if err := scoring.Validate(); err != nil {
+		return err
+	}
+	result, err := db.Exec(`
+		UPDATE quizzes SET negative_marking = $1, score_rounding = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`, scoring.NegativeMarking, scoring.Rounding, quizID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// nullFloatPtr converts a nullable float column to a pointer
+func nullFloatPtr(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrRefreshTokenInvalid is returned when a refresh token is unknown, expired or revoked
+var ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+
+type RefreshToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	UserAgent  string     `json:"userAgent"`
+	IPAddress  string     `json:"ipAddress"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	ReplacedBy *int       `json:"replacedBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// CreateRefreshToken issues a new refresh token for a user and returns the raw token
+func CreateRefreshToken(db *sql.DB, userID int, ttl time.Duration, userAgent, ipAddress string) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = db.Exec(query, userID, hashToken(token), userAgent, ipAddress, time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken revokes a refresh token and issues its replacement.
+// Presenting a token that was already rotated is treated as theft: every
+// refresh token of that user is revoked.
+func RotateRefreshToken(db *sql.DB, token string, ttl time.Duration, userAgent, ipAddress string) (int, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var id, userID int
+	var expiresAt time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashToken(token)).Scan(&id, &userID, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if revokedAt.Valid {
+		_, err = tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+		if err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", err
+		}
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	if time.Now().After(expiresAt) {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	newToken, err := generateToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	var newID int
+	err = tx.QueryRow(`
+		INSERT INTO refresh_tokens (user_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, userID, hashToken(newToken), userAgent, ipAddress, time.Now().Add(ttl)).Scan(&newID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $1 WHERE id = $2`, newID, id)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+
+	return userID, newToken, nil
+}
+
+// RevokeRefreshToken revokes a single refresh token belonging to the user
+func RevokeRefreshToken(db *sql.DB, userID int, token string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND token_hash = $2 AND revoked_at IS NULL
+	`
+	_, err := db.Exec(query, userID, hashToken(token))
+	return err
+}
+
+// RevokeAllRefreshTokens revokes every active refresh token of a user
+func RevokeAllRefreshTokens(db *sql.DB, userID int) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+	_, err := db.Exec(query, userID)
+	return err
+}
+
+// RevokeAllSessions revokes all refresh tokens and invalidates all access tokens of a user
+func RevokeAllSessions(db *sql.DB, userID int) error {
+	if err := IncrementTokenVersion(db, userID); err != nil {
+		return err
+	}
+	return RevokeAllRefreshTokens(db, userID)
+}
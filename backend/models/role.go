@@ -0,0 +1,319 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Role scopes
+const (
+	// RoleScopeGlobal roles act on every course
+	RoleScopeGlobal = "global"
+	// RoleScopeCourse roles only act on courses they are assigned to in course_instructors
+	RoleScopeCourse = "course"
+)
+
+// ErrSystemRole is returned when trying to change the permissions of a built-in role
+var ErrSystemRole = errors.New("system roles cannot be modified")
+
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Scope       string    `json:"scope"`
+	IsSystem    bool      `json:"isSystem"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scope       string   `json:"scope"`
+	Permissions []string `json:"permissions"`
+}
+
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+type AssignInstructorRequest struct {
+	UserID int `json:"userId"`
+}
+
+type CourseInstructor struct {
+	UserID     int       `json:"userId"`
+	Username   string    `json:"username"`
+	FullName   string    `json:"fullName"`
+	Email      string    `json:"email"`
+	Role       string    `json:"role"`
+	AssignedAt time.Time `json:"assignedAt"`
+}
+
+// Access is the resolved set of permissions for a user's role
+type Access struct {
+	UserID      int
+	Role        string
+	Scope       string
+	Permissions map[string]bool
+}
+
+// Has reports whether the role grants permission
+func (a *Access) Has(permission string) bool {
+	return a != nil && a.Permissions[permission]
+}
+
+// IsCourseScoped reports whether the role is limited to assigned courses
+func (a *Access) IsCourseScoped() bool {
+	return a != nil && a.Scope == RoleScopeCourse
+}
+
+// CanAccessCourse reports whether the user may act on a course. Global roles
+// can act on every course, course-scoped roles only on assigned ones.
+func (a *Access) CanAccessCourse(db *sql.DB, courseID int) (bool, error) {
+	if a == nil {
+		return false, nil
+	}
+	if !a.IsCourseScoped() {
+		return true, nil
+	}
+	return IsCourseInstructor(db, a.UserID, courseID)
+}
+
+// GetAccessForUser loads the scope and permissions of the user's role
+func GetAccessForUser(db *sql.DB, user *User) (*Access, error) {
+	access, err := GetAccessForRole(db, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	access.UserID = user.ID
+	return access, nil
+}
+
+// GetAccessForRole loads the scope and permissions of a role
+func GetAccessForRole(db *sql.DB, roleName string) (*Access, error) {
+	access := &Access{Role: roleName, Permissions: make(map[string]bool)}
+
+	err := db.QueryRow(`SELECT scope FROM roles WHERE name = $1`, roleName).Scan(&access.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT permission_name FROM role_permissions WHERE role_name = $1`, roleName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		access.Permissions[permission] = true
+	}
+
+	return access, rows.Err()
+}
+
+// RoleExists reports whether a role with the given name exists
+func RoleExists(db *sql.DB, roleName string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM roles WHERE name = $1)`, roleName).Scan(&exists)
+	return exists, err
+}
+
+// GetAllRoles retrieves every role with its permissions
+func GetAllRoles(db *sql.DB) ([]Role, error) {
+	query := `
+		SELECT r.name, COALESCE(r.description, ''), r.scope, r.is_system, r.created_at, rp.permission_name
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_name = r.name
+		ORDER BY r.is_system DESC, r.name, rp.permission_name
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		var permission sql.NullString
+		err := rows.Scan(&role.Name, &role.Description, &role.Scope, &role.IsSystem, &role.CreatedAt, &permission)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].Name != role.Name {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+
+	return roles, rows.Err()
+}
+
+// GetAllPermissions retrieves every known permission
+func GetAllPermissions(db *sql.DB) ([]Permission, error) {
+	rows, err := db.Query(`SELECT name, COALESCE(description, '') FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []Permission
+	for rows.Next() {
+		var permission Permission
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// CreateRole creates a custom role with the given permissions
+func CreateRole(db *sql.DB, req CreateRoleRequest) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO roles (name, description, scope) VALUES ($1, $2, $3)`,
+		req.Name, req.Description, req.Scope)
+	if err != nil {
+		return err
+	}
+
+	if err := insertRolePermissions(tx, req.Name, req.Permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetRolePermissions replaces the permissions of a custom role
+func SetRolePermissions(db *sql.DB, roleName string, permissions []string) error {
+	var isSystem bool
+	err := db.QueryRow(`SELECT is_system FROM roles WHERE name = $1`, roleName).Scan(&isSystem)
+	if err != nil {
+		return err
+	}
+	if isSystem {
+		return ErrSystemRole
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role_name = $1`, roleName); err != nil {
+		return err
+	}
+
+	if err := insertRolePermissions(tx, roleName, permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertRolePermissions(tx *sql.Tx, roleName string, permissions []string) error {
+	for _, permission := range permissions {
+		_, err := tx.Exec(`
+			INSERT INTO role_permissions (role_name, permission_name)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, roleName, permission)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AssignCourseInstructor assigns a user to a course as instructor
+func AssignCourseInstructor(db *sql.DB, courseID, userID int) error {
+	_, err := db.Exec(`
+		INSERT INTO course_instructors (course_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (course_id, user_id) DO NOTHING
+	`, courseID, userID)
+	return err
+}
+
+// RemoveCourseInstructor removes a user's assignment to a course
+func RemoveCourseInstructor(db *sql.DB, courseID, userID int) error {
+	result, err := db.Exec(`DELETE FROM course_instructors WHERE course_id = $1 AND user_id = $2`, courseID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCourseInstructors retrieves the users assigned to a course
+func GetCourseInstructors(db *sql.DB, courseID int) ([]CourseInstructor, error) {
+	query := `
+		SELECT u.id, u.username, u.full_name, u.email, u.role, ci.created_at
+		FROM course_instructors ci
+		JOIN users u ON u.id = ci.user_id
+		WHERE ci.course_id = $1
+		ORDER BY ci.created_at
+	`
+
+	rows, err := db.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var instructors []CourseInstructor
+	for rows.Next() {
+		var instructor CourseInstructor
+		err := rows.Scan(&instructor.UserID, &instructor.Username, &instructor.FullName,
+			&instructor.Email, &instructor.Role, &instructor.AssignedAt)
+		if err != nil {
+			return nil, err
+		}
+		instructors = append(instructors, instructor)
+	}
+
+	return instructors, rows.Err()
+}
+
+// IsCourseInstructor reports whether a user is assigned to a course
+func IsCourseInstructor(db *sql.DB, userID, courseID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM course_instructors WHERE course_id = $1 AND user_id = $2)
+	`, courseID, userID).Scan(&exists)
+	return exists, err
+}
+
+// InstructorCourseFilter returns a condition limiting column to the courses
+// assigned to the instructor bound to placeholder. An instructor ID of 0
+// disables the filter so global roles see every course.
+func InstructorCourseFilter(column, placeholder string) string {
+	return "(" + placeholder + " = 0 OR " + column + " IN (SELECT course_id FROM course_instructors WHERE user_id = " + placeholder + "))"
+}
@@ -0,0 +1,556 @@
+package models
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SCORM package versions
+const (
+	ScormVersion12   = "1.2"
+	ScormVersion2004 = "2004"
+	ScormVersionCmi5 = "cmi5"
+)
+
+// ErrInvalidScormPackage is returned for an archive without a usable manifest
+var ErrInvalidScormPackage = errors.New("invalid SCORM package")
+
+// ErrInvalidScormValue is returned when a runtime commit sets an unknown status
+// or goes over the runtime limits
+var ErrInvalidScormValue = errors.New("invalid SCORM runtime value")
+
+// Runtime data limits per learner and package: the cmi elements stored and the
+// suspend data, which SCORM 2004 caps at 64000 characters
+const (
+	maxScormCMIElements = 4096
+	maxScormSuspendData = 64000
+)
+
+// ScormPackage is the SCORM or cmi5 package behind a lesson of type "scorm"
+type ScormPackage struct {
+	ID           int       `json:"id"`
+	CourseID     int       `json:"courseId"`
+	LessonID     int       `json:"lessonId"`
+	Version      string    `json:"version"`
+	Identifier   string    `json:"identifier"`
+	Title        string    `json:"title"`
+	LaunchPath   string    `json:"launchPath"`
+	StoragePath  string    `json:"-"`
+	MasteryScore *int      `json:"masteryScore,omitempty"`
+	UploadedBy   *int      `json:"uploadedBy,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// ScormManifest is what ParseScormPackage reads from a package's manifest
+type ScormManifest struct {
+	Version      string
+	Identifier   string
+	Title        string
+	LaunchPath   string
+	MasteryScore *int
+}
+
+// ScormRuntime is a learner's stored runtime data for a package. CMI holds every
+// element the package has set; the other fields are derived from it.
+type ScormRuntime struct {
+	PackageID    int               `json:"packageId"`
+	UserID       int               `json:"userId"`
+	CMI          map[string]string `json:"cmi"`
+	LessonStatus string            `json:"lessonStatus"`
+	ScoreRaw     *float64          `json:"scoreRaw"`
+	SuspendData  string            `json:"suspendData"`
+	TotalTime    int               `json:"totalTime"` // in seconds
+	UpdatedAt    *time.Time        `json:"updatedAt,omitempty"`
+	// SessionTime is the time reported by the last commit, in seconds
+	SessionTime int `json:"-"`
+}
+
+// scormStatuses are the lesson statuses a runtime can store
+var scormStatuses = map[string]bool{
+	"passed": true, "completed": true, "failed": true, "incomplete": true,
+	"browsed": true, "not attempted": true,
+}
+
+// imsManifest is the part of imsmanifest.xml needed to launch a package
+type imsManifest struct {
+	Identifier    string `xml:"identifier,attr"`
+	SchemaVersion string `xml:"metadata>schemaversion"`
+	Organizations struct {
+		Default      string            `xml:"default,attr"`
+		Organization []imsOrganization `xml:"organization"`
+	} `xml:"organizations"`
+	Resources struct {
+		Base     string        `xml:"base,attr"`
+		Resource []imsResource `xml:"resource"`
+	} `xml:"resources"`
+}
+
+type imsOrganization struct {
+	Identifier string    `xml:"identifier,attr"`
+	Title      string    `xml:"title"`
+	Items      []imsItem `xml:"item"`
+}
+
+type imsItem struct {
+	IdentifierRef string    `xml:"identifierref,attr"`
+	MasteryScore  string    `xml:"masteryscore"`
+	Items         []imsItem `xml:"item"`
+}
+
+type imsResource struct {
+	Identifier string `xml:"identifier,attr"`
+	Href       string `xml:"href,attr"`
+	Base       string `xml:"base,attr"`
+}
+
+// cmi5Course is the part of cmi5.xml needed to launch a package
+type cmi5Course struct {
+	Course struct {
+		ID    string   `xml:"id,attr"`
+		Title []string `xml:"title>langstring"`
+	} `xml:"course"`
+	AUs    []cmi5AU `xml:"au"`
+	Blocks []struct {
+		AUs []cmi5AU `xml:"au"`
+	} `xml:"block"`
+}
+
+type cmi5AU struct {
+	MasteryScore string `xml:"masteryScore,attr"`
+	URL          string `xml:"url"`
+}
+
+// ParseScormPackage reads the manifest of a SCORM (imsmanifest.xml) or cmi5
+// (cmi5.xml) package and checks that its launch file is in the archive. Only
+// the first SCO or AU is launched.
+func ParseScormPackage(archive *zip.Reader) (*ScormManifest, error) {
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var manifest *ScormManifest
+	var err error
+	if file, ok := files["imsmanifest.xml"]; ok {
+		manifest, err = parseImsManifest(file)
+	} else if file, ok := files["cmi5.xml"]; ok {
+		manifest, err = parseCmi5Course(file)
+	} else {
+		return nil, fmt.Errorf("%w: imsmanifest.xml or cmi5.xml is missing", ErrInvalidScormPackage)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	launchFile := manifest.LaunchPath
+	if i := strings.IndexAny(launchFile, "?#"); i >= 0 {
+		launchFile = launchFile[:i]
+	}
+	if _, ok := files[launchFile]; !ok {
+		return nil, fmt.Errorf("%w: launch file %s is missing", ErrInvalidScormPackage, launchFile)
+	}
+	return manifest, nil
+}
+
+// readXML decodes an XML file of a package
+func readXML(file *zip.File, value interface{}) error {
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidScormPackage, file.Name, err)
+	}
+	defer src.Close()
+	if err := xml.NewDecoder(io.LimitReader(src, 10<<20)).Decode(value); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidScormPackage, file.Name, err)
+	}
+	return nil
+}
+
+func parseImsManifest(file *zip.File) (*ScormManifest, error) {
+	var doc imsManifest
+	if err := readXML(file, &doc); err != nil {
+		return nil, err
+	}
+
+	manifest := &ScormManifest{Version: ScormVersion12, Identifier: doc.Identifier}
+	if strings.Contains(doc.SchemaVersion, "2004") || strings.HasPrefix(doc.SchemaVersion, "CAM 1.3") {
+		manifest.Version = ScormVersion2004
+	}
+
+	resources := make(map[string]imsResource, len(doc.Resources.Resource))
+	for _, resource := range doc.Resources.Resource {
+		resources[resource.Identifier] = resource
+	}
+
+	var organization *imsOrganization
+	for i := range doc.Organizations.Organization {
+		if organization == nil || doc.Organizations.Organization[i].Identifier == doc.Organizations.Default {
+			organization = &doc.Organizations.Organization[i]
+		}
+	}
+	if organization == nil {
+		return nil, fmt.Errorf("%w: the manifest has no organization", ErrInvalidScormPackage)
+	}
+	manifest.Title = strings.TrimSpace(organization.Title)
+
+	item, resource := firstLaunchableItem(organization.Items, resources)
+	if item == nil {
+		return nil, fmt.Errorf("%w: the manifest has no launchable item", ErrInvalidScormPackage)
+	}
+	launchPath, err := packagePath(doc.Resources.Base + resource.Base + resource.Href)
+	if err != nil {
+		return nil, err
+	}
+	manifest.LaunchPath = launchPath
+
+	if score, err := strconv.Atoi(strings.TrimSpace(item.MasteryScore)); err == nil {
+		manifest.MasteryScore = &score
+	}
+	return manifest, nil
+}
+
+// firstLaunchableItem finds, depth first, the first item pointing at a resource with an href
+func firstLaunchableItem(items []imsItem, resources map[string]imsResource) (*imsItem, imsResource) {
+	for i := range items {
+		if resource, ok := resources[items[i].IdentifierRef]; ok && resource.Href != "" {
+			return &items[i], resource
+		}
+		if item, resource := firstLaunchableItem(items[i].Items, resources); item != nil {
+			return item, resource
+		}
+	}
+	return nil, imsResource{}
+}
+
+func parseCmi5Course(file *zip.File) (*ScormManifest, error) {
+	var doc cmi5Course
+	if err := readXML(file, &doc); err != nil {
+		return nil, err
+	}
+
+	aus := doc.AUs
+	for _, block := range doc.Blocks {
+		aus = append(aus, block.AUs...)
+	}
+	if len(aus) == 0 {
+		return nil, fmt.Errorf("%w: the course has no assignable unit", ErrInvalidScormPackage)
+	}
+
+	manifest := &ScormManifest{Version: ScormVersionCmi5, Identifier: doc.Course.ID}
+	if len(doc.Course.Title) > 0 {
+		manifest.Title = strings.TrimSpace(doc.Course.Title[0])
+	}
+	launchPath, err := packagePath(aus[0].URL)
+	if err != nil {
+		return nil, err
+	}
+	manifest.LaunchPath = launchPath
+
+	// cmi5 mastery scores are decimals between 0 and 1
+	if score, err := strconv.ParseFloat(aus[0].MasteryScore, 64); err == nil {
+		percentage := int(math.Round(score * 100))
+		manifest.MasteryScore = &percentage
+	}
+	return manifest, nil
+}
+
+// packagePath checks that a launch URL points at a file inside the package
+func packagePath(launchURL string) (string, error) {
+	launchURL = strings.TrimSpace(launchURL)
+	file, query := launchURL, ""
+	if i := strings.IndexAny(launchURL, "?#"); i >= 0 {
+		file, query = launchURL[:i], launchURL[i:]
+	}
+	if file == "" || strings.Contains(file, "://") || strings.HasPrefix(file, "/") {
+		return "", fmt.Errorf("%w: launch URL %q is not a file in the package", ErrInvalidScormPackage, launchURL)
+	}
+	cleaned := path.Clean(file)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: launch URL %q is not a file in the package", ErrInvalidScormPackage, launchURL)
+	}
+	return cleaned + query, nil
+}
+
+const scormPackageColumns = `id, course_id, lesson_id, version, identifier, title, launch_path, storage_path,
+	mastery_score, uploaded_by, created_at, updated_at`
+
+func scanScormPackage(row interface{ Scan(...interface{}) error }) (*ScormPackage, error) {
+	var pkg ScormPackage
+	var masteryScore, uploadedBy sql.NullInt64
+	err := row.Scan(&pkg.ID, &pkg.CourseID, &pkg.LessonID, &pkg.Version, &pkg.Identifier, &pkg.Title,
+		&pkg.LaunchPath, &pkg.StoragePath, &masteryScore, &uploadedBy, &pkg.CreatedAt, &pkg.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	pkg.MasteryScore = nullIntPtr(masteryScore)
+	pkg.UploadedBy = nullIntPtr(uploadedBy)
+	return &pkg, nil
+}
+
+// GetScormPackage returns a package, or sql.ErrNoRows when it does not exist
+func GetScormPackage(db *sql.DB, packageID int) (*ScormPackage, error) {
+	return scanScormPackage(db.QueryRow(`SELECT `+scormPackageColumns+` FROM scorm_packages WHERE id = $1`, packageID))
+}
+
+// GetScormPackageByLesson returns the package of a lesson, or sql.ErrNoRows when the lesson has none
+func GetScormPackageByLesson(db *sql.DB, courseID, lessonID int) (*ScormPackage, error) {
+	return scanScormPackage(db.QueryRow(`SELECT `+scormPackageColumns+` FROM scorm_packages WHERE lesson_id = $1 AND course_id = $2`,
+		lessonID, courseID))
+}
+
+// SaveScormPackage stores the package of a lesson and turns the lesson into a
+// "scorm" lesson. A package replacing an earlier one starts learners' runtime
+// data afresh; lesson progress is kept. It returns the storage path of the
+// replaced package, if any, and sql.ErrNoRows when the lesson does not exist.
+func SaveScormPackage(tx *sql.Tx, pkg *ScormPackage) (string, error) {
+	if err := lockCourse(tx, pkg.CourseID); err != nil {
+		return "", err
+	}
+
+	var lessonID int
+	err := tx.QueryRow(`SELECT id FROM lessons WHERE id = $1 AND course_id = $2 FOR UPDATE`, pkg.LessonID, pkg.CourseID).Scan(&lessonID)
+	if err != nil {
+		return "", err
+	}
+
+	var oldID int
+	var oldStoragePath string
+	err = tx.QueryRow(`SELECT id, storage_path FROM scorm_packages WHERE lesson_id = $1`, pkg.LessonID).Scan(&oldID, &oldStoragePath)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	if oldID != 0 {
+		if _, err := tx.Exec(`DELETE FROM scorm_runtime WHERE package_id = $1`, oldID); err != nil {
+			return "", err
+		}
+	}
+
+	var uploadedBy interface{}
+	if pkg.UploadedBy != nil {
+		uploadedBy = *pkg.UploadedBy
+	}
+	err = tx.QueryRow(`
+		INSERT INTO scorm_packages (course_id, lesson_id, version, identifier, title, launch_path, storage_path,
+		                            mastery_score, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (lesson_id) DO UPDATE SET
+			version = EXCLUDED.version,
+			identifier = EXCLUDED.identifier,
+			title = EXCLUDED.title,
+			launch_path = EXCLUDED.launch_path,
+			storage_path = EXCLUDED.storage_path,
+			mastery_score = EXCLUDED.mastery_score,
+			uploaded_by = EXCLUDED.uploaded_by,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
+	`, pkg.CourseID, pkg.LessonID, pkg.Version, pkg.Identifier, pkg.Title, pkg.LaunchPath, pkg.StoragePath,
+		pkg.MasteryScore, uploadedBy).Scan(&pkg.ID, &pkg.CreatedAt, &pkg.UpdatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE lessons SET type = 'scorm',
+			metadata = metadata || jsonb_build_object('scormPackageId', $1::integer, 'scormVersion', $2::text),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`, pkg.ID, pkg.Version, pkg.LessonID)
+	if err != nil {
+		return "", err
+	}
+	return oldStoragePath, nil
+}
+
+// GetScormRuntime returns a learner's runtime data for a package, with the
+// elements a package reads on initialization filled in. A learner who has not
+// launched the package gets an empty runtime.
+func GetScormRuntime(db *sql.DB, pkg *ScormPackage, userID int) (*ScormRuntime, error) {
+	runtime := &ScormRuntime{PackageID: pkg.ID, UserID: userID, CMI: map[string]string{}, LessonStatus: "not attempted"}
+
+	var cmi []byte
+	var scoreRaw sql.NullFloat64
+	var updatedAt time.Time
+	err := db.QueryRow(`
+		SELECT cmi, lesson_status, score_raw, suspend_data, total_time, updated_at
+		FROM scorm_runtime
+		WHERE package_id = $1 AND user_id = $2
+	`, pkg.ID, userID).Scan(&cmi, &runtime.LessonStatus, &scoreRaw, &runtime.SuspendData, &runtime.TotalTime, &updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(cmi, &runtime.CMI); err != nil {
+			return nil, err
+		}
+		if scoreRaw.Valid {
+			runtime.ScoreRaw = &scoreRaw.Float64
+		}
+		runtime.UpdatedAt = &updatedAt
+	}
+
+	runtime.fillInitialValues(pkg.Version)
+	return runtime, nil
+}
+
+// fillInitialValues sets the read-only elements the SCORM API serves on initialization
+func (runtime *ScormRuntime) fillInitialValues(version string) {
+	entry := "ab-initio"
+	if runtime.SuspendData != "" {
+		entry = "resume"
+	}
+
+	switch version {
+	case ScormVersion12:
+		runtime.CMI["cmi.core.lesson_status"] = runtime.LessonStatus
+		runtime.CMI["cmi.core.entry"] = entry
+		runtime.CMI["cmi.core.total_time"] = fmt.Sprintf("%04d:%02d:%02d", runtime.TotalTime/3600, runtime.TotalTime/60%60, runtime.TotalTime%60)
+		runtime.CMI["cmi.suspend_data"] = runtime.SuspendData
+	case ScormVersion2004:
+		runtime.CMI["cmi.entry"] = entry
+		runtime.CMI["cmi.total_time"] = fmt.Sprintf("PT%dH%dM%dS", runtime.TotalTime/3600, runtime.TotalTime/60%60, runtime.TotalTime%60)
+		runtime.CMI["cmi.suspend_data"] = runtime.SuspendData
+	}
+}
+
+// SaveScormRuntime merges the elements a SCORM 1.2 or 2004 package committed
+// into a learner's runtime data and derives the status, score, suspend data
+// and time from them. With a mastery score, a reported score decides between
+// passed and failed.
+func SaveScormRuntime(db *sql.DB, pkg *ScormPackage, userID int, values map[string]string) (*ScormRuntime, error) {
+	runtime, err := GetScormRuntime(db, pkg, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := scormKeys12
+	if pkg.Version == ScormVersion2004 {
+		keys = scormKeys2004
+	}
+
+	status := values[keys.status]
+	if pkg.Version == ScormVersion2004 {
+		// 2004 splits the status into success and completion
+		status = values["cmi.completion_status"]
+		if success := values["cmi.success_status"]; success == "passed" || success == "failed" {
+			status = success
+		}
+		if status == "unknown" {
+			status = ""
+		}
+	}
+	if status != "" {
+		if !scormStatuses[status] {
+			return nil, fmt.Errorf("%w: unknown lesson status %q", ErrInvalidScormValue, status)
+		}
+		runtime.LessonStatus = status
+	}
+
+	if value, ok := values[keys.score]; ok && value != "" {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: score %q is not a number", ErrInvalidScormValue, value)
+		}
+		runtime.ScoreRaw = &score
+		if pkg.MasteryScore != nil {
+			runtime.LessonStatus = "failed"
+			if score >= float64(*pkg.MasteryScore) {
+				runtime.LessonStatus = "passed"
+			}
+		}
+	}
+	if value, ok := values[keys.suspendData]; ok {
+		if utf8.RuneCountInString(value) > maxScormSuspendData {
+			return nil, fmt.Errorf("%w: suspend data is longer than %d characters", ErrInvalidScormValue, maxScormSuspendData)
+		}
+		runtime.SuspendData = value
+	}
+	if value, ok := values[keys.sessionTime]; ok {
+		runtime.SessionTime = parseScormDuration(value)
+		runtime.TotalTime += runtime.SessionTime
+	}
+
+	for key, value := range values {
+		runtime.CMI[key] = value
+	}
+	runtime.fillInitialValues(pkg.Version)
+	if len(runtime.CMI) > maxScormCMIElements {
+		return nil, fmt.Errorf("%w: more than %d cmi elements", ErrInvalidScormValue, maxScormCMIElements)
+	}
+	cmi, err := json.Marshal(runtime.CMI)
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedAt time.Time
+	err = db.QueryRow(`
+		INSERT INTO scorm_runtime (package_id, user_id, cmi, lesson_status, score_raw, suspend_data, total_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (package_id, user_id) DO UPDATE SET
+			cmi = EXCLUDED.cmi,
+			lesson_status = EXCLUDED.lesson_status,
+			score_raw = EXCLUDED.score_raw,
+			suspend_data = EXCLUDED.suspend_data,
+			total_time = EXCLUDED.total_time,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at
+	`, pkg.ID, userID, string(cmi), runtime.LessonStatus, runtime.ScoreRaw, runtime.SuspendData, runtime.TotalTime).Scan(&updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	runtime.UpdatedAt = &updatedAt
+	return runtime, nil
+}
+
+// Completed reports whether the runtime status completes the lesson
+func (runtime *ScormRuntime) Completed() bool {
+	return runtime.LessonStatus == "completed" || runtime.LessonStatus == "passed"
+}
+
+// scormKeys names the cmi elements of a SCORM version that SaveScormRuntime reads
+type scormKeys struct {
+	status, score, suspendData, sessionTime string
+}
+
+var (
+	scormKeys12   = scormKeys{"cmi.core.lesson_status", "cmi.core.score.raw", "cmi.suspend_data", "cmi.core.session_time"}
+	scormKeys2004 = scormKeys{"cmi.completion_status", "cmi.score.raw", "cmi.suspend_data", "cmi.session_time"}
+)
+
+// scormDurationPattern matches an ISO 8601 duration as used by SCORM 2004 and xAPI
+var scormDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:([\d.]+)S)?)?$`)
+
+// parseScormDuration converts a SCORM 1.2 timespan (HHHH:MM:SS.SS) or an ISO
+// 8601 duration (PT1H2M3S) to seconds; malformed values count as zero
+func parseScormDuration(value string) int {
+	if match := scormDurationPattern.FindStringSubmatch(value); match != nil {
+		days, _ := strconv.Atoi(match[1])
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		seconds, _ := strconv.ParseFloat(match[4], 64)
+		return days*86400 + hours*3600 + minutes*60 + int(seconds)
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0
+	}
+	return hours*3600 + minutes*60 + int(seconds)
+}
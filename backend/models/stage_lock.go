@@ -77,8 +77,8 @@ func GetStageLock(db *sql.DB, courseID int, stageName string) (*StageLock, error
 // UpsertStageLock creates or updates a stage lock
 func UpsertStageLock(db *sql.DB, stageLock *StageLock) error {
 	query := `
-		INSERT INTO course_stage_locks (course_id, stage_name, is_locked, lock_message, locked_by, locked_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO course_stage_locks (course_id, stage_name, is_locked, lock_message, locked_by, locked_at, module_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (course_id, stage_name)
 		DO UPDATE SET
 			is_locked = EXCLUDED.is_locked,
@@ -103,6 +103,12 @@ func UpsertStageLock(db *sql.DB, stageLock *StageLock) error {
 		lockedBy = nil
 	}
 
+	// Module locks reference their module so they are removed with it
+	var moduleID interface{}
+	if id, ok := ParseModuleStageName(stageLock.StageName); ok {
+		moduleID = id
+	}
+
 	err := db.QueryRow(query,
 		stageLock.CourseID,
 		stageLock.StageName,
@@ -110,6 +116,7 @@ func UpsertStageLock(db *sql.DB, stageLock *StageLock) error {
 		stageLock.LockMessage,
 		lockedBy,
 		lockedAt,
+		moduleID,
 	).Scan(&stageLock.ID, &stageLock.CreatedAt, &stageLock.UpdatedAt)
 
 	return err
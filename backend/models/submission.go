@@ -86,81 +86,6 @@ type SubmissionWithGrade struct {
 	Feedback     string     `json:"feedback,omitempty"`
 }
 
-// CreatePostWorkSubmissionTable creates the postwork_submissions table
-func CreatePostWorkSubmissionTable(db *sql.DB) error {
-	// First, check if table exists and add lesson_id column if missing
-	alterQuery := `ALTER TABLE postwork_submissions ADD COLUMN IF NOT EXISTS lesson_id INTEGER;`
-	db.Exec(alterQuery) // Add lesson_id column if it doesn't exist
-
-	query := `
-	CREATE TABLE IF NOT EXISTS postwork_submissions (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-		course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
-		lesson_id INTEGER, -- NULL allowed for course-level PostWork
-		title VARCHAR(255) NOT NULL,
-		content TEXT NOT NULL,
-		attachments JSONB,
-		status VARCHAR(50) DEFAULT 'submitted',
-		score INTEGER,
-		feedback TEXT,
-		submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		reviewed_at TIMESTAMP,
-		reviewed_by INTEGER REFERENCES users(id),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err := db.Exec(query)
-	return err
-}
-
-// CreateFinalProjectSubmissionTable creates the final_project_submissions table
-func CreateFinalProjectSubmissionTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS final_project_submissions (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-		course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
-		title VARCHAR(255) NOT NULL,
-		description TEXT,
-		content TEXT NOT NULL,
-		attachments JSONB,
-		github_url VARCHAR(500),
-		live_url VARCHAR(500),
-		status VARCHAR(50) DEFAULT 'submitted',
-		score INTEGER,
-		feedback TEXT,
-		submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		reviewed_at TIMESTAMP,
-		reviewed_by INTEGER REFERENCES users(id),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(user_id, course_id)
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
-// CreateFileUploadTable creates the file_uploads table
-func CreateFileUploadTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS file_uploads (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-		file_name VARCHAR(255) NOT NULL,
-		original_name VARCHAR(255) NOT NULL,
-		file_path VARCHAR(500) NOT NULL,
-		file_size BIGINT NOT NULL,
-		mime_type VARCHAR(100) NOT NULL,
-		file_type VARCHAR(50) NOT NULL,
-		uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
 // CreatePostWorkSubmission creates a new postwork submission
 func CreatePostWorkSubmission(db *sql.DB, userID int, req SubmissionRequest) (*PostWorkSubmission, error) {
 	query := `
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TestResult is a completed pre-test or post-test attempt as shown to reviewers
+type TestResult struct {
+	AttemptID     int        `json:"attempt_id"`
+	QuizID        int        `json:"quiz_id"`
+	UserID        int        `json:"user_id"`
+	UserName      string     `json:"user_name"`
+	UserEmail     string     `json:"user_email"`
+	CourseID      int        `json:"course_id"`
+	CourseTitle   string     `json:"course_title"`
+	QuizTitle     string     `json:"quiz_title"`
+	QuizType      string     `json:"quiz_type"`
+	Score         int        `json:"score"`
+	Passed        bool       `json:"passed"`
+	PassingScore  int        `json:"passing_score"`
+	CorrectCount  int        `json:"correct_count"`
+	TotalCount    int        `json:"total_count"`
+	Points        *float64   `json:"points,omitempty"`
+	MaxPoints     *float64   `json:"max_points,omitempty"`
+	TimeSpent     int        `json:"time_spent"`
+	AttemptNumber int        `json:"attempt_number"`
+	SubmittedAt   *time.Time `json:"submitted_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TestResultListSpec are the sort and filter fields of test result lists
+var TestResultListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"attempt_id":     {Column: "qa.id", Type: ListNumber},
+		"quiz_id":        {Column: "qa.quiz_id", Type: ListNumber},
+		"user_id":        {Column: "qa.user_id", Type: ListNumber},
+		"user_name":      {Column: "u.full_name", Type: ListText},
+		"user_email":     {Column: "u.email", Type: ListText},
+		"course_id":      {Column: "c.id", Type: ListNumber},
+		"course_title":   {Column: "c.title", Type: ListText},
+		"quiz_title":     {Column: "q.title", Type: ListText},
+		"quiz_type":      {Column: "q.quiz_type", Type: ListText},
+		"score":          {Column: "qa.score", Type: ListNumber},
+		"passed":         {Column: "qa.passed", Type: ListBool},
+		"time_spent":     {Column: "qa.time_spent", Type: ListNumber},
+		"attempt_number": {Column: "qa.attempt_number", Type: ListNumber},
+		"submitted_at":   {Column: "qa.submitted_at", Type: ListTime},
+	},
+	SearchColumns: []string{"u.full_name", "u.email", "c.title", "q.title"},
+	DefaultSort:   "-submitted_at",
+	IDColumn:      "qa.id",
+}
+
+// GetTestResults retrieves a page of completed pre-test and post-test attempts.
+// A non-zero instructorID limits the result to that instructor's courses.
+func GetTestResults(db *sql.DB, instructorID int, list *ListQuery) ([]TestResult, *ListPage, error) {
+	columns := `
+			qa.id as attempt_id,
+			qa.quiz_id,
+			qa.user_id,
+			qa.score,
+			qa.time_spent,
+			qa.passed,
+			qa.attempt_number,
+			qa.submitted_at,
+			qa.created_at,
+			u.full_name as user_name,
+			u.email as user_email,
+			c.id as course_id,
+			c.title as course_title,
+			q.title as quiz_title,
+			q.quiz_type,
+			q.passing_score,
+			qa.points,
+			qa.max_points,
+			COALESCE(qa.question_set, q.questions) as questions,
+			qa.answers`
+	from := `quiz_attempts qa
+		JOIN users u ON qa.user_id = u.id
+		JOIN quizzes q ON qa.quiz_id = q.id
+		JOIN courses c ON q.course_id = c.id`
+	where := []string{
+		"qa.completed = true",
+		"qa.submitted_at IS NOT NULL",
+		"q.quiz_type IN ('pretest', 'posttest')",
+		InstructorCourseFilter("c.id", "$1"),
+	}
+
+	rows, page, err := list.Query(db, columns, from, where, instructorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	results := []TestResult{}
+	for rows.Next() {
+		var result TestResult
+		var submittedAt sql.NullTime
+		var points, maxPoints sql.NullFloat64
+		var questionsJSON []byte
+		var answersJSON sql.NullString
+
+		err := rows.Scan(
+			&result.AttemptID,
+			&result.QuizID,
+			&result.UserID,
+			&result.Score,
+			&result.TimeSpent,
+			&result.Passed,
+			&result.AttemptNumber,
+			&submittedAt,
+			&result.CreatedAt,
+			&result.UserName,
+			&result.UserEmail,
+			&result.CourseID,
+			&result.CourseTitle,
+			&result.QuizTitle,
+			&result.QuizType,
+			&result.PassingScore,
+			&points,
+			&maxPoints,
+			&questionsJSON,
+			&answersJSON,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if submittedAt.Valid {
+			result.SubmittedAt = &submittedAt.Time
+		}
+		result.Points, result.MaxPoints = nullFloatPtr(points), nullFloatPtr(maxPoints)
+
+		// Count the questions answered fully correctly by grading the
+		// attempt's answers again; the scoring options only change the score
+		questions, err := ParseQuizQuestions(questionsJSON)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse questions of attempt %d: %v", result.AttemptID, err)
+		}
+		answers := make(map[string]interface{})
+		if answersJSON.Valid {
+			json.Unmarshal([]byte(answersJSON.String), &answers)
+		}
+		grade := GradeQuiz(questions, answers, QuizScoring{})
+		result.CorrectCount, result.TotalCount = grade.CorrectCount, grade.TotalCount
+
+		results = append(results, result)
+	}
+
+	return results, page, rows.Err()
+}
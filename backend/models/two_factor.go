@@ -0,0 +1,227 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"lms-backend/security"
+)
+
+// ErrLoginChallengeInvalid is returned when a 2FA login challenge is unknown, used, expired or exhausted
+var ErrLoginChallengeInvalid = errors.New("invalid or expired login challenge")
+
+// maxLoginChallengeAttempts limits code guesses per challenge
+const maxLoginChallengeAttempts = 5
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"twoFactorRequired"`
+	ChallengeToken    string    `json:"challengeToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+// SetPendingTOTPSecret stores a new secret for a user who has not enabled 2FA yet
+func SetPendingTOTPSecret(db *sql.DB, userID int, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_last_step = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND totp_enabled_at IS NULL
+	`
+	result, err := db.Exec(query, secret, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	return nil
+}
+
+// VerifyTOTPCode checks a code against the user's stored secret and records the
+// accepted time step so the same code cannot be used twice
+func VerifyTOTPCode(db *sql.DB, userID int, code string) (bool, error) {
+	var secret sql.NullString
+	err := db.QueryRow(`SELECT totp_secret FROM users WHERE id = $1`, userID).Scan(&secret)
+	if err != nil {
+		return false, err
+	}
+	if !secret.Valid || secret.String == "" {
+		return false, nil
+	}
+
+	step, ok := security.ValidateTOTP(secret.String, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	result, err := db.Exec(`
+		UPDATE users
+		SET totp_last_step = $1
+		WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)
+	`, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// EnableTOTP activates 2FA for the user and stores a fresh set of recovery codes
+func EnableTOTP(db *sql.DB, userID int, recoveryCodes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP turns 2FA off and discards the secret and recovery codes
+func DisableTOTP(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and stores new ones
+func ReplaceRecoveryCodes(db *sql.DB, userID int, recoveryCodes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, recoveryCodes []string) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err := tx.Exec(
+			`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, hashToken(security.NormalizeRecoveryCode(code)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes
+func UseRecoveryCode(db *sql.DB, userID int, code string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_recovery_codes
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(security.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// CreateLoginChallenge issues the token a client exchanges, together with a
+// TOTP or recovery code, for a session
+func CreateLoginChallenge(db *sql.DB, userID int, ttl time.Duration) (string, time.Time, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	_, err = db.Exec(`
+		INSERT INTO login_challenges (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, hashToken(token), expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// StartLoginChallengeAttempt counts an attempt against a challenge and returns
+// its ID and user, failing once the challenge is used, expired or exhausted
+func StartLoginChallengeAttempt(db *sql.DB, token string) (int, int, error) {
+	var id, userID int
+	err := db.QueryRow(`
+		UPDATE login_challenges
+		SET attempts = attempts + 1
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 AND attempts < $3
+		RETURNING id, user_id
+	`, hashToken(token), time.Now(), maxLoginChallengeAttempts).Scan(&id, &userID)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrLoginChallengeInvalid
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, userID, nil
+}
+
+// CompleteLoginChallenge marks a challenge as used so it cannot be replayed
+func CompleteLoginChallenge(db *sql.DB, challengeID int) error {
+	_, err := db.Exec(`UPDATE login_challenges SET used_at = CURRENT_TIMESTAMP WHERE id = $1`, challengeID)
+	return err
+}
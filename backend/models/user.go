@@ -8,14 +8,18 @@ import (
 )
 
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"` // Don't include in JSON responses
-	FullName  string    `json:"fullName"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID               int        `json:"id"`
+	Username         string     `json:"username"`
+	Email            string     `json:"email"`
+	Password         string     `json:"-"` // Don't include in JSON responses
+	FullName         string     `json:"fullName"`
+	Role             string     `json:"role"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+	EmailVerifiedAt  *time.Time `json:"emailVerifiedAt"`
+	TwoFactorEnabled bool       `json:"twoFactorEnabled"`
+	// TokenVersion is embedded in access tokens; bumping it revokes them all
+	TokenVersion int `json:"-"`
 }
 
 type LoginRequest struct {
@@ -31,8 +35,14 @@ type RegisterRequest struct {
 }
 
 type LoginResponse struct {
-	User  User   `json:"user"`
-	Token string `json:"token"`
+	User         User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // HashPassword hashes the user's password
@@ -76,14 +86,39 @@ func CreateUser(db *sql.DB, user *User) error {
 func GetUserByUsername(db *sql.DB, username string) (*User, error) {
 	user := &User{}
 	query := `
-		SELECT id, username, email, password_hash, full_name, role, created_at, updated_at
+		SELECT id, username, email, password_hash, full_name, role, created_at, updated_at, token_version, email_verified_at,
+		       totp_enabled_at IS NOT NULL
 		FROM users
 		WHERE username = $1
 	`
 
 	err := db.QueryRow(query, username).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt, &user.TokenVersion, &user.EmailVerifiedAt,
+		&user.TwoFactorEnabled,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail retrieves a user by email address (case-insensitive)
+func GetUserByEmail(db *sql.DB, email string) (*User, error) {
+	user := &User{}
+	query := `
+		SELECT id, username, email, password_hash, full_name, role, created_at, updated_at, token_version, email_verified_at,
+		       totp_enabled_at IS NOT NULL
+		FROM users
+		WHERE LOWER(email) = LOWER($1)
+	`
+
+	err := db.QueryRow(query, email).Scan(
+		&user.ID, &user.Username, &user.Email, &user.Password,
+		&user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt, &user.TokenVersion, &user.EmailVerifiedAt,
+		&user.TwoFactorEnabled,
 	)
 
 	if err != nil {
@@ -97,14 +132,16 @@ func GetUserByUsername(db *sql.DB, username string) (*User, error) {
 func GetUserByID(db *sql.DB, id int) (*User, error) {
 	user := &User{}
 	query := `
-		SELECT id, username, email, password_hash, full_name, role, created_at, updated_at
+		SELECT id, username, email, password_hash, full_name, role, created_at, updated_at, token_version, email_verified_at,
+		       totp_enabled_at IS NOT NULL
 		FROM users
 		WHERE id = $1
 	`
 
 	err := db.QueryRow(query, id).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt, &user.TokenVersion, &user.EmailVerifiedAt,
+		&user.TwoFactorEnabled,
 	)
 
 	if err != nil {
@@ -143,4 +180,25 @@ func UpdatePassword(db *sql.DB, userID int, newPassword string) error {
 
 	_, err = db.Exec(query, string(hashedPassword), userID)
 	return err
-}
\ No newline at end of file
+}
+
+// IncrementTokenVersion bumps the user's token version, invalidating every
+// access token issued before the call
+func IncrementTokenVersion(db *sql.DB, userID int) error {
+	query := `
+		UPDATE users
+		SET token_version = token_version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
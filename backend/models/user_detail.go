@@ -140,26 +140,44 @@ func DeleteUserDetail(db *sql.DB, userID int) error {
 	return nil
 }
 
-// GetAllUserDetails retrieves all user details (for admin)
-func GetAllUserDetails(db *sql.DB) ([]UserDetail, error) {
+// UserDetailListSpec are the sort and filter fields of the admin user detail list
+var UserDetailListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"id":                  {Column: "id", Type: ListNumber},
+		"userId":              {Column: "user_id", Type: ListNumber},
+		"phone":               {Column: "phone", Type: ListText},
+		"location":            {Column: "location", Type: ListText},
+		"occupation":          {Column: "occupation", Type: ListText},
+		"education":           {Column: "education", Type: ListText},
+		"learning_style":      {Column: "learning_style", Type: ListText},
+		"skill_level":         {Column: "skill_level", Type: ListText},
+		"email_notifications": {Column: "email_notifications", Type: ListBool},
+		"push_notifications":  {Column: "push_notifications", Type: ListBool},
+		"weekly_reports":      {Column: "weekly_reports", Type: ListBool},
+		"createdAt":           {Column: "created_at", Type: ListTime},
+		"updatedAt":           {Column: "updated_at", Type: ListTime},
+	},
+	SearchColumns: []string{"phone", "location", "occupation", "education", "bio"},
+	DefaultSort:   "-createdAt",
+	IDColumn:      "id",
+}
+
+// GetAllUserDetails retrieves a page of user details (for admin)
+func GetAllUserDetails(db *sql.DB, list *ListQuery) ([]UserDetail, *ListPage, error) {
 	log.Println("[USER_DETAIL] Getting all user details")
-	
-	query := `
-		SELECT id, user_id, phone, location, occupation, education, bio, 
+
+	columns := `id, user_id, phone, location, occupation, education, bio, 
 		       learning_style, skill_level, email_notifications, push_notifications, 
-		       weekly_reports, created_at, updated_at
-		FROM user_details
-		ORDER BY created_at DESC
-	`
+		       weekly_reports, created_at, updated_at`
 
-	rows, err := db.Query(query)
+	rows, page, err := list.Query(db, columns, "user_details", nil)
 	if err != nil {
 		log.Printf("[USER_DETAIL] Error querying all user details: %v", err)
-		return nil, fmt.Errorf("failed to query user details: %v", err)
+		return nil, nil, fmt.Errorf("failed to query user details: %v", err)
 	}
 	defer rows.Close()
 
-	var userDetails []UserDetail
+	userDetails := []UserDetail{}
 	for rows.Next() {
 		var userDetail UserDetail
 		err := rows.Scan(
@@ -171,16 +189,16 @@ func GetAllUserDetails(db *sql.DB) ([]UserDetail, error) {
 		)
 		if err != nil {
 			log.Printf("[USER_DETAIL] Error scanning user detail row: %v", err)
-			return nil, fmt.Errorf("failed to scan user detail: %v", err)
+			return nil, nil, fmt.Errorf("failed to scan user detail: %v", err)
 		}
 		userDetails = append(userDetails, userDetail)
 	}
 
 	if err = rows.Err(); err != nil {
 		log.Printf("[USER_DETAIL] Error iterating user detail rows: %v", err)
-		return nil, fmt.Errorf("failed to iterate user details: %v", err)
+		return nil, nil, fmt.Errorf("failed to iterate user details: %v", err)
 	}
 
 	log.Printf("[USER_DETAIL] Successfully retrieved %d user details", len(userDetails))
-	return userDetails, nil
+	return userDetails, page, nil
 }
\ No newline at end of file
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserImportRow is one parsed line of a user import CSV
+type UserImportRow struct {
+	Row       int    `json:"row"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	FullName  string `json:"fullName"`
+	Role      string `json:"role"`
+	Password  string `json:"-"`
+	CourseIDs []int  `json:"courseIds"`
+}
+
+// UserImportError reports a problem with one field of an import row. Row 0
+// refers to the file as a whole.
+type UserImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportedUser is a user created by an import
+type ImportedUser struct {
+	Row      int    `json:"row"`
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	FullName string `json:"fullName"`
+	Role     string `json:"role"`
+	// Invited is set when the row had no password and the user must set one
+	Invited bool `json:"invited"`
+}
+
+// UserExportRow is one user with profile details as written to an export CSV
+type UserExportRow struct {
+	ID                 int
+	Username           string
+	Email              string
+	FullName           string
+	Role               string
+	EmailVerified      bool
+	CreatedAt          time.Time
+	Phone              sql.NullString
+	Location           sql.NullString
+	Occupation         sql.NullString
+	Education          sql.NullString
+	Bio                sql.NullString
+	LearningStyle      sql.NullString
+	SkillLevel         sql.NullString
+	EmailNotifications sql.NullBool
+	PushNotifications  sql.NullBool
+	WeeklyReports      sql.NullBool
+	CourseIDs          []int64
+}
+
+// ValidateUserImport checks import rows against the database: roles and courses
+// must exist and usernames and emails must be unused, both in the database and
+// within the file. Field-level checks that need no database are left to the caller.
+func ValidateUserImport(db *sql.DB, rows []UserImportRow) ([]UserImportError, error) {
+	var errs []UserImportError
+
+	roles := make(map[string]bool)
+	courses := make(map[int]bool)
+	seenUsernames := make(map[string]int)
+	seenEmails := make(map[string]int)
+
+	for _, row := range rows {
+		if row.Role != "" {
+			exists, ok := roles[row.Role]
+			if !ok {
+				var err error
+				exists, err = RoleExists(db, row.Role)
+				if err != nil {
+					return nil, err
+				}
+				roles[row.Role] = exists
+			}
+			if !exists {
+				errs = append(errs, UserImportError{Row: row.Row, Field: "role", Message: fmt.Sprintf("role %q does not exist", row.Role)})
+			}
+		}
+
+		if row.Username != "" {
+			key := strings.ToLower(row.Username)
+			if first, ok := seenUsernames[key]; ok {
+				errs = append(errs, UserImportError{Row: row.Row, Field: "username", Message: fmt.Sprintf("duplicate of row %d", first)})
+			} else {
+				seenUsernames[key] = row.Row
+				var taken bool
+				if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(username) = $1)`, key).Scan(&taken); err != nil {
+					return nil, err
+				}
+				if taken {
+					errs = append(errs, UserImportError{Row: row.Row, Field: "username", Message: "username already exists"})
+				}
+			}
+		}
+
+		if row.Email != "" {
+			key := strings.ToLower(row.Email)
+			if first, ok := seenEmails[key]; ok {
+				errs = append(errs, UserImportError{Row: row.Row, Field: "email", Message: fmt.Sprintf("duplicate of row %d", first)})
+			} else {
+				seenEmails[key] = row.Row
+				var taken bool
+				if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = $1)`, key).Scan(&taken); err != nil {
+					return nil, err
+				}
+				if taken {
+					errs = append(errs, UserImportError{Row: row.Row, Field: "email", Message: "email already exists"})
+				}
+			}
+		}
+
+		for _, courseID := range row.CourseIDs {
+			exists, ok := courses[courseID]
+			if !ok {
+				if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM courses WHERE id = $1)`, courseID).Scan(&exists); err != nil {
+					return nil, err
+				}
+				courses[courseID] = exists
+			}
+			if !exists {
+				errs = append(errs, UserImportError{Row: row.Row, Field: "course_ids", Message: fmt.Sprintf("course %d does not exist", courseID)})
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// ImportUsers creates the users and their course enrollments in one transaction.
+// Rows without a password get an unusable random one and are reported as invited.
+func ImportUsers(db *sql.DB, rows []UserImportRow) ([]ImportedUser, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	imported := make([]ImportedUser, 0, len(rows))
+	for _, row := range rows {
+		password := row.Password
+		invited := password == ""
+		if invited {
+			if password, err = generateToken(); err != nil {
+				return nil, err
+			}
+		}
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+
+		role := row.Role
+		if role == "" {
+			role = "user"
+		}
+
+		var userID int
+		err = tx.QueryRow(`
+			INSERT INTO users (username, email, password_hash, full_name, role, email_verified_at)
+			VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+			RETURNING id
+		`, row.Username, row.Email, string(passwordHash), row.FullName, role).Scan(&userID)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", row.Row, err)
+		}
+
+		for _, courseID := range row.CourseIDs {
+			_, err := tx.Exec(`
+				INSERT INTO course_enrollments (user_id, course_id, revision_id)
+				VALUES ($1, $2, `+CurrentRevisionSQL("$2")+`)
+				ON CONFLICT (user_id, course_id) DO NOTHING
+			`, userID, courseID)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", row.Row, err)
+			}
+		}
+
+		imported = append(imported, ImportedUser{
+			Row:      row.Row,
+			ID:       userID,
+			Username: row.Username,
+			Email:    row.Email,
+			FullName: row.FullName,
+			Role:     role,
+			Invited:  invited,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// ForEachUserExportRow calls fn for every user with profile details and enrolled
+// course IDs, ordered by ID, without loading all users into memory
+func ForEachUserExportRow(db *sql.DB, fn func(*UserExportRow) error) error {
+	rows, err := db.Query(`
+		SELECT u.id, u.username, u.email, u.full_name, u.role,
+		       u.email_verified_at IS NOT NULL, u.created_at,
+		       d.phone, d.location, d.occupation, d.education, d.bio,
+		       d.learning_style, d.skill_level, d.email_notifications,
+		       d.push_notifications, d.weekly_reports,
+		       COALESCE(ARRAY(SELECT ce.course_id FROM course_enrollments ce
+		                      WHERE ce.user_id = u.id ORDER BY ce.course_id), '{}')
+		FROM users u
+		LEFT JOIN user_details d ON d.user_id = u.id
+		ORDER BY u.id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row UserExportRow
+		err := rows.Scan(&row.ID, &row.Username, &row.Email, &row.FullName, &row.Role,
+			&row.EmailVerified, &row.CreatedAt,
+			&row.Phone, &row.Location, &row.Occupation, &row.Education, &row.Bio,
+			&row.LearningStyle, &row.SkillLevel, &row.EmailNotifications,
+			&row.PushNotifications, &row.WeeklyReports, pq.Array(&row.CourseIDs))
+		if err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
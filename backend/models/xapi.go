@@ -0,0 +1,222 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"lms-backend/xapi"
+
+	"github.com/lib/pq"
+)
+
+// Limits of one page of statements returned by the LRS endpoint
+const (
+	DefaultXAPIStatementLimit = 100
+	MaxXAPIStatementLimit     = 500
+)
+
+// XAPIStatementFilter holds the query parameters of a statements request
+type XAPIStatementFilter struct {
+	StatementID string
+	Verb        string
+	Activity    string
+	Agent       json.RawMessage
+	UserID      *int
+	Since       *time.Time
+	Until       *time.Time
+	Limit       int
+	Ascending   bool
+	// After continues a previous page: only statements stored after (or,
+	// descending, before) the row with this id are returned
+	After int
+}
+
+// XAPIAttempt is what an xAPI statement needs to know about a quiz attempt
+type XAPIAttempt struct {
+	UserID    int
+	CourseID  int
+	QuizID    int
+	QuizTitle string
+	Score     int
+	Passed    bool
+	TimeSpent int
+}
+
+// StoreXAPIStatement saves a prepared statement. It returns false when a
+// statement with the same id is already stored.
+func StoreXAPIStatement(db *sql.DB, statement *xapi.Prepared, userID *int) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO xapi_statements (statement_id, user_id, verb_id, object_id, statement)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (statement_id) DO NOTHING
+	`, statement.ID, userID, statement.VerbID, statement.ObjectID, []byte(statement.Statement))
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// XAPIStatementMatches reports whether the stored statement with the id of
+// statement has the same actor, verb, object, result and context, i.e. a
+// repeated post of the same statement rather than a conflicting one
+func XAPIStatementMatches(db *sql.DB, statement *xapi.Prepared) (bool, error) {
+	var matches bool
+	err := db.QueryRow(`
+		SELECT COALESCE(statement->'actor', 'null') = COALESCE($2::jsonb->'actor', 'null')
+			AND COALESCE(statement->'verb', 'null') = COALESCE($2::jsonb->'verb', 'null')
+			AND COALESCE(statement->'object', 'null') = COALESCE($2::jsonb->'object', 'null')
+			AND COALESCE(statement->'result', 'null') = COALESCE($2::jsonb->'result', 'null')
+			AND COALESCE(statement->'context', 'null') = COALESCE($2::jsonb->'context', 'null')
+		FROM xapi_statements
+		WHERE statement_id = $1
+	`, statement.ID, []byte(statement.Statement)).Scan(&matches)
+	return matches, err
+}
+
+// GetXAPIStatements returns one page of statements matching filter, newest
+// first unless filter.Ascending is set. more is the cursor of the next page,
+// or 0 when this is the last one.
+func GetXAPIStatements(db *sql.DB, filter XAPIStatementFilter) ([]json.RawMessage, int, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.StatementID != "" {
+		add("statement_id = $%d", filter.StatementID)
+	}
+	if filter.Verb != "" {
+		add("verb_id = $%d", filter.Verb)
+	}
+	if filter.Activity != "" {
+		add("object_id = $%d", filter.Activity)
+	}
+	if len(filter.Agent) > 0 {
+		add("statement->'actor' @> $%d::jsonb", []byte(filter.Agent))
+	}
+	if filter.UserID != nil {
+		add("user_id = $%d", *filter.UserID)
+	}
+	if filter.Since != nil {
+		add("stored_at > $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		add("stored_at <= $%d", *filter.Until)
+	}
+	order := "DESC"
+	if filter.Ascending {
+		order = "ASC"
+		if filter.After > 0 {
+			add("id > $%d", filter.After)
+		}
+	} else if filter.After > 0 {
+		add("id < $%d", filter.After)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxXAPIStatementLimit {
+		limit = DefaultXAPIStatementLimit
+	}
+
+	query := "SELECT id, statement FROM xapi_statements"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id %s LIMIT %d", order, limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	statements := []json.RawMessage{}
+	var lastID, more int
+	for rows.Next() {
+		var id int
+		var statement []byte
+		if err := rows.Scan(&id, &statement); err != nil {
+			return nil, 0, err
+		}
+		if len(statements) == limit {
+			more = lastID
+			break
+		}
+		statements = append(statements, statement)
+		lastID = id
+	}
+	return statements, more, rows.Err()
+}
+
+// XAPIPendingStatement is a stored statement not yet sent to the external LRS
+type XAPIPendingStatement struct {
+	StatementID string
+	Statement   json.RawMessage
+}
+
+// GetUnforwardedXAPIStatements returns up to limit statements that have not
+// reached the external LRS and have failed fewer than maxAttempts times,
+// statements that failed least first
+func GetUnforwardedXAPIStatements(db *sql.DB, limit, maxAttempts int) ([]XAPIPendingStatement, error) {
+	rows, err := db.Query(`
+		SELECT statement_id, statement FROM xapi_statements
+		WHERE forwarded_at IS NULL AND forward_attempts < $1
+		ORDER BY forward_attempts, id
+		LIMIT $2
+	`, maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statements []XAPIPendingStatement
+	for rows.Next() {
+		var statement XAPIPendingStatement
+		if err := rows.Scan(&statement.StatementID, &statement.Statement); err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+	return statements, rows.Err()
+}
+
+// RecordXAPIForwardFailure counts a failed attempt to send statements
+func RecordXAPIForwardFailure(db *sql.DB, statementIDs []string) error {
+	_, err := db.Exec(`
+		UPDATE xapi_statements SET forward_attempts = forward_attempts + 1
+		WHERE statement_id = ANY($1::uuid[])
+	`, pq.Array(statementIDs))
+	return err
+}
+
+// MarkXAPIStatementsForwarded records that statements reached the external LRS
+func MarkXAPIStatementsForwarded(db *sql.DB, statementIDs []string) error {
+	_, err := db.Exec(`
+		UPDATE xapi_statements SET forwarded_at = CURRENT_TIMESTAMP
+		WHERE statement_id = ANY($1::uuid[])
+	`, pq.Array(statementIDs))
+	return err
+}
+
+// GetXAPIAttempt returns the submitted quiz attempt with its quiz
+func GetXAPIAttempt(db *sql.DB, attemptID int) (*XAPIAttempt, error) {
+	var attempt XAPIAttempt
+	err := db.QueryRow(`
+		SELECT qa.user_id, q.course_id, q.id, q.title,
+			COALESCE(qa.score, 0), COALESCE(qa.passed, FALSE), COALESCE(qa.time_spent, 0)
+		FROM quiz_attempts qa
+		JOIN quizzes q ON q.id = qa.quiz_id
+		WHERE qa.id = $1
+	`, attemptID).Scan(&attempt.UserID, &attempt.CourseID, &attempt.QuizID, &attempt.QuizTitle,
+		&attempt.Score, &attempt.Passed, &attempt.TimeSpent)
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
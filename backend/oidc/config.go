@@ -0,0 +1,123 @@
+package oidc
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds the OpenID Connect client settings
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// RoleClaim is the ID token claim holding the user's groups or roles.
+	// Nested claims use dots, e.g. "realm_access.roles".
+	RoleClaim string
+	// RoleMapping maps claim values to LMS roles; the first match wins
+	RoleMapping []RoleMapping
+	DefaultRole string
+	// SyncRoles re-applies the role mapping on every login, not only on provisioning
+	SyncRoles bool
+
+	// AutoProvision creates a user on first login when no account matches
+	AutoProvision bool
+	// AllowedDomains restricts logins to these email domains when not empty
+	AllowedDomains []string
+}
+
+// RoleMapping maps one claim value to an LMS role
+type RoleMapping struct {
+	ClaimValue string
+	Role       string
+}
+
+// ConfigFromEnv reads the OIDC_* variables. It returns nil when OIDC_ISSUER_URL
+// or OIDC_CLIENT_ID is not set, meaning single sign-on is disabled.
+func ConfigFromEnv() *Config {
+	cfg := &Config{
+		IssuerURL:     strings.TrimRight(os.Getenv("OIDC_ISSUER_URL"), "/"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:        strings.Fields(os.Getenv("OIDC_SCOPES")),
+		RoleClaim:     os.Getenv("OIDC_ROLE_CLAIM"),
+		RoleMapping:   parseRoleMapping(os.Getenv("OIDC_ROLE_MAPPING")),
+		DefaultRole:   os.Getenv("OIDC_DEFAULT_ROLE"),
+		AutoProvision: true,
+	}
+
+	if cfg.IssuerURL == "" || cfg.ClientID == "" {
+		return nil
+	}
+
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "groups"
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = "user"
+	}
+	if v, err := strconv.ParseBool(os.Getenv("OIDC_AUTO_PROVISION")); err == nil {
+		cfg.AutoProvision = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("OIDC_SYNC_ROLES")); err == nil {
+		cfg.SyncRoles = v
+	}
+	for _, domain := range strings.Split(os.Getenv("OIDC_ALLOWED_DOMAINS"), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			cfg.AllowedDomains = append(cfg.AllowedDomains, domain)
+		}
+	}
+
+	return cfg
+}
+
+// parseRoleMapping parses "claim-value=role,other-value=role"
+func parseRoleMapping(value string) []RoleMapping {
+	var mappings []RoleMapping
+	for _, pair := range strings.Split(value, ",") {
+		claimValue, role, ok := strings.Cut(pair, "=")
+		claimValue, role = strings.TrimSpace(claimValue), strings.TrimSpace(role)
+		if !ok || claimValue == "" || role == "" {
+			continue
+		}
+		mappings = append(mappings, RoleMapping{ClaimValue: claimValue, Role: role})
+	}
+	return mappings
+}
+
+// MapRole returns the LMS role for the given claim values
+func (c *Config) MapRole(values []string) string {
+	for _, mapping := range c.RoleMapping {
+		for _, value := range values {
+			if value == mapping.ClaimValue {
+				return mapping.Role
+			}
+		}
+	}
+	return c.DefaultRole
+}
+
+// EmailAllowed reports whether logins from this email address are permitted
+func (c *Config) EmailAllowed(email string) bool {
+	if len(c.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range c.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
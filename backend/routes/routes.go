@@ -5,7 +5,10 @@ import (
 	"net/http"
 
 	"lms-backend/handlers"
+	"lms-backend/mail"
 	"lms-backend/middleware"
+	"lms-backend/oidc"
+	"lms-backend/xapi"
 
 	"github.com/gorilla/mux"
 )
@@ -14,22 +17,41 @@ import (
 func SetupRoutes(db *sql.DB) *mux.Router {
 	router := mux.NewRouter()
 
+	// Initialize mail delivery
+	mailer := mail.NewMailerFromEnv()
+
+	// Initialize single sign-on when an identity provider is configured
+	var oidcProvider *oidc.Provider
+	if oidcConfig := oidc.ConfigFromEnv(); oidcConfig != nil {
+		oidcProvider = oidc.NewProvider(oidcConfig)
+	}
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db)
+	authHandler := handlers.NewAuthHandler(db, mailer, oidcProvider)
 	courseHandler := handlers.NewCourseHandler(db)
 	progressHandler := handlers.NewProgressHandler(db)
 	quizHandler := handlers.NewQuizHandler(db)
 	submissionHandler := handlers.NewSubmissionHandler(db)
+	scormHandler := handlers.NewScormHandler(db)
+	xapiHandler := handlers.NewXAPIHandler(db)
 	certificateHandler := handlers.NewCertificateHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, mailer)
 	announcementHandler := handlers.NewAnnouncementHandler(db)
 	surveyHandler := handlers.NewSurveyHandler(db)
 	stageLockHandler := handlers.NewStageLockHandler(db)
 	userDetailHandler := handlers.NewUserDetailHandler(db)
+	instructorHandler := handlers.NewInstructorHandler(db)
 
 	// Set database for enhanced handlers
 	handlers.SetEnhancedHandlerDB(db)
 
+	// Forward xAPI statements when an external LRS is configured
+	handlers.SetXAPIForwarder(xapi.NewForwarderFromEnv())
+	handlers.StartXAPIForwarder(db)
+
+	// Grade attempts at timed quizzes abandoned past their deadline
+	handlers.StartQuizAttemptSweeper(db)
+
 	// Apply JSON middleware to all routes
 	router.Use(middleware.JSONMiddleware)
 	router.Use(middleware.LoggingMiddleware)
@@ -43,12 +65,24 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	// Authentication routes
 	public.HandleFunc("/register", authHandler.Register).Methods("POST", "OPTIONS")
 	public.HandleFunc("/login", authHandler.Login).Methods("POST", "OPTIONS")
+	public.HandleFunc("/login/2fa", authHandler.LoginTwoFactor).Methods("POST", "OPTIONS")
+	public.HandleFunc("/refresh", authHandler.Refresh).Methods("POST", "OPTIONS")
+	public.HandleFunc("/password/forgot", authHandler.ForgotPassword).Methods("POST", "OPTIONS")
+	public.HandleFunc("/password/reset", authHandler.ResetPassword).Methods("POST", "OPTIONS")
+	public.HandleFunc("/verify-email", authHandler.VerifyEmail).Methods("GET", "OPTIONS")
+
+	// Single sign-on routes
+	public.HandleFunc("/sso/oidc/login", authHandler.OIDCLogin).Methods("GET", "OPTIONS")
+	public.HandleFunc("/sso/oidc/callback", authHandler.OIDCCallback).Methods("GET", "OPTIONS")
 
 	// Public course routes
 	public.HandleFunc("/courses", courseHandler.GetAllCourses).Methods("GET", "OPTIONS")
 	public.HandleFunc("/courses/{id:[0-9]+}", courseHandler.GetCourseByID).Methods("GET", "OPTIONS")
 	public.HandleFunc("/courses/search", courseHandler.SearchCourses).Methods("GET", "OPTIONS")
 
+	// SCORM package assets, authorized by the token in the launch URL
+	public.HandleFunc("/scorm/{packageId:[0-9]+}/{token}/{path:.+}", scormHandler.ServeScormAsset).Methods("GET", "OPTIONS")
+
 	// Public certificate verification
 	public.HandleFunc("/certificates/verify/{certNumber}", certificateHandler.VerifyCertificate).Methods("GET", "OPTIONS")
 
@@ -60,6 +94,14 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	protected.HandleFunc("/user/profile", authHandler.GetProfile).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/user/profile", authHandler.UpdateProfile).Methods("PUT", "OPTIONS")
 	protected.HandleFunc("/user/change-password", authHandler.ChangePassword).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/logout", authHandler.Logout).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/user/resend-verification", authHandler.ResendVerification).Methods("POST", "OPTIONS")
+
+	// Two-factor authentication routes
+	protected.HandleFunc("/user/2fa/setup", authHandler.SetupTwoFactor).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/user/2fa/enable", authHandler.EnableTwoFactor).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/user/2fa/disable", authHandler.DisableTwoFactor).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/user/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes).Methods("POST", "OPTIONS")
 
 	// User detail routes
 	protected.HandleFunc("/user/detail", userDetailHandler.GetUserDetail).Methods("GET", "OPTIONS")
@@ -69,6 +111,7 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	protected.HandleFunc("/courses", courseHandler.GetCoursesWithEnrollment).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/courses/enroll", courseHandler.EnrollInCourse).Methods("POST", "OPTIONS")
 	protected.HandleFunc("/courses/enrollments", courseHandler.GetUserEnrollments).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/courses/{id:[0-9]+}", courseHandler.GetEnrolledCourse).Methods("GET", "OPTIONS")
 
 	// Progress tracking routes
 	protected.HandleFunc("/progress/lesson", progressHandler.UpdateLessonProgressHandler).Methods("POST", "OPTIONS")
@@ -77,6 +120,11 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	protected.HandleFunc("/progress", progressHandler.GetUserProgressListHandler).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/progress/sync", progressHandler.SyncProgressHandler).Methods("POST", "OPTIONS")
 
+	// SCORM lesson routes
+	protected.HandleFunc("/courses/{courseId:[0-9]+}/lessons/{lessonId:[0-9]+}/scorm/launch", scormHandler.LaunchScormLesson).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/courses/{courseId:[0-9]+}/lessons/{lessonId:[0-9]+}/scorm/runtime", scormHandler.GetScormRuntimeHandler).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/courses/{courseId:[0-9]+}/lessons/{lessonId:[0-9]+}/scorm/runtime", scormHandler.CommitScormRuntimeHandler).Methods("PUT", "OPTIONS")
+
 	// Quiz routes (legacy)
 	protected.HandleFunc("/quizzes/{id:[0-9]+}", quizHandler.GetQuizHandler).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/courses/{courseId:[0-9]+}/quizzes", quizHandler.GetQuizzesByCourseHandler).Methods("GET", "OPTIONS")
@@ -89,6 +137,7 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	// Enhanced Quiz routes (new improved system)
 	protected.HandleFunc("/courses/{courseId:[0-9]+}/quiz/{type}", handlers.GetQuizEnhancedHandler).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/courses/{courseId:[0-9]+}/quiz/{type}/start", handlers.StartQuizAttemptEnhancedHandler).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/quiz/attempts/{attemptId:[0-9]+}/answers", handlers.SaveQuizAnswersEnhancedHandler).Methods("PUT", "OPTIONS")
 	protected.HandleFunc("/quiz/attempts/{attemptId:[0-9]+}/submit", handlers.SubmitQuizEnhancedHandler).Methods("POST", "OPTIONS")
 	protected.HandleFunc("/courses/{courseId:[0-9]+}/quiz/{type}/attempts", handlers.GetQuizAttemptsEnhancedHandler).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/quiz/attempts/{attemptId:[0-9]+}/result", handlers.GetQuizResultEnhancedHandler).Methods("GET", "OPTIONS")
@@ -114,74 +163,137 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	protected.HandleFunc("/surveys/feedback", surveyHandler.SubmitSurveyFeedbackHandler).Methods("POST", "OPTIONS")
 	protected.HandleFunc("/surveys/feedback/{courseId:[0-9]+}", surveyHandler.GetSurveyFeedbackHandler).Methods("GET", "OPTIONS")
 
-	// Admin routes (admin role required)
+	// xAPI statements (built-in LRS)
+	protected.HandleFunc("/xapi/statements", xapiHandler.GetStatements).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/xapi/statements", xapiHandler.PostStatements).Methods("POST", "OPTIONS")
+
+	// Instructor routes (limited to the courses the user is assigned to)
+	instructor := protected.PathPrefix("/instructor").Subrouter()
+	instructorRoute := func(path, permission string, handler http.HandlerFunc) *mux.Route {
+		return instructor.Handle(path, middleware.RequirePermission(permission)(handler))
+	}
+	instructorRoute("/courses", "courses.view", instructorHandler.GetMyCourses).Methods("GET", "OPTIONS")
+	instructorRoute("/courses/{courseId:[0-9]+}/submissions", "submissions.view", instructorHandler.GetCourseSubmissions).Methods("GET", "OPTIONS")
+	instructorRoute("/courses/{courseId:[0-9]+}/surveys/feedback", "surveys.view", surveyHandler.GetInstructorSurveyFeedbackHandler).Methods("GET", "OPTIONS")
+	instructorRoute("/test-results", "test_results.view", instructorHandler.GetTestResults).Methods("GET", "OPTIONS")
+
+	// Admin routes (each route requires a permission granted by the user's role)
 	admin := protected.PathPrefix("/admin").Subrouter()
-	admin.Use(middleware.AdminMiddleware)
+	adminRoute := func(path, permission string, handler http.HandlerFunc) *mux.Route {
+		return admin.Handle(path, middleware.RequirePermission(permission)(handler))
+	}
 
 	// Admin quiz management routes
-	admin.HandleFunc("/quizzes", quizHandler.GetAllQuizzesHandler).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/quizzes", quizHandler.CreateQuizHandler).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/quizzes/{id:[0-9]+}", quizHandler.UpdateQuizHandler).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/quizzes/{id:[0-9]+}", quizHandler.DeleteQuizHandler).Methods("DELETE", "OPTIONS")
+	adminRoute("/quizzes", "quizzes.manage", quizHandler.GetAllQuizzesHandler).Methods("GET", "OPTIONS")
+	adminRoute("/quizzes", "quizzes.manage", quizHandler.CreateQuizHandler).Methods("POST", "OPTIONS")
+	adminRoute("/quizzes/{id:[0-9]+}", "quizzes.manage", quizHandler.UpdateQuizHandler).Methods("PUT", "OPTIONS")
+	adminRoute("/quizzes/{id:[0-9]+}", "quizzes.manage", quizHandler.DeleteQuizHandler).Methods("DELETE", "OPTIONS")
 
 	// Admin quiz access routes (no enrollment check)
-	admin.HandleFunc("/courses/{courseId:[0-9]+}/pretest", adminHandler.GetCoursePreTestAdmin).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/courses/{courseId:[0-9]+}/posttest", adminHandler.GetCoursePostTestAdmin).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{courseId:[0-9]+}/pretest", "courses.view", adminHandler.GetCoursePreTestAdmin).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{courseId:[0-9]+}/posttest", "courses.view", adminHandler.GetCoursePostTestAdmin).Methods("GET", "OPTIONS")
 
 	// Admin course management routes
-	admin.HandleFunc("/courses", adminHandler.GetAllCourses).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/courses", adminHandler.CreateCourse).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/courses/{id:[0-9]+}", adminHandler.UpdateCourse).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/courses/{id:[0-9]+}", adminHandler.DeleteCourse).Methods("DELETE", "OPTIONS")
+	adminRoute("/courses", "courses.view", adminHandler.GetAllCourses).Methods("GET", "OPTIONS")
+	adminRoute("/courses", "courses.manage", adminHandler.CreateCourse).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}", "courses.view", adminHandler.GetCourseAdmin).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}", "courses.edit", adminHandler.UpdateCourse).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/status", "courses.edit", adminHandler.UpdateCourseStatus).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/clone", "courses.manage", adminHandler.CloneCourse).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/template", "courses.manage", adminHandler.SetCourseTemplate).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/export", "courses.manage", adminHandler.ExportCoursePackage).Methods("GET", "OPTIONS")
+	adminRoute("/courses/import", "courses.manage", adminHandler.ImportCoursePackage).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}", "courses.manage", adminHandler.DeleteCourse).Methods("DELETE", "OPTIONS")
+
+	// Lesson management
+	adminRoute("/courses/{id:[0-9]+}/lessons", "courses.edit", adminHandler.GetCourseLessons).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons", "courses.edit", adminHandler.CreateLesson).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons/order", "courses.edit", adminHandler.ReorderLessons).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons/{lessonId:[0-9]+}", "courses.edit", adminHandler.GetCourseLesson).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons/{lessonId:[0-9]+}", "courses.edit", adminHandler.UpdateLesson).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons/{lessonId:[0-9]+}", "courses.edit", adminHandler.DeleteLesson).Methods("DELETE", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons/{lessonId:[0-9]+}/scorm", "courses.edit", adminHandler.GetScormPackageAdmin).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/lessons/{lessonId:[0-9]+}/scorm", "courses.edit", adminHandler.UploadScormPackage).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/questions", "quizzes.manage", adminHandler.GetBankQuestions).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/questions", "quizzes.manage", adminHandler.CreateBankQuestion).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/questions/{questionId:[0-9]+}", "quizzes.manage", adminHandler.GetBankQuestion).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/questions/{questionId:[0-9]+}", "quizzes.manage", adminHandler.UpdateBankQuestion).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/questions/{questionId:[0-9]+}", "quizzes.manage", adminHandler.DeleteBankQuestion).Methods("DELETE", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/modules", "courses.edit", adminHandler.GetCourseModules).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/modules", "courses.edit", adminHandler.CreateModule).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/modules/order", "courses.edit", adminHandler.ReorderModules).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/modules/{moduleId:[0-9]+}", "courses.edit", adminHandler.UpdateModule).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/modules/{moduleId:[0-9]+}", "courses.edit", adminHandler.DeleteModule).Methods("DELETE", "OPTIONS")
+
+	// Course revision history
+	adminRoute("/courses/{id:[0-9]+}/revisions", "courses.view", adminHandler.GetCourseRevisions).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/revisions/{revisionId:[0-9]+}", "courses.view", adminHandler.GetCourseRevision).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/revisions/{revisionId:[0-9]+}/diff", "courses.view", adminHandler.DiffCourseRevision).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/revisions/{revisionId:[0-9]+}/restore", "courses.edit", adminHandler.RestoreCourseRevision).Methods("POST", "OPTIONS")
 
 	// Admin grading system routes
-	admin.HandleFunc("/grading", adminHandler.CreateGrade).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/grading", adminHandler.GetGrades).Methods("GET", "OPTIONS")
+	adminRoute("/grading", "submissions.grade", adminHandler.CreateGrade).Methods("POST", "OPTIONS")
+	adminRoute("/grading", "submissions.view", adminHandler.GetGrades).Methods("GET", "OPTIONS")
 
 	// Admin submissions review routes
-	admin.HandleFunc("/courses/{courseId:[0-9]+}/submissions", adminHandler.GetCourseSubmissions).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{courseId:[0-9]+}/submissions", "submissions.view", adminHandler.GetCourseSubmissions).Methods("GET", "OPTIONS")
 
 	// Admin certificate management
-	admin.HandleFunc("/certificates", certificateHandler.GetAllCertificates).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/certificates/pending", certificateHandler.GetPendingCertificates).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/certificates/{certId:[0-9]+}/approve", certificateHandler.ApproveCertificate).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/certificates/{certId:[0-9]+}/reject", certificateHandler.RejectCertificate).Methods("POST", "OPTIONS")
+	adminRoute("/certificates", "certificates.view", certificateHandler.GetAllCertificates).Methods("GET", "OPTIONS")
+	adminRoute("/certificates/pending", "certificates.view", certificateHandler.GetPendingCertificates).Methods("GET", "OPTIONS")
+	adminRoute("/certificates/{certId:[0-9]+}/approve", "certificates.approve", certificateHandler.ApproveCertificate).Methods("POST", "OPTIONS")
+	adminRoute("/certificates/{certId:[0-9]+}/reject", "certificates.approve", certificateHandler.RejectCertificate).Methods("POST", "OPTIONS")
 
 	// Admin user management routes
-	admin.HandleFunc("/users", adminHandler.GetAllUsers).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/users", adminHandler.CreateUser).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/users/{id:[0-9]+}", adminHandler.UpdateUser).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/users/{id:[0-9]+}", adminHandler.DeleteUser).Methods("DELETE", "OPTIONS")
+	adminRoute("/users", "users.manage", adminHandler.GetAllUsers).Methods("GET", "OPTIONS")
+	adminRoute("/users", "users.manage", adminHandler.CreateUser).Methods("POST", "OPTIONS")
+	adminRoute("/users/import", "users.manage", adminHandler.ImportUsers).Methods("POST", "OPTIONS")
+	adminRoute("/users/export", "users.manage", adminHandler.ExportUsers).Methods("GET", "OPTIONS")
+	adminRoute("/users/{id:[0-9]+}", "users.manage", adminHandler.UpdateUser).Methods("PUT", "OPTIONS")
+	adminRoute("/users/{id:[0-9]+}", "users.manage", adminHandler.DeleteUser).Methods("DELETE", "OPTIONS")
+	adminRoute("/users/{id:[0-9]+}/revoke-sessions", "users.manage", adminHandler.RevokeUserSessions).Methods("POST", "OPTIONS")
+	adminRoute("/users/{id:[0-9]+}/unlock", "users.manage", adminHandler.UnlockUserLogin).Methods("POST", "OPTIONS")
 
 	// Admin user detail management routes
-	admin.HandleFunc("/user-details", userDetailHandler.GetAllUserDetails).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/user-details/{id:[0-9]+}", userDetailHandler.GetUserDetailByID).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/user-details/{id:[0-9]+}", userDetailHandler.UpdateUserDetailByID).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/user-details/{id:[0-9]+}", userDetailHandler.DeleteUserDetailByID).Methods("DELETE", "OPTIONS")
+	adminRoute("/user-details", "users.manage", userDetailHandler.GetAllUserDetails).Methods("GET", "OPTIONS")
+	adminRoute("/user-details/{id:[0-9]+}", "users.manage", userDetailHandler.GetUserDetailByID).Methods("GET", "OPTIONS")
+	adminRoute("/user-details/{id:[0-9]+}", "users.manage", userDetailHandler.UpdateUserDetailByID).Methods("PUT", "OPTIONS")
+	adminRoute("/user-details/{id:[0-9]+}", "users.manage", userDetailHandler.DeleteUserDetailByID).Methods("DELETE", "OPTIONS")
 
 	// Admin announcement management routes
-	admin.HandleFunc("/announcements", adminHandler.CreateAnnouncement).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/announcements", adminHandler.GetAllAnnouncements).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/announcements/{id:[0-9]+}", adminHandler.GetAnnouncementByID).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/announcements/{id:[0-9]+}", adminHandler.UpdateAnnouncement).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/announcements/{id:[0-9]+}", adminHandler.DeleteAnnouncement).Methods("DELETE", "OPTIONS")
+	adminRoute("/announcements", "announcements.manage", adminHandler.CreateAnnouncement).Methods("POST", "OPTIONS")
+	adminRoute("/announcements", "announcements.manage", adminHandler.GetAllAnnouncements).Methods("GET", "OPTIONS")
+	adminRoute("/announcements/{id:[0-9]+}", "announcements.manage", adminHandler.GetAnnouncementByID).Methods("GET", "OPTIONS")
+	adminRoute("/announcements/{id:[0-9]+}", "announcements.manage", adminHandler.UpdateAnnouncement).Methods("PUT", "OPTIONS")
+	adminRoute("/announcements/{id:[0-9]+}", "announcements.manage", adminHandler.DeleteAnnouncement).Methods("DELETE", "OPTIONS")
 
 	// Admin dashboard statistics route
-	admin.HandleFunc("/dashboard/stats", adminHandler.GetDashboardStats).Methods("GET", "OPTIONS")
+	adminRoute("/dashboard/stats", "dashboard.view", adminHandler.GetDashboardStats).Methods("GET", "OPTIONS")
 
 	// Admin test results route
-	admin.HandleFunc("/test-results", adminHandler.GetAllTestResults).Methods("GET", "OPTIONS")
+	adminRoute("/test-results", "test_results.view", adminHandler.GetAllTestResults).Methods("GET", "OPTIONS")
+
+	// Admin role and permission management routes
+	adminRoute("/roles", "roles.manage", adminHandler.GetRoles).Methods("GET", "OPTIONS")
+	adminRoute("/roles", "roles.manage", adminHandler.CreateRole).Methods("POST", "OPTIONS")
+	adminRoute("/roles/{name}/permissions", "roles.manage", adminHandler.SetRolePermissions).Methods("PUT", "OPTIONS")
+	adminRoute("/permissions", "roles.manage", adminHandler.GetPermissions).Methods("GET", "OPTIONS")
+
+	// Admin course instructor assignment routes
+	adminRoute("/courses/{id:[0-9]+}/instructors", "courses.manage", adminHandler.GetCourseInstructors).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/instructors", "courses.manage", adminHandler.AssignCourseInstructor).Methods("POST", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/instructors/{userId:[0-9]+}", "courses.manage", adminHandler.RemoveCourseInstructor).Methods("DELETE", "OPTIONS")
 
 	// Admin survey feedback routes
-	admin.HandleFunc("/surveys/feedback/{courseId:[0-9]+}", surveyHandler.GetAllSurveyFeedbackHandler).Methods("GET", "OPTIONS")
+	adminRoute("/surveys/feedback/{courseId:[0-9]+}", "surveys.view", surveyHandler.GetAllSurveyFeedbackHandler).Methods("GET", "OPTIONS")
 
 	// Admin stage lock management routes
-	admin.HandleFunc("/courses/{id:[0-9]+}/stage-locks", stageLockHandler.GetStageLocks).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/courses/{id:[0-9]+}/stage-locks", stageLockHandler.UpdateStageLock).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/stage-locks", "courses.edit", stageLockHandler.GetStageLocks).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{id:[0-9]+}/stage-locks", "courses.edit", stageLockHandler.UpdateStageLock).Methods("PUT", "OPTIONS")
 
 	// Admin course configuration routes
-	admin.HandleFunc("/courses/{courseId:[0-9]+}/config", handlers.GetCourseConfigHandler(db)).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/courses/{courseId:[0-9]+}/config", handlers.UpdateCourseConfigHandler(db)).Methods("PUT", "OPTIONS")
+	adminRoute("/courses/{courseId:[0-9]+}/config", "courses.edit", handlers.GetCourseConfigHandler(db)).Methods("GET", "OPTIONS")
+	adminRoute("/courses/{courseId:[0-9]+}/config", "courses.edit", handlers.UpdateCourseConfigHandler(db)).Methods("PUT", "OPTIONS")
 
 	// Protected stage access check routes
 	protected.HandleFunc("/courses/{courseId:[0-9]+}/stages/{stageName}/access", stageLockHandler.CheckStageAccess).Methods("GET", "OPTIONS")
@@ -200,7 +312,7 @@ func SetupRoutes(db *sql.DB) *mux.Router {
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"message":"Welcome to LMS Backend API","version":"1.0.0","endpoints":{"/api/public":["POST /register","POST /login","GET /courses","GET /courses/{id}","GET /courses/search"],"/api/protected":["GET /user/profile","PUT /user/profile","GET /courses","POST /courses/enroll","GET /courses/enrollments"]}}`))
+		w.Write([]byte(`{"message":"Welcome to LMS Backend API","version":"1.0.0","endpoints":{"/api/public":["POST /register","POST /login","POST /refresh","GET /courses","GET /courses/{id}","GET /courses/search"],"/api/protected":["GET /user/profile","PUT /user/profile","GET /courses","POST /courses/enroll","GET /courses/enrollments"]}}`))
 	}).Methods("GET", "OPTIONS")
 
 	return router
@@ -0,0 +1,71 @@
+package security
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// LockoutPolicy controls progressive lockout after repeated failed logins
+type LockoutPolicy struct {
+	// MaxAttempts is the number of failures allowed before the first lockout
+	MaxAttempts int
+	// BaseDuration is the first lockout; every further failure doubles it
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	// Window resets the failure counter when no failure happened for this long
+	Window time.Duration
+}
+
+// UsernameLockoutPolicyFromEnv reads LOGIN_MAX_ATTEMPTS and the shared lockout settings
+func UsernameLockoutPolicyFromEnv() LockoutPolicy {
+	policy := lockoutDefaultsFromEnv()
+	policy.MaxAttempts = intFromEnv("LOGIN_MAX_ATTEMPTS", 5)
+	return policy
+}
+
+// IPLockoutPolicyFromEnv reads LOGIN_MAX_ATTEMPTS_PER_IP and the shared lockout settings.
+// The per-IP limit is higher because several users may share one address.
+func IPLockoutPolicyFromEnv() LockoutPolicy {
+	policy := lockoutDefaultsFromEnv()
+	policy.MaxAttempts = intFromEnv("LOGIN_MAX_ATTEMPTS_PER_IP", 20)
+	return policy
+}
+
+func lockoutDefaultsFromEnv() LockoutPolicy {
+	return LockoutPolicy{
+		BaseDuration: durationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		MaxDuration:  durationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+		Window:       durationFromEnv("LOGIN_ATTEMPT_WINDOW", time.Hour),
+	}
+}
+
+// LockDuration returns how long to lock after the given number of consecutive failures
+func (p LockoutPolicy) LockDuration(failedCount int) time.Duration {
+	if failedCount < p.MaxAttempts {
+		return 0
+	}
+
+	d := p.BaseDuration
+	for i := p.MaxAttempts; i < failedCount; i++ {
+		d *= 2
+		if d >= p.MaxDuration {
+			return p.MaxDuration
+		}
+	}
+	return d
+}
+
+func intFromEnv(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
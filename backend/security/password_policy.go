@@ -0,0 +1,110 @@
+package security
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+func loadCommonPasswords(content string) map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = true
+	}
+	return set
+}
+
+// PasswordPolicy describes the rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength int
+	// MinCharClasses is how many of lowercase, uppercase, digits and symbols must appear
+	MinCharClasses int
+	RejectCommon   bool
+}
+
+// PasswordPolicyFromEnv reads the policy from PASSWORD_MIN_LENGTH,
+// PASSWORD_MIN_CHAR_CLASSES and PASSWORD_REJECT_COMMON
+func PasswordPolicyFromEnv() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:      8,
+		MinCharClasses: 2,
+		RejectCommon:   true,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && v > 0 {
+		policy.MinLength = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_CHAR_CLASSES")); err == nil && v >= 0 && v <= 4 {
+		policy.MinCharClasses = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("PASSWORD_REJECT_COMMON")); err == nil {
+		policy.RejectCommon = v
+	}
+
+	return policy
+}
+
+// Validate checks password against the policy. personalInfo holds values such
+// as the username or email that the password must not equal.
+func (p PasswordPolicy) Validate(password string, personalInfo ...string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		return fmt.Errorf("Password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinCharClasses)
+	}
+
+	lower := strings.ToLower(password)
+	if p.RejectCommon && commonPasswords[lower] {
+		return fmt.Errorf("Password is too common, please choose another one")
+	}
+
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+		if at := strings.Index(info, "@"); at > 0 {
+			info = info[:at]
+		}
+		if info != "" && lower == info {
+			return fmt.Errorf("Password must not be the same as your username or email")
+		}
+	}
+
+	return nil
+}
+
+func charClasses(password string) int {
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	count := 0
+	for _, ok := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if ok {
+			count++
+		}
+	}
+	return count
+}
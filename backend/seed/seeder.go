@@ -12,9 +12,6 @@ import (
 func SeedData(db *sql.DB) {
 	log.Println("Starting database seeding...")
 
-	// Create tables first
-	createNewTables(db)
-
 	// Seed users
 	seedUsers(db)
 
@@ -32,83 +29,6 @@ func SeedData(db *sql.DB) {
 	log.Println("Database seeding completed")
 }
 
-// createNewTables creates the new tables for progress, quiz, and submission features
-func createNewTables(db *sql.DB) {
-	log.Println("Creating new tables...")
-
-	// Create lesson progress table
-	if err := models.CreateLessonProgressTable(db); err != nil {
-		log.Printf("Error creating lesson_progress table: %v", err)
-	} else {
-		log.Println("Created lesson_progress table")
-	}
-
-	// Create course progress table
-	if err := models.CreateCourseProgressTable(db); err != nil {
-		log.Printf("Error creating course_progress table: %v", err)
-	} else {
-		log.Println("Created course_progress table")
-	}
-
-	// Create quiz table
-	if err := models.CreateQuizTable(db); err != nil {
-		log.Printf("Error creating quizzes table: %v", err)
-	} else {
-		log.Println("Created quizzes table")
-	}
-
-	// Create quiz attempt tableg
-	if err := models.CreateQuizAttemptTable(db); err != nil {
-		log.Printf("Error creating quiz_attempts table: %v", err)
-	} else {
-		log.Println("Created quiz_attempts table")
-	}
-
-	// Create postwork submission table
-	if err := models.CreatePostWorkSubmissionTable(db); err != nil {
-		log.Printf("Error creating postwork_submissions table: %v", err)
-	} else {
-		log.Println("Created postwork_submissions table")
-	}
-
-	// Create final project submission table
-	if err := models.CreateFinalProjectSubmissionTable(db); err != nil {
-		log.Printf("Error creating final_project_submissions table: %v", err)
-	} else {
-		log.Println("Created final_project_submissions table")
-	}
-
-	// Create file upload table
-	if err := models.CreateFileUploadTable(db); err != nil {
-		log.Printf("Error creating file_uploads table: %v", err)
-	} else {
-		log.Println("Created file_uploads table")
-	}
-
-	// Create certificates table
-	if err := createCertificatesTable(db); err != nil {
-		log.Printf("Error creating certificates table: %v", err)
-	} else {
-		log.Println("Created certificates table")
-	}
-
-	// Create grades table
-	if err := createGradesTable(db); err != nil {
-		log.Printf("Error creating grades table: %v", err)
-	} else {
-		log.Println("Created grades table")
-	}
-
-	// Create course stage locks table
-	if err := createCourseStageLocks(db); err != nil {
-		log.Printf("Error creating course_stage_locks table: %v", err)
-	} else {
-		log.Println("Created course_stage_locks table")
-	}
-
-	log.Println("New tables creation completed")
-}
-
 func seedUsers(db *sql.DB) {
 	// Check if users already exist
 	var count int
@@ -146,6 +66,7 @@ func seedUsers(db *sql.DB) {
 		if err != nil {
 			log.Printf("Error creating user %s: %v", user.Username, err)
 		} else {
+			models.MarkEmailVerified(db, user.ID)
 			log.Printf("Created user: %s", user.Username)
 		}
 	}
@@ -210,95 +131,6 @@ func seedStageLocks(db *sql.DB) {
 	}
 }
 
-
-// createCertificatesTable creates the certificates table
-func createCertificatesTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS certificates (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-		course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
-		cert_number VARCHAR(255) UNIQUE NOT NULL,
-		user_name VARCHAR(255) NOT NULL,
-		course_name VARCHAR(255) NOT NULL,
-		instructor VARCHAR(255) NOT NULL,
-		completion_date TIMESTAMP NOT NULL,
-		issued_at TIMESTAMP NOT NULL,
-		status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
-		approved_by INTEGER REFERENCES users(id),
-		approved_at TIMESTAMP,
-		rejection_reason TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(user_id, course_id)
-	);
-	
-	-- Create indexes for better query performance
-	CREATE INDEX IF NOT EXISTS idx_certificates_status ON certificates(status);
-	CREATE INDEX IF NOT EXISTS idx_certificates_user_course_status ON certificates(user_id, course_id, status);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
-// createGradesTable creates the grades table
-func createGradesTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS grades (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-		course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
-		submission_id INTEGER NOT NULL,
-		grade DECIMAL(5,2) NOT NULL CHECK (grade >= 0 AND grade <= 100),
-		feedback TEXT,
-		graded_by INTEGER NOT NULL REFERENCES users(id),
-		graded_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(submission_id, course_id)
-	);
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
-// createCourseStageLocks creates the course_stage_locks table
-func createCourseStageLocks(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS course_stage_locks (
-		id SERIAL PRIMARY KEY,
-		course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
-		stage_name VARCHAR(50) NOT NULL,
-		is_locked BOOLEAN NOT NULL DEFAULT FALSE,
-		lock_message TEXT DEFAULT '',
-		locked_by INTEGER REFERENCES users(id),
-		locked_at TIMESTAMP,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(course_id, stage_name)
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_course_stage_locks_course_id ON course_stage_locks(course_id);
-	CREATE INDEX IF NOT EXISTS idx_course_stage_locks_stage_name ON course_stage_locks(stage_name);
-	
-	CREATE OR REPLACE FUNCTION update_course_stage_locks_updated_at()
-	RETURNS TRIGGER AS $$
-	BEGIN
-		NEW.updated_at = CURRENT_TIMESTAMP;
-		RETURN NEW;
-	END;
-	$$ language 'plpgsql';
-	
-	DROP TRIGGER IF EXISTS update_course_stage_locks_updated_at ON course_stage_locks;
-	CREATE TRIGGER update_course_stage_locks_updated_at
-		BEFORE UPDATE ON course_stage_locks
-		FOR EACH ROW
-		EXECUTE FUNCTION update_course_stage_locks_updated_at();
-	`
-	_, err := db.Exec(query)
-	return err
-}
-
 func seedCourses(db *sql.DB) {
 	// Check if courses already exist
 	var count int
@@ -955,24 +787,33 @@ func seedCourses(db *sql.DB) {
 		query := `
 			INSERT INTO courses (
 				title, description, category, level, duration, instructor,
-				rating, students, image, intro_material, lessons, pre_test, post_test,
-				has_post_work, has_final_project, certificate_delay, step_weights
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+				rating, students, image, intro_material, pre_test, post_test,
+				has_post_work, has_final_project, certificate_delay, step_weights, status
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, 'published')
+			RETURNING id
 		`
 
-		_, err := db.Exec(query,
+		var courseID int
+		err := db.QueryRow(query,
 			courseData["title"], courseData["description"], courseData["category"],
 			courseData["level"], courseData["duration"], courseData["instructor"],
 			courseData["rating"], courseData["students"], courseData["image"],
-			introMaterialJSON, lessonsJSON, preTestJSON, postTestJSON,
+			introMaterialJSON, preTestJSON, postTestJSON,
 			hasPostWork, hasFinalProject, certificateDelay, stepWeightsJSON,
-		)
+		).Scan(&courseID)
 
 		if err != nil {
 			log.Printf("Error creating course %s: %v", courseData["title"], err)
-		} else {
-			log.Printf("Created course: %s", courseData["title"])
+			continue
+		}
+
+		if err := models.SyncCourseLessons(db, courseID, lessonsJSON); err != nil {
+			log.Printf("Error creating lessons for course %s: %v", courseData["title"], err)
+		}
+		if _, err := models.CreateCourseRevision(db, courseID, 0, "Initial revision"); err != nil {
+			log.Printf("Error creating revision for course %s: %v", courseData["title"], err)
 		}
+		log.Printf("Created course: %s", courseData["title"])
 	}
 }
 
@@ -0,0 +1,86 @@
+package xapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrStatementsRejected is returned when the LRS refuses statements as invalid,
+// as opposed to failing to take them for now
+var ErrStatementsRejected = errors.New("LRS rejected statements")
+
+// Forwarder sends stored statements on to an external LRS
+type Forwarder struct {
+	// Endpoint is the LRS base URL; statements go to Endpoint + "/statements"
+	Endpoint string
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+// NewForwarderFromEnv builds a Forwarder from XAPI_FORWARD_ENDPOINT,
+// XAPI_FORWARD_USERNAME and XAPI_FORWARD_PASSWORD. It returns nil when no
+// endpoint is set, meaning statements are only stored locally.
+func NewForwarderFromEnv() *Forwarder {
+	endpoint := strings.TrimRight(os.Getenv("XAPI_FORWARD_ENDPOINT"), "/")
+	if endpoint == "" {
+		return nil
+	}
+	return &Forwarder{
+		Endpoint: endpoint,
+		Username: os.Getenv("XAPI_FORWARD_USERNAME"),
+		Password: os.Getenv("XAPI_FORWARD_PASSWORD"),
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts statements to the external LRS in one request
+func (f *Forwarder) Send(statements []json.RawMessage) error {
+	body, err := json.Marshal(statements)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.Endpoint+"/statements", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Experience-API-Version", Version)
+	if f.Username != "" {
+		req.SetBasicAuth(f.Username, f.Password)
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to forward statements: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if invalidStatementsStatus(resp.StatusCode) {
+			return fmt.Errorf("%w: %v", ErrStatementsRejected, err)
+		}
+		return fmt.Errorf("LRS failed to store statements: %v", err)
+	}
+	return nil
+}
+
+// invalidStatementsStatus reports whether a response status blames the
+// statements sent: a client error other than failed authentication, a timeout
+// or rate limiting, which a retry of the same statements can get past
+func invalidStatementsStatus(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status < 500
+}
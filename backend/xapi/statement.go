@@ -0,0 +1,227 @@
+package xapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version is the xAPI version spoken by the LRS endpoint
+const Version = "1.0.3"
+
+// ErrInvalidStatement is returned for a statement the LRS cannot store
+var ErrInvalidStatement = errors.New("invalid xAPI statement")
+
+// ADL verbs used by the statements the LMS emits
+var (
+	VerbRegistered = Verb{ID: "http://adlnet.gov/expapi/verbs/registered", Display: map[string]string{"en-US": "registered"}}
+	VerbLaunched   = Verb{ID: "http://adlnet.gov/expapi/verbs/launched", Display: map[string]string{"en-US": "launched"}}
+	VerbCompleted  = Verb{ID: "http://adlnet.gov/expapi/verbs/completed", Display: map[string]string{"en-US": "completed"}}
+	VerbPassed     = Verb{ID: "http://adlnet.gov/expapi/verbs/passed", Display: map[string]string{"en-US": "passed"}}
+	VerbFailed     = Verb{ID: "http://adlnet.gov/expapi/verbs/failed", Display: map[string]string{"en-US": "failed"}}
+	VerbScored     = Verb{ID: "http://adlnet.gov/expapi/verbs/scored", Display: map[string]string{"en-US": "scored"}}
+)
+
+// ADL activity types of the activities the LMS emits statements about
+const (
+	ActivityTypeCourse      = "http://adlnet.gov/expapi/activities/course"
+	ActivityTypeLesson      = "http://adlnet.gov/expapi/activities/lesson"
+	ActivityTypeAssessment  = "http://adlnet.gov/expapi/activities/assessment"
+	ActivityTypePerformance = "http://adlnet.gov/expapi/activities/performance"
+)
+
+// Statement is an xAPI statement with an agent actor and an activity object
+type Statement struct {
+	ID        string     `json:"id,omitempty"`
+	Actor     Agent      `json:"actor"`
+	Verb      Verb       `json:"verb"`
+	Object    Activity   `json:"object"`
+	Result    *Result    `json:"result,omitempty"`
+	Context   *Context   `json:"context,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// Agent identifies a person; the LMS identifies learners by account
+type Agent struct {
+	ObjectType string   `json:"objectType,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Mbox       string   `json:"mbox,omitempty"`
+	Account    *Account `json:"account,omitempty"`
+}
+
+// Account is an agent's account on a system
+type Account struct {
+	HomePage string `json:"homePage"`
+	Name     string `json:"name"`
+}
+
+// Verb is the action of a statement
+type Verb struct {
+	ID      string            `json:"id"`
+	Display map[string]string `json:"display,omitempty"`
+}
+
+// Activity is the object of a statement
+type Activity struct {
+	ObjectType string              `json:"objectType,omitempty"`
+	ID         string              `json:"id"`
+	Definition *ActivityDefinition `json:"definition,omitempty"`
+}
+
+// ActivityDefinition describes an activity
+type ActivityDefinition struct {
+	Name map[string]string `json:"name,omitempty"`
+	Type string            `json:"type,omitempty"`
+}
+
+// Result is the outcome of a statement
+type Result struct {
+	Score      *Score `json:"score,omitempty"`
+	Success    *bool  `json:"success,omitempty"`
+	Completion *bool  `json:"completion,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+// Score is a result score; Scaled is between -1 and 1
+type Score struct {
+	Scaled *float64 `json:"scaled,omitempty"`
+	Raw    *float64 `json:"raw,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// Context relates a statement to other activities, e.g. a lesson to its course
+type Context struct {
+	ContextActivities *ContextActivities `json:"contextActivities,omitempty"`
+}
+
+// ContextActivities lists the activities a statement's object belongs to
+type ContextActivities struct {
+	Parent []Activity `json:"parent,omitempty"`
+}
+
+// NewActivity returns an activity with a type and an en-US name
+func NewActivity(id, activityType, name string) Activity {
+	activity := Activity{ObjectType: "Activity", ID: id, Definition: &ActivityDefinition{Type: activityType}}
+	if name != "" {
+		activity.Definition.Name = map[string]string{"en-US": name}
+	}
+	return activity
+}
+
+// PercentageScore returns a score out of 100
+func PercentageScore(percentage float64) *Score {
+	scaled := percentage / 100
+	min, max := 0.0, 100.0
+	return &Score{Scaled: &scaled, Raw: &percentage, Min: &min, Max: &max}
+}
+
+// Duration formats seconds as an ISO 8601 duration
+func Duration(seconds int) string {
+	return fmt.Sprintf("PT%dS", seconds)
+}
+
+// Prepared is a validated statement ready to be stored
+type Prepared struct {
+	ID        string
+	VerbID    string
+	ObjectID  string
+	Actor     json.RawMessage
+	Statement json.RawMessage
+}
+
+// Prepare validates a statement and fills in the properties the LRS sets: a
+// missing id and timestamp, stored, authority and version
+func Prepare(raw json.RawMessage, authority Agent, now time.Time) (*Prepared, error) {
+	var statement map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &statement); err != nil || statement == nil {
+		return nil, fmt.Errorf("%w: a statement must be a JSON object", ErrInvalidStatement)
+	}
+
+	prepared := &Prepared{Actor: statement["actor"]}
+	if value, ok := statement["id"]; ok {
+		if err := json.Unmarshal(value, &prepared.ID); err != nil {
+			return nil, fmt.Errorf("%w: id must be a UUID", ErrInvalidStatement)
+		}
+		id, err := uuid.Parse(prepared.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: id must be a UUID", ErrInvalidStatement)
+		}
+		prepared.ID = id.String()
+	} else {
+		prepared.ID = uuid.New().String()
+	}
+
+	var actor map[string]json.RawMessage
+	if json.Unmarshal(statement["actor"], &actor) != nil || actor == nil {
+		return nil, fmt.Errorf("%w: actor is required", ErrInvalidStatement)
+	}
+	if !hasIdentifier(actor) {
+		return nil, fmt.Errorf("%w: actor needs an mbox, mbox_sha1sum, openid, account or group members", ErrInvalidStatement)
+	}
+
+	var verb Verb
+	if json.Unmarshal(statement["verb"], &verb) != nil || !isIRI(verb.ID) {
+		return nil, fmt.Errorf("%w: verb.id must be an IRI", ErrInvalidStatement)
+	}
+	prepared.VerbID = verb.ID
+
+	var object struct {
+		ObjectType string `json:"objectType"`
+		ID         string `json:"id"`
+	}
+	if json.Unmarshal(statement["object"], &object) != nil {
+		return nil, fmt.Errorf("%w: object is required", ErrInvalidStatement)
+	}
+	if object.ObjectType == "" || object.ObjectType == "Activity" {
+		if !isIRI(object.ID) {
+			return nil, fmt.Errorf("%w: object.id must be an IRI", ErrInvalidStatement)
+		}
+		prepared.ObjectID = object.ID
+	}
+
+	set := func(key string, value interface{}) {
+		encoded, _ := json.Marshal(value)
+		statement[key] = encoded
+	}
+	set("id", prepared.ID)
+	if _, ok := statement["timestamp"]; !ok {
+		set("timestamp", now)
+	}
+	set("stored", now)
+	set("authority", authority)
+	if _, ok := statement["version"]; !ok {
+		set("version", Version)
+	}
+
+	encoded, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+	prepared.Statement = encoded
+	return prepared, nil
+}
+
+// hasIdentifier reports whether an actor has one of the inverse functional
+// identifiers of an agent, or is a group listing its members
+func hasIdentifier(actor map[string]json.RawMessage) bool {
+	for _, key := range []string{"mbox", "mbox_sha1sum", "openid", "account"} {
+		if _, ok := actor[key]; ok {
+			return true
+		}
+	}
+	var objectType string
+	json.Unmarshal(actor["objectType"], &objectType)
+	_, hasMembers := actor["member"]
+	return objectType == "Group" && hasMembers
+}
+
+// isIRI is a loose check that value is an absolute IRI
+func isIRI(value string) bool {
+	i := strings.Index(value, ":")
+	return i > 0 && i < len(value)-1 && !strings.ContainsAny(value, " \t\n")
+}